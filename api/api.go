@@ -0,0 +1,315 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Exposed APIs
+	___________________________________________________________________________
+*/
+
+func (s *Server) userAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		type payload struct {
+			UserName  string `json:"userName"`
+			PublicKey string `json:"publicKey"`
+			Password  string `json:"password"`
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+
+		var data payload
+		err = json.Unmarshal(body, &data)
+		if err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+
+		var pubKey *ecdsa.PublicKey
+		if data.PublicKey != "" {
+			pubKey, err = crypto.ParsePublicKey([]byte(data.PublicKey))
+			if err != nil {
+				s.apiLogger(w, errkind.Failed(err.Error()))
+				return
+			}
+		}
+
+		u, err := s.ledger.RegisterUser(data.UserName, pubKey, data.Password)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, u)
+	} else if r.Method == "GET" {
+		if _, err := s.currentUser(r); err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, s.ledger.Users())
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST")
+	}
+}
+
+/*
+	coinAPI mints coins for goofy on POST and lists coins on GET,
+	optionally filtered by ?id= or ?owner=
+*/
+func (s *Server) coinAPI(w http.ResponseWriter, r *http.Request) {
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	if r.Method == "POST" {
+		type payload struct {
+			Amount json.Number `json:"amount"`
+		}
+		var data payload
+		err = json.NewDecoder(r.Body).Decode(&data)
+		if err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+		amount, err := ledger.ParseAmount(data.Amount.String())
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+
+		c, err := s.ledger.Mint(uid, amount)
+		if err != nil {
+			s.rejectTx(err)
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, c)
+	} else if r.Method == "GET" {
+		if id := r.URL.Query().Get("id"); id != "" {
+			coinID, err := uuid.FromString(id)
+			if err != nil {
+				s.apiLogger(w, errkind.Malformed(err))
+				return
+			}
+			c, err := s.ledger.Coin(coinID)
+			if err != nil {
+				s.apiLogger(w, err)
+				return
+			}
+			s.writeJSON(w, http.StatusOK, c)
+			return
+		}
+		if owner := r.URL.Query().Get("owner"); owner != "" {
+			ownerID, err := uuid.FromString(owner)
+			if err != nil {
+				s.apiLogger(w, errkind.Malformed(err))
+				return
+			}
+			s.writeJSON(w, http.StatusOK, s.ledger.CoinsOf(ownerID))
+			return
+		}
+		s.writeJSON(w, http.StatusOK, s.ledger.Coins())
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST")
+	}
+}
+
+/*
+	coinIDAPI serves /api/coin/{id}/history, every hop of the coin from its
+	mints with the keys to check them
+*/
+func (s *Server) coinIDAPI(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/coin/"), "/")
+	if action != "history" {
+		s.apiLogger(w, errkind.New(errkind.NotFound, "unknown coin action "+action))
+		return
+	}
+	if r.Method != "GET" {
+		s.methodNotAllowed(w, r, "GET")
+		return
+	}
+	if _, err := s.currentUser(r); err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	coinID, err := uuid.FromString(id)
+	if err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	h, err := s.ledger.History(coinID)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, h)
+}
+
+/*
+	parseSignature() parses the optional hex signature r and s, both empty
+	leaves the signing to the server
+*/
+func parseSignature(r, s string) (*big.Int, *big.Int, error) {
+	if r == "" && s == "" {
+		return nil, nil, nil
+	}
+	sigR, sigS, err := crypto.ParseSignature(r, s)
+	if err != nil {
+		return nil, nil, errkind.Malformed(err)
+	}
+	return sigR, sigS, nil
+}
+
+/*
+	txAPI passes a coin to a receiver on POST, optionally locked, hash
+	locked or scripted and with a memo, and lists the transactions the
+	caller may see on GET, see ledger.VisibleTo(), optionally a single one
+	with ?hash= or those whose memo matches ?memo= key or key=value. A POST naming a prevHash other than the
+	coin's last Tx is rejected as double spend, one claiming a hash locked
+	coin carries the preimage and one spending a scripted coin the unlocking
+	script
+*/
+func (s *Server) txAPI(w http.ResponseWriter, r *http.Request) {
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	if r.Method == "POST" {
+		type payload struct {
+			Coin     uuid.UUID        `json:"coin"`
+			Receiver uuid.UUID        `json:"receiver"`
+			PrevHash ledger.HexBytes  `json:"prevHash"`
+			Lock     *ledger.Lock     `json:"lock"`
+			HashLock *ledger.HashLock `json:"hashLock"`
+			Preimage ledger.HexBytes  `json:"preimage"`
+			Script   ledger.HexBytes  `json:"script"`
+			Unlock   ledger.HexBytes  `json:"unlock"`
+			Memo     ledger.Memo      `json:"memo"`
+			R        string           `json:"r"`
+			S        string           `json:"s"`
+		}
+		var data payload
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+
+		sigR, sigS, err := parseSignature(data.R, data.S)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+
+		Tx, err := s.ledger.Pay(ledger.Payment{Spender: uid, Coin: data.Coin, Receiver: data.Receiver, Lock: data.Lock, HashLock: data.HashLock, Preimage: data.Preimage, Script: data.Script, Unlock: data.Unlock, PrevHash: data.PrevHash, Memo: data.Memo, R: sigR, S: sigS})
+		if err != nil {
+			s.rejectTx(err)
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, Tx)
+	} else if r.Method == "GET" {
+		if h := r.URL.Query().Get("hash"); h != "" {
+			hash, err := hex.DecodeString(h)
+			if err != nil {
+				s.apiLogger(w, errkind.Malformed(err))
+				return
+			}
+			Tx, err := s.ledger.Transaction(hash)
+			if err == nil && !s.ledger.VisibleTo(uid, Tx) {
+				err = ledger.ErrTxNotFound
+			}
+			if err != nil {
+				s.apiLogger(w, err)
+				return
+			}
+			s.writeJSON(w, http.StatusOK, Tx)
+			return
+		}
+		if q := r.URL.Query().Get("memo"); q != "" {
+			s.writeJSON(w, http.StatusOK, s.ledger.TransactionsWithMemo(uid, q))
+			return
+		}
+		s.writeJSON(w, http.StatusOK, s.ledger.TransactionsOf(uid))
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST")
+	}
+}
+
+/*
+	balanceAPI returns the balance and coins of ?user=, split into spendable
+	and locked value
+*/
+func (s *Server) balanceAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		s.methodNotAllowed(w, r, "GET")
+		return
+	}
+	if _, err := s.currentUser(r); err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	userID, err := uuid.FromString(r.URL.Query().Get("user"))
+	if err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+
+	b, err := s.ledger.Balance(userID)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, b)
+}
+
+/*
+	chainStatus is the result of VerifyChain() for a chain of Length
+	transactions and Height sealed blocks
+*/
+type chainStatus struct {
+	Valid  bool   `json:"valid"`
+	Length int    `json:"length"`
+	Height int    `json:"height"`
+	Error  string `json:"error,omitempty"`
+}
+
+/*
+	chainVerifyAPI reports whether the chain passes VerifyChain(), it is
+	public like /api/info: the status tells nothing of users or coins. The
+	ledger only grows, so the status is kept until a Tx or block is added
+	and concurrent requests wait for a single verification
+*/
+func (s *Server) chainVerifyAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		s.methodNotAllowed(w, r, "GET")
+		return
+	}
+	s.verifyMu.Lock()
+	defer s.verifyMu.Unlock()
+	length, height := s.ledger.Len(), s.ledger.Height()
+	if v := s.verified; v == nil || v.Length != length || v.Height != height {
+		v = &chainStatus{Valid: true, Length: length, Height: height}
+		if err := s.ledger.VerifyChain(); err != nil {
+			v.Valid = false
+			v.Error = err.Error()
+		}
+		s.verified = v
+	}
+	s.writeJSON(w, http.StatusOK, s.verified)
+}
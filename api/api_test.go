@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	goofycoin "github.com/de7ign/goofy-coin"
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/de7ign/goofy-coin/wallet"
+)
+
+/*
+	newTestServer() returns a ready server of a new ledger journaled to a
+	temporary directory, goofy logs in with "goofy-password"
+*/
+func newTestServer(t *testing.T) (*Server, wallet.User) {
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(ledger.New(), Options{Static: goofycoin.Static, Version: "test", LocalWebhooks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.Start(t.TempDir(), key, "goofy-password"); err != nil {
+		t.Fatal(err)
+	}
+	goofy, err := s.ledger.Goofy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, goofy
+}
+
+/*
+	token() returns a session token of user valid for a minute
+*/
+func (s *Server) token(t *testing.T, user wallet.User) string {
+	token, _, err := s.newSession(user.UUID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func apiRequest(handler http.HandlerFunc, method, target, token, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	handler(rec, req)
+	return rec
+}
+
+func TestAPIErrors(t *testing.T) {
+	s, goofy := newTestServer(t)
+	alice, err := s.ledger.RegisterUser("alice", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staleHash := hex.EncodeToString(c.TxHash)
+	_, err = s.ledger.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	goofyToken := s.token(t, goofy)
+	aliceToken := s.token(t, alice)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		token   string
+		body    string
+		status  int
+		code    string
+	}{
+		{"user malformed body", s.userAPI, "POST", "/api/user", goofyToken, "{", http.StatusBadRequest, "bad_request"},
+		{"user bad public key", s.userAPI, "POST", "/api/user", goofyToken, `{"userName": "bob", "publicKey": "x"}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"user wrong method", s.userAPI, "DELETE", "/api/user", goofyToken, "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"user invalid name", s.userAPI, "POST", "/api/user", goofyToken, `{"userName": "bob smith"}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"user name taken", s.userAPI, "POST", "/api/user", goofyToken, `{"userName": "Goofy"}`, http.StatusConflict, "conflict"},
+		{"coin fractional amount", s.coinAPI, "POST", "/api/coin", goofyToken, `{"amount": 1.5}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"coin negative amount", s.coinAPI, "POST", "/api/coin", goofyToken, `{"amount": -1}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"coin malformed id", s.coinAPI, "GET", "/api/coin?id=x", goofyToken, "", http.StatusBadRequest, "bad_request"},
+		{"coin not found", s.coinAPI, "GET", "/api/coin?id=" + unknown, goofyToken, "", http.StatusNotFound, "not_found"},
+		{"coin wrong method", s.coinAPI, "PUT", "/api/coin", goofyToken, "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"coin unknown action", s.coinIDAPI, "GET", "/api/coin/" + c.UUID.String() + "/owner", goofyToken, "", http.StatusNotFound, "not_found"},
+		{"coin history malformed id", s.coinIDAPI, "GET", "/api/coin/x/history", goofyToken, "", http.StatusBadRequest, "bad_request"},
+		{"coin history not found", s.coinIDAPI, "GET", "/api/coin/" + unknown + "/history", goofyToken, "", http.StatusNotFound, "not_found"},
+		{"coin history wrong method", s.coinIDAPI, "POST", "/api/coin/" + c.UUID.String() + "/history", goofyToken, "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"tx malformed signature", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + c.UUID.String() + `", "receiver": "` + alice.UUID.String() + `", "r": "xyz", "s": "1"}`, http.StatusBadRequest, "bad_request"},
+		{"tx unknown coin", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + unknown + `", "receiver": "` + alice.UUID.String() + `"}`, http.StatusNotFound, "not_found"},
+		{"tx unknown receiver", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + c.UUID.String() + `", "receiver": "` + unknown + `"}`, http.StatusNotFound, "not_found"},
+		{"tx invalid signature", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + c.UUID.String() + `", "receiver": "` + alice.UUID.String() + `", "r": "1", "s": "1"}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"tx memo too large", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + c.UUID.String() + `", "receiver": "` + goofy.UUID.String() + `", "memo": {"note": "` + strings.Repeat("x", ledger.MaxMemoSize) + `"}}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"tx malformed memo", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + c.UUID.String() + `", "receiver": "` + goofy.UUID.String() + `", "memo": {"order": 42}}`, http.StatusBadRequest, "bad_request"},
+		{"tx double spend", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + c.UUID.String() + `", "receiver": "` + alice.UUID.String() + `", "prevHash": "` + staleHash + `"}`, http.StatusConflict, "double_spend"},
+		{"tx list without session", s.txAPI, "GET", "/api/tx", "", "", http.StatusUnauthorized, "unauthorized"},
+		{"tx hash without session", s.txAPI, "GET", "/api/tx?hash=" + staleHash, "", "", http.StatusUnauthorized, "unauthorized"},
+		{"tx memo without session", s.txAPI, "GET", "/api/tx?memo=order", "", "", http.StatusUnauthorized, "unauthorized"},
+		{"tx not found", s.txAPI, "GET", "/api/tx?hash=00", goofyToken, "", http.StatusNotFound, "not_found"},
+		{"balance unknown user", s.balanceAPI, "GET", "/api/balance?user=" + unknown, goofyToken, "", http.StatusNotFound, "not_found"},
+		{"balance wrong method", s.balanceAPI, "POST", "/api/balance", goofyToken, "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"chain wrong method", s.chainVerifyAPI, "POST", "/api/chain/verify", goofyToken, "", http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	for _, tt := range tests {
+		users := len(s.ledger.Users())
+		rec := apiRequest(tt.handler, tt.method, tt.target, tt.token, tt.body)
+
+		var res apiError
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		if err != nil {
+			t.Errorf("%s: body %q is not an error: %v", tt.name, rec.Body.String(), err)
+			continue
+		}
+		if rec.Code != tt.status || res.Code != tt.code || res.Message == "" {
+			t.Errorf("%s: got %d %+v, want %d %s", tt.name, rec.Code, res, tt.status, tt.code)
+		}
+		if len(s.ledger.Users()) != users {
+			t.Errorf("%s: failed request created a user", tt.name)
+		}
+	}
+}
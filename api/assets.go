@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+/*
+	asset is a file held in memory with its ETag
+*/
+type asset struct {
+	data []byte
+	etag string
+}
+
+var startTime = time.Now()
+
+/*
+	loadAssets() reads every file of fsys into memory
+*/
+func loadAssets(fsys fs.FS) (map[string]asset, error) {
+	assets := map[string]asset{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		assets[name] = asset{data: data, etag: etag(data)}
+		return nil
+	})
+	return assets, err
+}
+
+func etag(data []byte) string {
+	hash := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+/*
+	serveAsset() writes the named file, from disk if Options.StaticDir is set
+	and from memory otherwise, conditional requests are answered by
+	http.ServeContent through the ETag
+*/
+func (s *Server) serveAsset(w http.ResponseWriter, r *http.Request, name string) {
+	var a asset
+	modTime := startTime
+	if s.opts.StaticDir != "" {
+		data, err := os.ReadFile(filepath.Join(s.opts.StaticDir, filepath.FromSlash(name)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		a = asset{data: data, etag: etag(data)}
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		var ok bool
+		a, ok = s.assets[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if path.Ext(name) == ".html" {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+		}
+	}
+	w.Header().Set("ETag", a.etag)
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(a.data))
+}
+
+/*
+	assetDirHandler serves files below dir, e.g. assets/js for /js/
+*/
+func (s *Server) assetDirHandler(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveAsset(w, r, path.Join(dir, path.Clean("/"+r.URL.Path)))
+	}
+}
+
+/*
+	indexHandler serves '/' endpoint
+*/
+func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveAsset(w, r, "public/index.html")
+}
+
+/*
+	dashboardHandler serves '/dashboard' endpoint
+*/
+func (s *Server) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveAsset(w, r, "public/dashboard.html")
+}
+
+/*
+	openAPIHandler serves '/api/openapi.json', the OpenAPI document of the API
+*/
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveAsset(w, r, "assets/openapi.json")
+}
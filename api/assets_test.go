@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+func TestServeEmbeddedAsset(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	http.StripPrefix("/js/", s.assetDirHandler("assets/js")).ServeHTTP(rec, httptest.NewRequest("GET", "/js/script.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	tag := rec.Header().Get("ETag")
+	if tag == "" || rec.Header().Get("Cache-Control") == "" {
+		t.Error("missing cache headers")
+	}
+
+	req := httptest.NewRequest("GET", "/js/script.js", nil)
+	req.Header.Set("If-None-Match", tag)
+	rec = httptest.NewRecorder()
+	http.StripPrefix("/js/", s.assetDirHandler("assets/js")).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	http.StripPrefix("/js/", s.assetDirHandler("assets/js")).ServeHTTP(rec, httptest.NewRequest("GET", "/js/../../static.go", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServeAssetFromDisk(t *testing.T) {
+	s, err := New(ledger.New(), Options{StaticDir: ".."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rec := httptest.NewRecorder()
+	s.dashboardHandler(rec, httptest.NewRequest("GET", "/dashboard", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("status %d, cache-control %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/de7ign/goofy-coin/wallet"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Authentication
+	___________________________________________________________________________
+
+	A user logs in with a password, or by signing a challenge with the private
+	key of the user's registered public key, and receives a session token which
+	is sent as "Authorization: Bearer <token>" on every further request
+*/
+
+const (
+	sessionTTL    = time.Hour
+	keySessionTTL = 15 * time.Minute
+	challengeTTL  = time.Minute
+	challengeLen  = 32
+	// userChallenges is how many challenges of one user may be pending,
+	// a new one replaces the oldest
+	userChallenges = 4
+)
+
+type session struct {
+	user    uuid.UUID
+	expires time.Time
+}
+
+/*
+	challenge is a single-use nonce issued to the user named in the request
+*/
+type challenge struct {
+	user    uuid.UUID
+	expires time.Time
+}
+
+var errLoginFailed = &errkind.Error{Kind: errkind.Unauthorized, Message: "invalid user name or password"}
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+/*
+	dummyPassword() returns the hash checked for unknown users and users
+	without a password, so they take as long to reject as a wrong password
+*/
+func dummyPassword() []byte {
+	dummyOnce.Do(func() {
+		dummyHash, _ = crypto.HashPassword("goofy-coin dummy password")
+	})
+	return dummyHash
+}
+
+/*
+	newChallenge() issues a nonce for user valid for challengeTTL. The nonce
+	of an unknown user, uuid.Nil, is not kept since it can never be taken,
+	at most userChallenges of a user are pending so the map stays bounded
+	without one user's challenges locking out the others
+*/
+func (s *Server) newChallenge(user uuid.UUID) ([]byte, time.Time, error) {
+	nonce := make([]byte, challengeLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, time.Time{}, err
+	}
+	expires := time.Now().Add(challengeTTL)
+	if user == uuid.Nil {
+		return nonce, expires, nil
+	}
+
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	pending, oldest := 0, ""
+	for n, c := range s.challenges {
+		if time.Now().After(c.expires) {
+			delete(s.challenges, n)
+		} else if c.user == user {
+			pending++
+			if oldest == "" || c.expires.Before(s.challenges[oldest].expires) {
+				oldest = n
+			}
+		}
+	}
+	if pending >= userChallenges {
+		delete(s.challenges, oldest)
+	}
+	s.challenges[hex.EncodeToString(nonce)] = challenge{user: user, expires: expires}
+	return nonce, expires, nil
+}
+
+/*
+	takeChallenge() consumes nonce and reports whether it was issued to user
+	and has not expired
+*/
+func (s *Server) takeChallenge(user uuid.UUID, nonce []byte) bool {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	key := hex.EncodeToString(nonce)
+	c, ok := s.challenges[key]
+	delete(s.challenges, key)
+	return ok && user != uuid.Nil && c.user == user && time.Now().Before(c.expires)
+}
+
+/*
+	newSession() issues a token for user valid for ttl
+*/
+func (s *Server) newSession(user uuid.UUID, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+	expires := time.Now().Add(ttl)
+
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	for t, ss := range s.sessions {
+		if time.Now().After(ss.expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = session{user: user, expires: expires}
+	return token, expires, nil
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(auth, "Bearer ")
+}
+
+/*
+	currentUser() returns the user of the request's session token
+*/
+func (s *Server) currentUser(r *http.Request) (uuid.UUID, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return uuid.Nil, errkind.New(errkind.Unauthorized, "login required")
+	}
+
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	ss, ok := s.sessions[token]
+	if !ok || time.Now().After(ss.expires) {
+		delete(s.sessions, token)
+		return uuid.Nil, errkind.New(errkind.Unauthorized, "session expired or invalid")
+	}
+	return ss.user, nil
+}
+
+/*
+	writeSession() answers a successful login with a new session for u
+*/
+func (s *Server) writeSession(w http.ResponseWriter, u wallet.User, ttl time.Duration) {
+	token, expires, err := s.newSession(u.UUID, ttl)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{"token": token, "expires": expires, "user": u})
+}
+
+/*
+	challengeAPI issues a login challenge for a user name, unknown names get a
+	challenge too so the answer does not reveal which users exist
+*/
+func (s *Server) challengeAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.methodNotAllowed(w, r, "POST")
+		return
+	}
+	type payload struct {
+		UserName string `json:"userName"`
+	}
+	var data payload
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+
+	u, _ := s.ledger.UserByName(data.UserName)
+	nonce, expires, err := s.newChallenge(u.UUID)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{"challenge": ledger.HexBytes(nonce), "expires": expires})
+}
+
+/*
+	loginAPI exchanges a user name and either a password or a signature of
+	crypto.ChallengeDigest() by the user's key for a session token, key
+	sessions are short-lived
+*/
+func (s *Server) loginAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.methodNotAllowed(w, r, "POST")
+		return
+	}
+	type payload struct {
+		UserName  string          `json:"userName"`
+		Password  string          `json:"password"`
+		Challenge ledger.HexBytes `json:"challenge"`
+		R         string          `json:"r"`
+		S         string          `json:"s"`
+	}
+	var data payload
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+
+	u, err := s.ledger.UserByName(data.UserName)
+	if data.Challenge != nil {
+		taken := s.takeChallenge(u.UUID, data.Challenge)
+		sigR, sigS, sigErr := crypto.ParseSignature(data.R, data.S)
+		if !taken || err != nil || sigErr != nil || !crypto.Verify(u.PublicKey, crypto.ChallengeDigest(data.Challenge), sigR, sigS) {
+			s.apiLogger(w, errkind.New(errkind.Unauthorized, "invalid challenge or signature"))
+			return
+		}
+		s.writeSession(w, u, keySessionTTL)
+		return
+	}
+	hash := u.Password
+	if err != nil || len(hash) == 0 {
+		hash = dummyPassword()
+	}
+	if !crypto.CheckPassword(hash, data.Password) || err != nil || len(u.Password) == 0 {
+		s.apiLogger(w, errLoginFailed)
+		return
+	}
+	s.writeSession(w, u, sessionTTL)
+}
+
+/*
+	logoutAPI ends the session of the request's token
+*/
+func (s *Server) logoutAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.methodNotAllowed(w, r, "POST")
+		return
+	}
+	if _, err := s.currentUser(r); err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	s.sessionMu.Lock()
+	delete(s.sessions, bearerToken(r))
+	s.sessionMu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+func TestLogin(t *testing.T) {
+	s, _ := newTestServer(t)
+	_, err := s.ledger.RegisterUser("alice", nil, "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := apiRequest(s.loginAPI, "POST", "/api/login", "", `{"userName": "alice", "password": "wonderland"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login failed with %d", rec.Code)
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if rec := apiRequest(s.userAPI, "GET", "/api/user", res.Token, ""); rec.Code != http.StatusOK {
+		t.Errorf("session token rejected with %d", rec.Code)
+	}
+
+	if rec := apiRequest(s.logoutAPI, "POST", "/api/logout", res.Token, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("logout failed with %d", rec.Code)
+	}
+	if rec := apiRequest(s.userAPI, "GET", "/api/user", res.Token, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("token accepted after logout with %d", rec.Code)
+	}
+}
+
+func TestAuthDenials(t *testing.T) {
+	s, goofy := newTestServer(t)
+	alice, err := s.ledger.RegisterUser("alice", nil, "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := s.ledger.RegisterUser("bob", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceToken := s.token(t, alice)
+	expiredToken, _, err := s.newSession(alice.UUID, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		token   string
+		body    string
+		status  int
+	}{
+		{"list users without token", s.userAPI, "GET", "/api/user", "", "", http.StatusUnauthorized},
+		{"list users with unknown token", s.userAPI, "GET", "/api/user", "abc", "", http.StatusUnauthorized},
+		{"list users with expired token", s.userAPI, "GET", "/api/user", expiredToken, "", http.StatusUnauthorized},
+		{"list coins without token", s.coinAPI, "GET", "/api/coin", "", "", http.StatusUnauthorized},
+		{"balance without token", s.balanceAPI, "GET", "/api/balance?user=" + alice.UUID.String(), "", "", http.StatusUnauthorized},
+		{"login with wrong password", s.loginAPI, "POST", "/api/login", "", `{"userName": "alice", "password": "looking-glass"}`, http.StatusUnauthorized},
+		{"login without password set", s.loginAPI, "POST", "/api/login", "", `{"userName": "bob", "password": ""}`, http.StatusUnauthorized},
+		{"login unknown user", s.loginAPI, "POST", "/api/login", "", `{"userName": "carol", "password": "wonderland"}`, http.StatusUnauthorized},
+		{"logout without token", s.logoutAPI, "POST", "/api/logout", "", "", http.StatusUnauthorized},
+		{"mint without token", s.coinAPI, "POST", "/api/coin", "", `{"amount": 10}`, http.StatusUnauthorized},
+		{"mint by alice", s.coinAPI, "POST", "/api/coin", aliceToken, `{"amount": 10}`, http.StatusForbidden},
+		{"pay without token", s.txAPI, "POST", "/api/tx", "", `{"coin": "` + c.UUID.String() + `", "receiver": "` + bob.UUID.String() + `"}`, http.StatusUnauthorized},
+		{"alice spends goofy's coin", s.txAPI, "POST", "/api/tx", aliceToken, `{"coin": "` + c.UUID.String() + `", "receiver": "` + bob.UUID.String() + `"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := apiRequest(tt.handler, tt.method, tt.target, tt.token, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+	c, _ = s.ledger.Coin(c.UUID)
+	if c.Owner != goofy.UUID || len(s.ledger.Coins()) != 1 {
+		t.Error("denied request changed the ledger")
+	}
+
+	// the transactions of others are hidden
+	if rec := apiRequest(s.txAPI, "GET", "/api/tx?hash="+hex.EncodeToString(c.TxHash), aliceToken, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("alice read goofy's mint with %d", rec.Code)
+	}
+	if rec := apiRequest(s.txAPI, "GET", "/api/tx", aliceToken, ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("alice listed %s", rec.Body)
+	}
+	if rec := apiRequest(s.txAPI, "GET", "/api/tx?hash="+hex.EncodeToString(c.TxHash), s.token(t, goofy), ""); rec.Code != http.StatusOK {
+		t.Errorf("goofy read his mint with %d", rec.Code)
+	}
+}
+
+func TestChallengeLogin(t *testing.T) {
+	s, goofy := newTestServer(t)
+	priv, pub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ledger.RegisterUser("alice", pub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPriv, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func(key *ecdsa.PrivateKey) (string, *httptest.ResponseRecorder) {
+		rec := apiRequest(s.challengeAPI, "POST", "/api/login/challenge", "", `{"userName": "alice"}`)
+		var res struct {
+			Challenge ledger.HexBytes `json:"challenge"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		r, sig, err := crypto.Sign(key, crypto.ChallengeDigest(res.Challenge))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := `{"userName": "alice", "challenge": "` + hex.EncodeToString(res.Challenge) + `", "r": "` + r.Text(16) + `", "s": "` + sig.Text(16) + `"}`
+		return body, apiRequest(s.loginAPI, "POST", "/api/login", "", body)
+	}
+
+	body, rec := login(priv)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("challenge login failed with %d", rec.Code)
+	}
+	if rec := apiRequest(s.loginAPI, "POST", "/api/login", "", body); rec.Code != http.StatusUnauthorized {
+		t.Errorf("replayed challenge accepted with %d", rec.Code)
+	}
+	if _, rec := login(otherPriv); rec.Code != http.StatusUnauthorized {
+		t.Errorf("challenge signed by another key accepted with %d", rec.Code)
+	}
+
+	nonce, _, err := s.newChallenge(goofy.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.sessionMu.Lock()
+	c := s.challenges[hex.EncodeToString(nonce)]
+	c.expires = time.Now().Add(-time.Second)
+	s.challenges[hex.EncodeToString(nonce)] = c
+	s.sessionMu.Unlock()
+	if s.takeChallenge(goofy.UUID, nonce) {
+		t.Error("expired challenge accepted")
+	}
+
+	// unknown names get a challenge which is not kept
+	rec = apiRequest(s.challengeAPI, "POST", "/api/login/challenge", "", `{"userName": "nobody"}`)
+	if rec.Code != http.StatusOK || len(s.challenges) != 0 {
+		t.Errorf("challenge of an unknown user: %d, %d pending", rec.Code, len(s.challenges))
+	}
+
+	// a flood of challenges for goofy replaces its oldest and leaves alice's
+	alice, err := s.ledger.UserByName("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _, err := s.newChallenge(alice.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, _, err := s.newChallenge(goofy.UUID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.challenges) != userChallenges+1 {
+		t.Errorf("%d challenges pending", len(s.challenges))
+	}
+	if !s.takeChallenge(alice.UUID, kept) {
+		t.Error("flood of challenges for goofy dropped alice's")
+	}
+}
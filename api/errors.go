@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/de7ign/goofy-coin/errkind"
+)
+
+/*
+	apiError is the body of every failed API response
+*/
+type apiError struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+/*
+	errorStatus() returns the status, code and message reported for err,
+	errors of unknown kind are reported as internal without details
+*/
+func errorStatus(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, errkind.BadRequest):
+		return http.StatusBadRequest, "bad_request", err.Error()
+	case errors.Is(err, errkind.Unauthorized):
+		return http.StatusUnauthorized, "unauthorized", err.Error()
+	case errors.Is(err, errkind.Forbidden):
+		return http.StatusForbidden, "forbidden", err.Error()
+	case errors.Is(err, errkind.NotFound):
+		return http.StatusNotFound, "not_found", err.Error()
+	case errors.Is(err, errkind.DoubleSpend):
+		return http.StatusConflict, "double_spend", err.Error()
+	case errors.Is(err, errkind.Conflict):
+		return http.StatusConflict, "conflict", err.Error()
+	case errors.Is(err, errkind.Invalid):
+		return http.StatusUnprocessableEntity, "validation_failed", err.Error()
+	case errors.Is(err, errkind.Unavailable):
+		return http.StatusServiceUnavailable, "unavailable", err.Error()
+	}
+	return http.StatusInternalServerError, "internal", "internal server error"
+}
+
+/*
+	apiLogger writes err as an apiError with the status of its kind, err is
+	logged with the request by reqLogger or on its own outside of it
+*/
+func (s *Server) apiLogger(w http.ResponseWriter, err error) {
+	status, code, message := errorStatus(err)
+	if rec, ok := w.(*statusRecorder); ok {
+		rec.err = err
+	} else if status >= 500 {
+		s.logger.Error(err.Error())
+	} else {
+		s.logger.Debug(err.Error())
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	s.writeJSON(w, status, apiError{Code: code, Message: message})
+}
+
+/*
+	methodNotAllowed answers a request with a method the endpoint does not serve
+*/
+func (s *Server) methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	s.writeJSON(w, http.StatusMethodNotAllowed, apiError{Code: "method_not_allowed", Message: r.Method + " not allowed"})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		s.logger.Error("cannot encode response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(payload)
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Escrow
+	___________________________________________________________________________
+
+	The buyer opens an escrow for the seller with an arbiter, the ledger
+	holds the coin in their 2-of-3 multisig. A party settles it by signing
+	the multisig spend to the seller or back to the buyer, the second
+	signature on the same spend submits it
+*/
+
+/*
+	partyEscrow() returns the escrow id if user is one of its parties
+*/
+func (s *Server) partyEscrow(user uuid.UUID, id string) (ledger.Escrow, error) {
+	escrowID, err := uuid.FromString(id)
+	if err != nil {
+		return ledger.Escrow{}, errkind.Malformed(err)
+	}
+	e, err := s.ledger.Escrow(escrowID)
+	if err != nil {
+		return ledger.Escrow{}, err
+	}
+	if !e.HasParty(user) {
+		return ledger.Escrow{}, errkind.New(errkind.Forbidden, "escrow belongs to other users")
+	}
+	return e, nil
+}
+
+/*
+	settleSpend() returns the pending spend of the escrowed coin to
+	receiver, proposing it as user if there is none
+*/
+func (s *Server) settleSpend(user uuid.UUID, e ledger.Escrow, receiver uuid.UUID) (spend, error) {
+	c, err := s.ledger.Coin(e.Coin)
+	if err != nil {
+		return spend{}, err
+	}
+	s.spendMu.Lock()
+	for _, p := range s.spends {
+		if p.Coin == c.UUID && p.Receiver == receiver && p.Tx == nil && string(p.PrevHash) == string(c.TxHash) && time.Now().Before(p.expires) {
+			s.spendMu.Unlock()
+			return *p, nil
+		}
+	}
+	s.spendMu.Unlock()
+	return s.proposeSpend(user, c.UUID, receiver)
+}
+
+/*
+	escrowAPI opens an escrow of the caller's coin on POST, returns the
+	escrows the caller is a party of on GET
+*/
+func (s *Server) escrowAPI(w http.ResponseWriter, r *http.Request) {
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	if r.Method == "POST" {
+		type payload struct {
+			Seller  uuid.UUID `json:"seller"`
+			Arbiter uuid.UUID `json:"arbiter"`
+			Coin    uuid.UUID `json:"coin"`
+		}
+		var data payload
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+		e, err := s.ledger.OpenEscrow(uid, data.Seller, data.Arbiter, data.Coin)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, e)
+	} else if r.Method == "GET" {
+		s.writeJSON(w, http.StatusOK, s.ledger.EscrowsOf(uid))
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST")
+	}
+}
+
+/*
+	escrowIDAPI serves /api/escrow/{id} and its dispute and settle actions
+	to the parties of the escrow
+*/
+func (s *Server) escrowIDAPI(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/escrow/"), "/")
+	method := "POST"
+	if action == "" {
+		method = "GET"
+	} else if action != "dispute" && action != "settle" {
+		s.apiLogger(w, errkind.New(errkind.NotFound, "unknown escrow action "+action))
+		return
+	}
+	if r.Method != method {
+		s.methodNotAllowed(w, r, method)
+		return
+	}
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	e, err := s.partyEscrow(uid, id)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	switch action {
+	case "":
+		s.writeJSON(w, http.StatusOK, e)
+	case "dispute":
+		e, err = s.ledger.Dispute(uid, e.ID)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, e)
+	case "settle":
+		s.settleEscrow(w, r, uid, e)
+	}
+}
+
+/*
+	settleEscrow() adds the signature of user to the spend of the escrowed
+	coin to the seller or the buyer
+*/
+func (s *Server) settleEscrow(w http.ResponseWriter, r *http.Request, user uuid.UUID, e ledger.Escrow) {
+	type payload struct {
+		To string `json:"to"`
+		R  string `json:"r"`
+		S  string `json:"s"`
+	}
+	var data payload
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	var receiver uuid.UUID
+	switch data.To {
+	case "seller":
+		receiver = e.Seller
+	case "buyer":
+		receiver = e.Buyer
+	default:
+		s.apiLogger(w, errkind.Failed(`to must be "seller" or "buyer"`))
+		return
+	}
+	if !e.Open() {
+		s.apiLogger(w, errkind.New(errkind.Conflict, "escrow is "+string(e.State)))
+		return
+	}
+	sigR, sigS, err := parseSignature(data.R, data.S)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	p, err := s.settleSpend(user, e, receiver)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	p, err = s.signSpend(user, p.ID, sigR, sigS)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, p)
+}
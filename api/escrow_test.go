@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/de7ign/goofy-coin/wallet"
+)
+
+func TestEscrowSettle(t *testing.T) {
+	s, goofy := newTestServer(t)
+	var users []wallet.User
+	for _, name := range []string{"alice", "bob", "claire", "dave"} {
+		u, err := s.ledger.RegisterUser(name, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+	alice, bob, claire, dave := users[0], users[1], users[2], users[3]
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ledger.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	aliceToken, bobToken, claireToken := s.token(t, alice), s.token(t, bob), s.token(t, claire)
+	serve := s.Handler().ServeHTTP
+
+	rec := apiRequest(serve, "POST", "/api/escrow", aliceToken, `{"seller": "`+bob.UUID.String()+`", "arbiter": "`+claire.UUID.String()+`", "coin": "`+c.UUID.String()+`"}`)
+	var e ledger.Escrow
+	if err := json.Unmarshal(rec.Body.Bytes(), &e); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("open answered %d: %s", rec.Code, rec.Body)
+	}
+	path := "/api/escrow/" + e.ID.String()
+	settle := func(token, to string) *httptest.ResponseRecorder {
+		return apiRequest(serve, "POST", path+"/settle", token, `{"to": "`+to+`"}`)
+	}
+
+	if res := apiRequest(serve, "GET", path, s.token(t, dave), ""); res.Code != http.StatusForbidden {
+		t.Errorf("escrow read by a stranger answered %d", res.Code)
+	}
+	if res := apiRequest(serve, "GET", "/api/escrow/nope", aliceToken, ""); res.Code != http.StatusBadRequest {
+		t.Errorf("malformed id answered %d", res.Code)
+	}
+	if res := settle(aliceToken, "dave"); res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("settlement to dave answered %d: %s", res.Code, res.Body)
+	}
+	if res := apiRequest(serve, "POST", path+"/dispute", claireToken, ""); res.Code != http.StatusForbidden {
+		t.Errorf("dispute by the arbiter answered %d: %s", res.Code, res.Body)
+	}
+	if res := apiRequest(serve, "POST", path+"/dispute", aliceToken, ""); res.Code != http.StatusOK {
+		t.Fatalf("dispute answered %d: %s", res.Code, res.Body)
+	}
+
+	// buyer and seller signing the same spend do not settle a disputed escrow
+	if res := settle(bobToken, "seller"); res.Code != http.StatusOK {
+		t.Fatalf("bob's signature answered %d: %s", res.Code, res.Body)
+	}
+	if res := settle(aliceToken, "seller"); res.Code != http.StatusForbidden {
+		t.Errorf("settlement without the arbiter answered %d: %s", res.Code, res.Body)
+	}
+	if res := settle(claireToken, "buyer"); res.Code != http.StatusOK {
+		t.Fatalf("claire's signature answered %d: %s", res.Code, res.Body)
+	}
+	if res := settle(aliceToken, "buyer"); res.Code != http.StatusOK {
+		t.Fatalf("refund answered %d: %s", res.Code, res.Body)
+	}
+	if c, _ := s.ledger.Coin(c.UUID); c.Owner != alice.UUID {
+		t.Error("coin not refunded to alice")
+	}
+	rec = apiRequest(serve, "GET", path, bobToken, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil || e.State != ledger.EscrowRefunded {
+		t.Errorf("escrow is %s, %v", e.State, err)
+	}
+	if res := settle(bobToken, "seller"); res.Code != http.StatusConflict {
+		t.Errorf("settlement of a refunded escrow answered %d: %s", res.Code, res.Body)
+	}
+}
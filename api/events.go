@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Live Updates
+	___________________________________________________________________________
+
+	Every subscriber of /api/events receives the events of the ledger as
+	Server-Sent Events
+*/
+
+const (
+	subscriberBuffer = 64
+	heartbeat        = 15 * time.Second
+)
+
+/*
+	eventsAPI streams user, multisig, escrow, invoice, mint, transfer,
+	split, merge, block and reorg events, only the transactions the caller
+	may see, reorg is not published yet. EventSource cannot set headers, so
+	the session token may be passed as ?token=
+*/
+func (s *Server) eventsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		s.methodNotAllowed(w, r, "GET")
+		return
+	}
+	if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.apiLogger(w, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	ch, cancel := s.ledger.SubscribeAsync(subscriberBuffer)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e := <-ch:
+			if !s.visibleEvent(uid, e) {
+				continue
+			}
+			data, err := json.Marshal(e.Payload())
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type(), data)
+		}
+		flusher.Flush()
+	}
+}
+
+/*
+	visibleEvent() reports whether e may be streamed to user, transactions
+	are only streamed to those who may see them
+*/
+func (s *Server) visibleEvent(user uuid.UUID, e ledger.Event) bool {
+	switch e := e.(type) {
+	case ledger.CoinMinted:
+		return s.ledger.VisibleTo(user, e.Tx)
+	case ledger.CoinTransferred:
+		return s.ledger.VisibleTo(user, e.Tx)
+	case ledger.CoinsSplit:
+		return s.ledger.VisibleTo(user, e.Tx)
+	case ledger.CoinsMerged:
+		return s.ledger.VisibleTo(user, e.Tx)
+	}
+	return true
+}
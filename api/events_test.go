@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventStream(t *testing.T) {
+	s, goofy := newTestServer(t)
+	token := s.token(t, goofy)
+
+	srv := httptest.NewServer(http.HandlerFunc(s.eventsAPI))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "?token=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("stream opened with invalid token, status %d", res.StatusCode)
+	}
+
+	res, err = http.Get(srv.URL + "?token=" + token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", res.Header.Get("Content-Type"))
+	}
+
+	// the subscription exists once the headers are flushed
+	_, err = s.ledger.RegisterUser("alice", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ledger.SealBlock()
+
+	want := []string{"event: user", "event: mint", "event: block"}
+	scanner := bufio.NewScanner(res.Body)
+	for len(want) > 0 && scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			if line != want[0] {
+				t.Fatalf("got %q, want %q", line, want[0])
+			}
+			want = want[1:]
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("missing events %v", want)
+	}
+
+	// alice only sees her own transactions
+	alice, err := s.ledger.UserByName("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = http.Get(srv.URL + "?token=" + s.token(t, alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	s.ledger.Mint(goofy.UUID, 5)
+	c, _ := s.ledger.Mint(goofy.UUID, 7)
+	if _, err := s.ledger.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.ledger.SealBlock()
+	want = []string{"event: transfer", "event: block"}
+	scanner = bufio.NewScanner(res.Body)
+	for len(want) > 0 && scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			if line != want[0] {
+				t.Fatalf("alice got %q, want %q", line, want[0])
+			}
+			want = want[1:]
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+/*
+	Node Status
+	___________________________________________________________________________
+
+	The server listens right away while the ledger is replayed from the
+	journal and verified. Until then /healthz answers but /readyz and the
+	API, except /api/info, answer 503
+*/
+
+/*
+	consensusMode names how blocks are agreed on, goofy alone appends to
+	the chain
+*/
+const consensusMode = "centralized"
+
+/*
+	healthzAPI reports that the process is serving
+*/
+func (s *Server) healthzAPI(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+/*
+	readyzAPI reports whether the ledger is loaded, verified and journaled
+*/
+func (s *Server) readyzAPI(w http.ResponseWriter, r *http.Request) {
+	if err := s.notReady(); err != nil {
+		s.writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+/*
+	infoAPI returns the version, chain height and tip, goofy's public key,
+	total supply and consensus mode of the node
+*/
+func (s *Server) infoAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		s.methodNotAllowed(w, r, "GET")
+		return
+	}
+	var goofyKey string
+	if goofy, err := s.ledger.Goofy(); err == nil {
+		goofyKey, err = crypto.EncodePublicKey(goofy.PublicKey)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":        s.opts.Version,
+		"height":         s.ledger.Height(),
+		"tipHash":        ledger.HexBytes(s.ledger.TipHash()),
+		"goofyPublicKey": goofyKey,
+		"totalSupply":    s.ledger.TotalSupply(),
+		"consensus":      consensusMode,
+		"ready":          s.notReady() == nil,
+	})
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+func TestReadiness(t *testing.T) {
+	s, err := New(ledger.New(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rec := apiRequest(s.healthzAPI, "GET", "/healthz", "", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz answered %d", rec.Code)
+	}
+	rec = apiRequest(s.readyzAPI, "GET", "/readyz", "", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz answered %d before the ledger loaded", rec.Code)
+	}
+	rec = apiRequest(s.requireReady(s.chainVerifyAPI), "GET", "/api/chain/verify", "", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("API answered %d before the ledger loaded", rec.Code)
+	}
+
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Start(t.TempDir(), key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = apiRequest(s.readyzAPI, "GET", "/readyz", "", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("readyz answered %d after the ledger loaded", rec.Code)
+	}
+	rec = apiRequest(s.requireReady(s.chainVerifyAPI), "GET", "/api/chain/verify", "", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("API answered %d after the ledger loaded", rec.Code)
+	}
+
+	// the chain is verified again only once it grew
+	goofy, _ := s.ledger.Goofy()
+	s.ledger.Mint(goofy.UUID, 1)
+	verifications := func() uint64 {
+		s.metrics.mu.Lock()
+		defer s.metrics.mu.Unlock()
+		return s.metrics.verifyDuration.count
+	}
+	apiRequest(s.chainVerifyAPI, "GET", "/api/chain/verify", "", "")
+	n := verifications()
+	apiRequest(s.chainVerifyAPI, "GET", "/api/chain/verify", "", "")
+	if n == 0 || verifications() != n {
+		t.Errorf("unchanged chain verified again, %d signatures checked after %d", verifications(), n)
+	}
+	if s.verified == nil || !s.verified.Valid || s.verified.Length != 1 {
+		t.Errorf("verified %+v", s.verified)
+	}
+
+	// a closed journal takes the node out of service
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rec = apiRequest(s.readyzAPI, "GET", "/readyz", "", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz answered %d after the journal closed", rec.Code)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	s, goofy := newTestServer(t)
+	_, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := s.ledger.SealBlock()
+
+	rec := apiRequest(s.infoAPI, "GET", "/api/info", "", "")
+	var info struct {
+		Version        string          `json:"version"`
+		Height         int             `json:"height"`
+		TipHash        ledger.HexBytes `json:"tipHash"`
+		GoofyPublicKey string          `json:"goofyPublicKey"`
+		TotalSupply    int             `json:"totalSupply"`
+		Consensus      string          `json:"consensus"`
+		Ready          bool            `json:"ready"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.ParsePublicKey([]byte(info.GoofyPublicKey))
+	if err != nil || !pub.Equal(goofy.PublicKey) {
+		t.Errorf("goofy's public key not reported: %v", err)
+	}
+	if info.Version != "test" || info.Height != 1 || string(info.TipHash) != string(b.Hash) || info.TotalSupply != 10 || info.Consensus != "centralized" || !info.Ready {
+		t.Errorf("unexpected info %+v", info)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Invoices
+	___________________________________________________________________________
+
+	The caller issues invoices payable to itself, signed by the server with
+	the caller's key or by the caller, and pays invoices of others with
+	coins worth the amount
+*/
+
+/*
+	invoiceAPI issues an invoice to the caller on POST, returns the invoices
+	the caller issued or paid on GET
+*/
+func (s *Server) invoiceAPI(w http.ResponseWriter, r *http.Request) {
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	if r.Method == "POST" {
+		type payload struct {
+			ID      uuid.UUID `json:"id"`
+			Amount  int       `json:"amount"`
+			Memo    string    `json:"memo"`
+			Expires int64     `json:"expires"`
+			R       string    `json:"r"`
+			S       string    `json:"s"`
+		}
+		var data payload
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+		sigR, sigS, err := parseSignature(data.R, data.S)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		inv, err := s.ledger.CreateInvoice(ledger.Invoice{ID: data.ID, Recipient: uid, Amount: data.Amount, Memo: data.Memo, Expires: data.Expires, Signature: ledger.Signature{R: sigR, S: sigS}})
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, inv)
+	} else if r.Method == "GET" {
+		s.writeJSON(w, http.StatusOK, s.ledger.InvoicesOf(uid))
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST")
+	}
+}
+
+/*
+	invoiceIDAPI serves /api/invoice/{id} to anyone logged in, the invoice
+	is meant to be shared, and /api/invoice/{id}/pay
+*/
+func (s *Server) invoiceIDAPI(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/invoice/"), "/")
+	method := "POST"
+	if action == "" {
+		method = "GET"
+	} else if action != "pay" {
+		s.apiLogger(w, errkind.New(errkind.NotFound, "unknown invoice action "+action))
+		return
+	}
+	if r.Method != method {
+		s.methodNotAllowed(w, r, method)
+		return
+	}
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	invoiceID, err := uuid.FromString(id)
+	if err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	if action == "" {
+		inv, err := s.ledger.Invoice(invoiceID)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, inv)
+		return
+	}
+
+	type coin struct {
+		Coin uuid.UUID `json:"coin"`
+		R    string    `json:"r"`
+		S    string    `json:"s"`
+	}
+	type payload struct {
+		Coins []coin `json:"coins"`
+	}
+	var data payload
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	payments := make([]ledger.Payment, len(data.Coins))
+	for i, c := range data.Coins {
+		sigR, sigS, err := parseSignature(c.R, c.S)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		payments[i] = ledger.Payment{Coin: c.Coin, R: sigR, S: sigS}
+	}
+	inv, err := s.ledger.PayInvoice(uid, invoiceID, payments)
+	if err != nil {
+		s.rejectTx(err)
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, inv)
+}
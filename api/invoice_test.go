@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+func TestInvoicePaths(t *testing.T) {
+	s, goofy := newTestServer(t)
+	alice, err := s.ledger.RegisterUser("alice", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceToken, goofyToken := s.token(t, alice), s.token(t, goofy)
+	serve := s.Handler().ServeHTTP
+
+	rec := apiRequest(serve, "POST", "/api/invoice", aliceToken, `{"amount": 10, "memo": "rent"}`)
+	var inv ledger.Invoice
+	if err := json.Unmarshal(rec.Body.Bytes(), &inv); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("create answered %d: %s", rec.Code, rec.Body)
+	}
+	path := "/api/invoice/" + inv.ID.String()
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"unknown action", "POST", path + "/refund", "", http.StatusNotFound},
+		{"pay with GET", "GET", path + "/pay", "", http.StatusMethodNotAllowed},
+		{"malformed id", "GET", "/api/invoice/nope", "", http.StatusBadRequest},
+		{"malformed signature", "POST", path + "/pay", `{"coins": [{"coin": "` + c.UUID.String() + `", "r": "zz", "s": "1"}]}`, http.StatusBadRequest},
+		{"invalid signature", "POST", path + "/pay", `{"coins": [{"coin": "` + c.UUID.String() + `", "r": "1", "s": "1"}]}`, http.StatusUnprocessableEntity},
+		{"pay", "POST", path + "/pay", `{"coins": [{"coin": "` + c.UUID.String() + `"}]}`, http.StatusOK},
+		{"pay twice", "POST", path + "/pay", `{"coins": [{"coin": "` + c.UUID.String() + `"}]}`, http.StatusConflict},
+	}
+	for _, test := range tests {
+		if res := apiRequest(serve, test.method, test.path, goofyToken, test.body); res.Code != test.status {
+			t.Errorf("%s answered %d, want %d: %s", test.name, res.Code, test.status, res.Body)
+		}
+	}
+	rec = apiRequest(serve, "GET", path, goofyToken, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &inv); err != nil || inv.State != ledger.InvoicePaid {
+		t.Errorf("invoice is %s, %v", inv.State, err)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+/*
+	Logging
+	___________________________________________________________________________
+
+	Every request is logged once by reqLogger with its request ID to the
+	server's logger
+*/
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+const maxRequestIDLen = 64
+
+/*
+	requestID() returns the ID reqLogger gave the request of ctx
+*/
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+/*
+	validRequestID() reports whether a client supplied X-Request-ID can be
+	logged as is
+*/
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/*
+	statusRecorder keeps the status code, body size and API error written by
+	a handler, it flushes so /api/events can stream through it
+*/
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	err    error
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+/*
+	reqLogger logs the request ID, method, path, status, response size,
+	latency and remote address of every request, and the error apiLogger()
+	answered with. It keeps the client's X-Request-ID or generates one and
+	returns it in the response
+*/
+func (s *Server) reqLogger(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		} else if rec.status >= 400 {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if rec.err != nil {
+			attrs = append(attrs, slog.String("error", rec.err.Error()))
+		}
+		s.logger.LogAttrs(r.Context(), level, "request", attrs...)
+	}
+}
+
+/*
+	logEvent() logs new users, multisigs, escrow and invoice changes, and
+	mints, transfers, splits, merges and sealed blocks at debug level
+*/
+func (s *Server) logEvent(e ledger.Event) {
+	switch e := e.(type) {
+	case ledger.UserCreated:
+		s.logger.Info("user created", "user", e.User.UUID.String(), "name", e.User.Name)
+	case ledger.MultisigCreated:
+		s.logger.Info("multisig created", "multisig", e.Multisig.UUID.String(), "m", e.Multisig.M, "n", len(e.Multisig.Owners))
+	case ledger.EscrowChanged:
+		s.logger.Info("escrow "+string(e.Escrow.State), "escrow", e.Escrow.ID.String(), "coin", e.Escrow.Coin.String())
+	case ledger.InvoiceChanged:
+		s.logger.Info("invoice "+string(e.Invoice.State), "invoice", e.Invoice.ID.String(), "amount", e.Invoice.Amount)
+	case ledger.CoinMinted:
+		s.logger.Debug("coin minted", "coin", e.Coin.UUID.String(), "value", e.Coin.Value)
+	case ledger.CoinTransferred:
+		s.logger.Debug("coin transferred", "coin", e.Tx.CoinID.String(), "sender", e.Tx.Sender.String(), "receiver", e.Tx.Receiver.String())
+	case ledger.CoinsSplit:
+		s.logger.Debug("coin split", "coin", e.Tx.Inputs[0].Coin.String(), "owner", e.Tx.Sender.String(), "coins", len(e.Coins))
+	case ledger.CoinsMerged:
+		s.logger.Debug("coins merged", "coin", e.Coin.UUID.String(), "owner", e.Tx.Sender.String(), "coins", len(e.Tx.Inputs))
+	case ledger.BlockSealed:
+		s.logger.Debug("block sealed", "height", e.Block.Height, "txCount", len(e.Block.Tx))
+	}
+}
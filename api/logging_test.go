@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/de7ign/goofy-coin/wallet"
+)
+
+/*
+	captureLogs() returns a server logging to a buffer at level
+*/
+func captureLogs(t *testing.T, level slog.Level) (*Server, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	s, err := New(ledger.New(), Options{Logger: slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: level}))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, buf
+}
+
+func TestRequestLog(t *testing.T) {
+	s, buf := captureLogs(t, slog.LevelInfo)
+	var seen string
+	handler := s.reqLogger(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r.Context())
+		s.apiLogger(w, ledger.ErrCoinNotFound)
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/coin?id=x", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	handler(rec, req)
+	if seen != "abc-123" || rec.Header().Get("X-Request-ID") != "abc-123" {
+		t.Errorf("request ID not kept, handler saw %q, response has %q", seen, rec.Header().Get("X-Request-ID"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1:\n%s", len(lines), buf)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"level": "WARN", "msg": "request", "id": "abc-123", "method": "GET", "path": "/api/coin", "status": 404.0, "error": "coin not found"}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s: got %v, want %v", k, entry[k], v)
+		}
+	}
+	for _, k := range []string{"bytes", "latency_ms", "remote"} {
+		if _, ok := entry[k]; !ok {
+			t.Errorf("%s missing in %s", k, lines[0])
+		}
+	}
+
+	// a malformed client ID is replaced
+	buf.Reset()
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "two\nlines")
+	handler(rec, req)
+	if id := rec.Header().Get("X-Request-ID"); id == "" || strings.Contains(id, "\n") {
+		t.Errorf("unexpected request ID %q", id)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	s, buf := captureLogs(t, slog.LevelWarn)
+	handler := s.reqLogger(func(w http.ResponseWriter, r *http.Request) {
+		s.writeJSON(w, http.StatusOK, "ok")
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	s.logEvent(ledger.UserCreated{User: wallet.User{Name: "alice"}})
+	if buf.Len() != 0 {
+		t.Errorf("info messages logged at warn level:\n%s", buf)
+	}
+}
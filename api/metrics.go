@@ -0,0 +1,258 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+/*
+	Metrics
+	___________________________________________________________________________
+
+	/metrics exports counters, gauges and histograms in the Prometheus text
+	format. Ledger counters follow the ledger's events, HTTP metrics are
+	recorded per route by instrument()
+*/
+
+var (
+	latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	verifyBuckets  = []float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01}
+)
+
+/*
+	histogram counts observations into cumulative buckets
+*/
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+/*
+	write() prints the series of h with labels, e.g. route="/api/tx"
+*/
+func (h *histogram) write(w io.Writer, name, labels string) {
+	prefix, total := "", ""
+	if labels != "" {
+		prefix, total = labels+",", "{"+labels+"}"
+	}
+	for i, le := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%sle=%q} %d\n", name, prefix, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, total, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, total, h.count)
+}
+
+type requestKey struct {
+	route  string
+	method string
+	code   int
+}
+
+/*
+	metrics holds the counters of a server
+*/
+type metrics struct {
+	mu             sync.Mutex
+	usersCreated   int
+	coinsMinted    int
+	txAccepted     map[string]int
+	txRejected     map[string]int
+	httpRequests   map[requestKey]int
+	httpDuration   map[string]*histogram
+	verifyDuration *histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		txAccepted:     map[string]int{},
+		txRejected:     map[string]int{},
+		httpRequests:   map[requestKey]int{},
+		httpDuration:   map[string]*histogram{},
+		verifyDuration: newHistogram(verifyBuckets),
+	}
+}
+
+/*
+	countEvent() counts the events of the ledger
+*/
+func (s *Server) countEvent(e ledger.Event) {
+	m := s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch e.(type) {
+	case ledger.UserCreated:
+		m.usersCreated++
+	case ledger.CoinMinted:
+		m.coinsMinted++
+		m.txAccepted["mint"]++
+	case ledger.CoinTransferred:
+		m.txAccepted["transfer"]++
+	case ledger.CoinsSplit:
+		m.txAccepted["split"]++
+	case ledger.CoinsMerged:
+		m.txAccepted["merge"]++
+	}
+}
+
+/*
+	rejectTx() counts a mint or transfer refused by the ledger with err
+*/
+func (s *Server) rejectTx(err error) {
+	reason := ""
+	switch err {
+	case ledger.ErrSignatureRequired:
+		reason = "signature_required"
+	case ledger.ErrInvalidSignature:
+		reason = "invalid_signature"
+	default:
+		_, reason, _ = errorStatus(err)
+	}
+	s.metrics.mu.Lock()
+	s.metrics.txRejected[reason]++
+	s.metrics.mu.Unlock()
+}
+
+/*
+	observeVerify() records the duration of a signature verification
+*/
+func (s *Server) observeVerify(d time.Duration) {
+	s.metrics.mu.Lock()
+	s.metrics.verifyDuration.observe(d.Seconds())
+	s.metrics.mu.Unlock()
+}
+
+/*
+	instrument() counts the requests of route and their latency
+*/
+func (s *Server) instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		m := s.metrics
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.httpRequests[requestKey{route, r.Method, rec.status}]++
+		h, ok := m.httpDuration[route]
+		if !ok {
+			h = newHistogram(latencyBuckets)
+			m.httpDuration[route] = h
+		}
+		h.observe(time.Since(start).Seconds())
+	}
+}
+
+/*
+	metricsAPI writes every metric in the Prometheus text format
+*/
+func (s *Server) metricsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		s.methodNotAllowed(w, r, "GET")
+		return
+	}
+	blocks, txs, supply := s.ledger.Height(), s.ledger.Len(), s.ledger.TotalSupply()
+
+	m := s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP goofy_users_created_total Users created.")
+	fmt.Fprintln(w, "# TYPE goofy_users_created_total counter")
+	fmt.Fprintf(w, "goofy_users_created_total %d\n", m.usersCreated)
+	fmt.Fprintln(w, "# HELP goofy_coins_minted_total Coins minted by goofy.")
+	fmt.Fprintln(w, "# TYPE goofy_coins_minted_total counter")
+	fmt.Fprintf(w, "goofy_coins_minted_total %d\n", m.coinsMinted)
+
+	fmt.Fprintln(w, "# HELP goofy_transactions_accepted_total Transactions appended to the ledger.")
+	fmt.Fprintln(w, "# TYPE goofy_transactions_accepted_total counter")
+	for _, typ := range sortedKeys(m.txAccepted) {
+		fmt.Fprintf(w, "goofy_transactions_accepted_total{type=%q} %d\n", typ, m.txAccepted[typ])
+	}
+	fmt.Fprintln(w, "# HELP goofy_transactions_rejected_total Mints and transfers refused by the ledger.")
+	fmt.Fprintln(w, "# TYPE goofy_transactions_rejected_total counter")
+	for _, reason := range sortedKeys(m.txRejected) {
+		fmt.Fprintf(w, "goofy_transactions_rejected_total{reason=%q} %d\n", reason, m.txRejected[reason])
+	}
+
+	fmt.Fprintln(w, "# HELP goofy_chain_length Sealed blocks.")
+	fmt.Fprintln(w, "# TYPE goofy_chain_length gauge")
+	fmt.Fprintf(w, "goofy_chain_length %d\n", blocks)
+	fmt.Fprintln(w, "# HELP goofy_chain_transactions Transactions in sealed blocks and the open block.")
+	fmt.Fprintln(w, "# TYPE goofy_chain_transactions gauge")
+	fmt.Fprintf(w, "goofy_chain_transactions %d\n", txs)
+	fmt.Fprintln(w, "# HELP goofy_total_supply Sum of the value of all coins.")
+	fmt.Fprintln(w, "# TYPE goofy_total_supply gauge")
+	fmt.Fprintf(w, "goofy_total_supply %d\n", supply)
+
+	keys := make([]requestKey, 0, len(m.httpRequests))
+	for k := range m.httpRequests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	fmt.Fprintln(w, "# HELP goofy_http_requests_total HTTP requests by route, method and status code.")
+	fmt.Fprintln(w, "# TYPE goofy_http_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "goofy_http_requests_total{route=%q,method=%q,code=\"%d\"} %d\n", k.route, k.method, k.code, m.httpRequests[k])
+	}
+	routes := make([]string, 0, len(m.httpDuration))
+	for route := range m.httpDuration {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	fmt.Fprintln(w, "# HELP goofy_http_request_duration_seconds HTTP request latency by route.")
+	fmt.Fprintln(w, "# TYPE goofy_http_request_duration_seconds histogram")
+	for _, route := range routes {
+		m.httpDuration[route].write(w, "goofy_http_request_duration_seconds", fmt.Sprintf("route=%q", route))
+	}
+
+	fmt.Fprintln(w, "# HELP goofy_signature_verify_seconds ECDSA signature verification time.")
+	fmt.Fprintln(w, "# TYPE goofy_signature_verify_seconds histogram")
+	m.verifyDuration.write(w, "goofy_signature_verify_seconds", "")
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	s, goofy := newTestServer(t)
+	alice, err := s.ledger.RegisterUser("alice", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceToken := s.token(t, alice)
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ledger.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ledger.SealBlock()
+
+	tx := s.instrument("/api/tx", s.txAPI)
+	rec := apiRequest(tx, "POST", "/api/tx", aliceToken, `{"coin": "`+c.UUID.String()+`", "receiver": "`+alice.UUID.String()+`", "r": "1", "s": "1"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("forged transfer answered %d", rec.Code)
+	}
+	apiRequest(tx, "GET", "/api/tx", aliceToken, "")
+
+	rec = apiRequest(s.metricsAPI, "GET", "/metrics", "", "")
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	for _, line := range []string{
+		"goofy_users_created_total 2",
+		"goofy_coins_minted_total 1",
+		`goofy_transactions_accepted_total{type="mint"} 1`,
+		`goofy_transactions_accepted_total{type="transfer"} 1`,
+		`goofy_transactions_rejected_total{reason="invalid_signature"} 1`,
+		"goofy_chain_length 1",
+		"goofy_chain_transactions 2",
+		"goofy_total_supply 10",
+		`goofy_http_requests_total{route="/api/tx",method="GET",code="200"} 1`,
+		`goofy_http_requests_total{route="/api/tx",method="POST",code="422"} 1`,
+		`goofy_http_request_duration_seconds_bucket{route="/api/tx",le="+Inf"} 2`,
+		`goofy_http_request_duration_seconds_count{route="/api/tx"} 2`,
+		"# TYPE goofy_signature_verify_seconds histogram",
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("missing %s in\n%s", line, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Multisig Spends
+	___________________________________________________________________________
+
+	An owner of a multisig proposes to pass one of its coins to a receiver,
+	the owners sign the proposal one by one and the signature which completes
+	m of them submits the spend to the ledger. Proposals live in the server's
+	memory for spendTTL
+*/
+
+const spendTTL = 24 * time.Hour
+
+/*
+	spend is a proposed multisig spend collecting signatures, Tx is set once
+	it is submitted
+*/
+type spend struct {
+	ID       uuid.UUID           `json:"id"`
+	Multisig ledger.Multisig     `json:"multisig"`
+	Coin     uuid.UUID           `json:"coin"`
+	Receiver uuid.UUID           `json:"receiver"`
+	PrevHash ledger.HexBytes     `json:"prevHash"`
+	Signers  []uuid.UUID         `json:"signers"`
+	Tx       *ledger.Transaction `json:"tx,omitempty"`
+	sigs     []ledger.Signature
+	expires  time.Time
+}
+
+var errSpendNotFound = &errkind.Error{Kind: errkind.NotFound, Message: "spend not found"}
+
+/*
+	proposeSpend() starts collecting signatures for passing coinID from its
+	multisig to receiver, proposer must be an owner
+*/
+func (s *Server) proposeSpend(proposer uuid.UUID, coinID uuid.UUID, receiver uuid.UUID) (spend, error) {
+	c, err := s.ledger.Coin(coinID)
+	if err != nil {
+		return spend{}, err
+	}
+	ms, err := s.ledger.Multisig(c.Owner)
+	if err != nil {
+		return spend{}, errkind.New(errkind.Forbidden, "coin is not owned by a multisig")
+	}
+	if !ms.HasOwner(proposer) {
+		return spend{}, errkind.New(errkind.Forbidden, "only an owner of the multisig can propose a spend")
+	}
+	if _, err := s.ledger.AccountName(receiver); err != nil {
+		return spend{}, err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return spend{}, err
+	}
+	p := &spend{ID: id, Multisig: ms, Coin: c.UUID, Receiver: receiver, PrevHash: c.TxHash, Signers: []uuid.UUID{}, expires: time.Now().Add(spendTTL)}
+
+	s.spendMu.Lock()
+	defer s.spendMu.Unlock()
+	for id, p := range s.spends {
+		if time.Now().After(p.expires) {
+			delete(s.spends, id)
+		}
+	}
+	s.spends[p.ID] = p
+	return *p, nil
+}
+
+/*
+	pendingSpend() returns the unexpired proposal id if user owns its
+	multisig. The caller holds spendMu
+*/
+func (s *Server) pendingSpend(user uuid.UUID, id uuid.UUID) (*spend, error) {
+	p, ok := s.spends[id]
+	if !ok || time.Now().After(p.expires) {
+		return nil, errSpendNotFound
+	}
+	if !p.Multisig.HasOwner(user) {
+		return nil, errkind.New(errkind.Forbidden, "spend belongs to another multisig")
+	}
+	return p, nil
+}
+
+/*
+	signSpend() adds signer's signature of crypto.SpendDigest() to the
+	proposal id, if r and s are nil the server signs with the signer's key
+	when it holds it. A spend the ledger rejects is dropped
+*/
+func (s *Server) signSpend(signer uuid.UUID, id uuid.UUID, r, sigS *big.Int) (spend, error) {
+	s.spendMu.Lock()
+	defer s.spendMu.Unlock()
+
+	p, err := s.pendingSpend(signer, id)
+	if err != nil {
+		return spend{}, err
+	}
+	if p.Tx != nil {
+		return spend{}, errkind.New(errkind.Conflict, "spend already submitted")
+	}
+	for _, signed := range p.Signers {
+		if signed == signer {
+			return spend{}, errkind.New(errkind.Conflict, "already signed")
+		}
+	}
+	u, err := s.ledger.User(signer)
+	if err != nil {
+		return spend{}, err
+	}
+	digest := crypto.SpendDigest(p.Coin, p.Receiver, p.PrevHash)
+	if r == nil || sigS == nil {
+		if u.PrivateKey == nil {
+			return spend{}, ledger.ErrSignatureRequired
+		}
+		r, sigS, err = crypto.Sign(u.PrivateKey, digest)
+		if err != nil {
+			return spend{}, err
+		}
+	}
+	if !crypto.Verify(u.PublicKey, digest, r, sigS) {
+		return spend{}, ledger.ErrInvalidSignature
+	}
+	p.sigs = append(p.sigs, ledger.Signature{Signer: signer, R: r, S: sigS})
+	p.Signers = append(p.Signers, signer)
+	if len(p.sigs) < p.Multisig.M {
+		return *p, nil
+	}
+
+	Tx, err := s.ledger.SpendMultisig(p.Coin, p.Receiver, p.PrevHash, p.sigs)
+	if err != nil {
+		delete(s.spends, id)
+		s.rejectTx(err)
+		return spend{}, err
+	}
+	p.Tx = Tx
+	return *p, nil
+}
+
+/*
+	multisigAPI creates a multisig on POST, returns ?id= or the caller's
+	multisigs on GET
+*/
+func (s *Server) multisigAPI(w http.ResponseWriter, r *http.Request) {
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	if r.Method == "POST" {
+		type payload struct {
+			M      int         `json:"m"`
+			Owners []uuid.UUID `json:"owners"`
+		}
+		var data payload
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+		ms, err := s.ledger.CreateMultisig(data.M, data.Owners)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, ms)
+	} else if r.Method == "GET" {
+		if id := r.URL.Query().Get("id"); id != "" {
+			msID, err := uuid.FromString(id)
+			if err != nil {
+				s.apiLogger(w, errkind.Malformed(err))
+				return
+			}
+			ms, err := s.ledger.Multisig(msID)
+			if err != nil {
+				s.apiLogger(w, err)
+				return
+			}
+			s.writeJSON(w, http.StatusOK, ms)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, s.ledger.MultisigsOf(uid))
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST")
+	}
+}
+
+/*
+	multisigSpendAPI proposes a spend on POST, returns ?id= or the open
+	proposals of the caller's multisigs on GET
+*/
+func (s *Server) multisigSpendAPI(w http.ResponseWriter, r *http.Request) {
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	if r.Method == "POST" {
+		type payload struct {
+			Coin     uuid.UUID `json:"coin"`
+			Receiver uuid.UUID `json:"receiver"`
+		}
+		var data payload
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+		p, err := s.proposeSpend(uid, data.Coin, data.Receiver)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, p)
+	} else if r.Method == "GET" {
+		s.spendMu.Lock()
+		defer s.spendMu.Unlock()
+		if id := r.URL.Query().Get("id"); id != "" {
+			spendID, err := uuid.FromString(id)
+			if err != nil {
+				s.apiLogger(w, errkind.Malformed(err))
+				return
+			}
+			p, err := s.pendingSpend(uid, spendID)
+			if err != nil {
+				s.apiLogger(w, err)
+				return
+			}
+			s.writeJSON(w, http.StatusOK, p)
+			return
+		}
+		list := []spend{}
+		for _, p := range s.spends {
+			if p.Tx == nil && p.Multisig.HasOwner(uid) && time.Now().Before(p.expires) {
+				list = append(list, *p)
+			}
+		}
+		s.writeJSON(w, http.StatusOK, list)
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST")
+	}
+}
+
+/*
+	multisigSignAPI adds the caller's signature to a proposed spend
+*/
+func (s *Server) multisigSignAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.methodNotAllowed(w, r, "POST")
+		return
+	}
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	type payload struct {
+		Spend uuid.UUID `json:"spend"`
+		R     string    `json:"r"`
+		S     string    `json:"s"`
+	}
+	var data payload
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	sigR, sigS, err := parseSignature(data.R, data.S)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	p, err := s.signSpend(uid, data.Spend, sigR, sigS)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/wallet"
+	"github.com/gofrs/uuid"
+)
+
+func TestMultisigSpend(t *testing.T) {
+	s, goofy := newTestServer(t)
+	var users []wallet.User
+	for _, name := range []string{"alice", "bob", "claire", "dave"} {
+		u, err := s.ledger.RegisterUser(name, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+	alice, bob, claire, dave := users[0], users[1], users[2], users[3]
+	ms, err := s.ledger.CreateMultisig(2, []uuid.UUID{alice.UUID, bob.UUID, claire.UUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ledger.Transfer(goofy.UUID, c.UUID, ms.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	aliceToken, bobToken, daveToken := s.token(t, alice), s.token(t, bob), s.token(t, dave)
+
+	propose := func(token string) (*httptest.ResponseRecorder, spend) {
+		rec := apiRequest(s.multisigSpendAPI, "POST", "/api/multisig/spend", token, `{"coin": "`+c.UUID.String()+`", "receiver": "`+dave.UUID.String()+`"}`)
+		var p spend
+		json.Unmarshal(rec.Body.Bytes(), &p)
+		return rec, p
+	}
+	sign := func(token string, id uuid.UUID, sig string) *httptest.ResponseRecorder {
+		return apiRequest(s.multisigSignAPI, "POST", "/api/multisig/sign", token, `{"spend": "`+id.String()+`"`+sig+`}`)
+	}
+
+	if res, _ := propose(daveToken); res.Code != http.StatusForbidden {
+		t.Errorf("proposal by a stranger answered %d", res.Code)
+	}
+	res, first := propose(aliceToken)
+	if res.Code != http.StatusOK {
+		t.Fatalf("proposal answered %d: %s", res.Code, res.Body)
+	}
+	_, second := propose(bobToken)
+
+	if res := sign(daveToken, first.ID, ""); res.Code != http.StatusForbidden {
+		t.Errorf("signature by a stranger answered %d: %s", res.Code, res.Body)
+	}
+	if res := sign(aliceToken, first.ID, `, "r": "1", "s": "1"`); res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid signature answered %d: %s", res.Code, res.Body)
+	}
+	if res := sign(aliceToken, first.ID, ""); res.Code != http.StatusOK {
+		t.Fatalf("first signature answered %d: %s", res.Code, res.Body)
+	}
+	if res := sign(aliceToken, first.ID, ""); res.Code != http.StatusConflict {
+		t.Errorf("second signature by alice answered %d: %s", res.Code, res.Body)
+	}
+	rec := apiRequest(s.multisigSpendAPI, "GET", "/api/multisig/spend", bobToken, "")
+	var open []spend
+	if err := json.Unmarshal(rec.Body.Bytes(), &open); err != nil || len(open) != 2 {
+		t.Errorf("bob sees %d open spends, %v", len(open), err)
+	}
+	if res := sign(bobToken, first.ID, ""); res.Code != http.StatusOK {
+		t.Fatalf("completing signature answered %d: %s", res.Code, res.Body)
+	}
+	if c, _ := s.ledger.Coin(c.UUID); c.Owner != dave.UUID {
+		t.Error("coin not passed to dave")
+	}
+	if res := sign(s.token(t, claire), first.ID, ""); res.Code != http.StatusConflict {
+		t.Errorf("signature of a submitted spend answered %d: %s", res.Code, res.Body)
+	}
+
+	// the competing proposal for the coin is refused as double spend and
+	// dropped
+	sign(aliceToken, second.ID, "")
+	if res := sign(bobToken, second.ID, ""); res.Code != http.StatusConflict {
+		t.Errorf("stale spend answered %d: %s", res.Code, res.Body)
+	}
+	rec = apiRequest(s.multisigSpendAPI, "GET", "/api/multisig/spend?id="+second.ID.String(), bobToken, "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("rejected spend answered %d", rec.Code)
+	}
+	if err := s.ledger.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+	Package api serves a ledger over HTTP: the JSON API described by
+	assets/openapi.json, the dashboard, live events, webhooks, metrics and
+	the health endpoints of the node
+*/
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Options configures a Server
+*/
+type Options struct {
+	// Static holds the public/ and assets/ directories of the dashboard
+	Static fs.FS
+	// StaticDir, if set, serves the dashboard from disk instead, read on
+	// every request for development
+	StaticDir string
+	// Version is reported by /api/info
+	Version string
+	// Logger receives the request log, slog.Default() if nil
+	Logger *slog.Logger
+	// LocalWebhooks lets webhooks POST to loopback, link-local and private
+	// hosts, which are refused by default to keep the node off internal
+	// services
+	LocalWebhooks bool
+}
+
+/*
+	Server answers the API of one ledger, sessions, multisig spends,
+	webhooks and metrics live as long as the server
+*/
+type Server struct {
+	ledger      *ledger.Ledger
+	store       *ledger.Store
+	opts        Options
+	logger      *slog.Logger
+	assets      map[string]asset
+	ready       atomic.Bool
+	unsubscribe []func()
+	metrics     *metrics
+
+	sessionMu  sync.Mutex
+	sessions   map[string]session
+	challenges map[string]challenge
+
+	spendMu sync.Mutex
+	spends  map[uuid.UUID]*spend
+
+	verifyMu sync.Mutex
+	verified *chainStatus
+
+	webhookMu      sync.Mutex
+	webhooks       []*webhook
+	deliveries     []delivery
+	webhookCtx     context.Context
+	stopDeliveries context.CancelFunc
+	webhookWG      sync.WaitGroup
+	webhookBackoff time.Duration
+	webhookClient  *http.Client
+}
+
+/*
+	New() returns a server for l, it logs, counts and dispatches the events
+	of l until it is closed. The API answers 503 until Start() succeeds
+*/
+func New(l *ledger.Ledger, opts Options) (*Server, error) {
+	s := &Server{
+		ledger:         l,
+		opts:           opts,
+		logger:         opts.Logger,
+		assets:         map[string]asset{},
+		metrics:        newMetrics(),
+		sessions:       map[string]session{},
+		challenges:     map[string]challenge{},
+		spends:         map[uuid.UUID]*spend{},
+		webhookBackoff: time.Second,
+		webhookClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if !opts.LocalWebhooks {
+		s.webhookClient.Transport = externalTransport()
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	if opts.Static != nil {
+		var err error
+		s.assets, err = loadAssets(opts.Static)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.webhookCtx, s.stopDeliveries = context.WithCancel(context.Background())
+	l.OnVerify = s.observeVerify
+	s.unsubscribe = []func(){l.Subscribe(s.logEvent), l.Subscribe(s.countEvent), l.Subscribe(s.dispatch)}
+	return s, nil
+}
+
+/*
+	routes() maps every pattern served by Handler() to its handler, the API
+	routes are described by assets/openapi.json. A pattern ending in a slash
+	serves the spec paths with parameters below it
+*/
+func (s *Server) routes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/":                       s.indexHandler,
+		"/dashboard":              s.dashboardHandler,
+		"/healthz":                s.healthzAPI,
+		"/readyz":                 s.readyzAPI,
+		"/metrics":                s.metricsAPI,
+		"/api/openapi.json":       s.openAPIHandler,
+		"/api/info":               s.infoAPI,
+		"/api/login":              s.loginAPI,
+		"/api/login/challenge":    s.challengeAPI,
+		"/api/logout":             s.logoutAPI,
+		"/api/user":               s.userAPI,
+		"/api/coin":               s.coinAPI,
+		"/api/coin/":              s.coinIDAPI,
+		"/api/coin/split":         s.coinSplitAPI,
+		"/api/coin/merge":         s.coinMergeAPI,
+		"/api/tx":                 s.txAPI,
+		"/api/balance":            s.balanceAPI,
+		"/api/multisig":           s.multisigAPI,
+		"/api/multisig/spend":     s.multisigSpendAPI,
+		"/api/multisig/sign":      s.multisigSignAPI,
+		"/api/escrow":             s.escrowAPI,
+		"/api/escrow/":            s.escrowIDAPI,
+		"/api/invoice":            s.invoiceAPI,
+		"/api/invoice/":           s.invoiceIDAPI,
+		"/api/chain/verify":       s.chainVerifyAPI,
+		"/api/events":             s.eventsAPI,
+		"/api/webhook":            s.webhookAPI,
+		"/api/webhook/deliveries": s.webhookDeliveriesAPI,
+		"/js/":                    http.StripPrefix("/js/", s.assetDirHandler("assets/js")).ServeHTTP,
+		"/css/":                   http.StripPrefix("/css/", s.assetDirHandler("assets/css")).ServeHTTP,
+	}
+}
+
+/*
+	Handler() returns the handler of every route
+*/
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for pattern, h := range s.routes() {
+		mux.HandleFunc(pattern, s.handle(pattern, h))
+	}
+	return mux
+}
+
+/*
+	handle() wraps h of pattern with request logging and metrics, the API
+	except /api/info and /api/openapi.json waits for the ledger to be ready
+*/
+func (s *Server) handle(pattern string, h http.HandlerFunc) http.HandlerFunc {
+	if strings.HasPrefix(pattern, "/api/") && pattern != "/api/info" && pattern != "/api/openapi.json" {
+		h = s.requireReady(h)
+	}
+	return s.instrument(pattern, s.reqLogger(h))
+}
+
+/*
+	Start() replays the journal in dataDir, registers goofy with key and
+	verifies the chain, the server is ready if it succeeds
+*/
+func (s *Server) Start(dataDir string, key *ecdsa.PrivateKey, password string) error {
+	st, err := s.ledger.OpenStore(filepath.Join(dataDir, "ledger.jsonl"))
+	if err != nil {
+		return err
+	}
+	s.store = st
+
+	if err := s.ledger.AttachGoofy(key, password); err != nil {
+		return err
+	}
+	if err := s.ledger.VerifyChain(); err != nil {
+		return err
+	}
+	s.ready.Store(true)
+	s.logger.Info("ledger ready", "users", len(s.ledger.Users()), "height", s.ledger.Height())
+	return nil
+}
+
+/*
+	Close() aborts the webhook deliveries in flight, stops following the
+	ledger and closes its journal
+*/
+func (s *Server) Close() error {
+	s.stopWebhooks()
+	for _, unsubscribe := range s.unsubscribe {
+		unsubscribe()
+	}
+	s.unsubscribe = nil
+	if s.store == nil {
+		return nil
+	}
+	err := s.store.Close()
+	s.store = nil
+	return err
+}
+
+/*
+	notReady() returns why the node cannot serve the ledger, nil if it can
+*/
+func (s *Server) notReady() error {
+	if !s.ready.Load() {
+		return errkind.New(errkind.Unavailable, "ledger is loading")
+	}
+	if s.store == nil {
+		return errkind.New(errkind.Unavailable, "ledger is closed")
+	}
+	if err := s.store.Err(); err != nil {
+		return errkind.New(errkind.Unavailable, "ledger journal failed: "+err.Error())
+	}
+	return nil
+}
+
+/*
+	requireReady() answers 503 until the node is ready
+*/
+func (s *Server) requireReady(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.notReady(); err != nil {
+			w.Header().Set("Retry-After", "5")
+			s.apiLogger(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Split and Merge
+	___________________________________________________________________________
+
+	The caller splits one of its coins into coins of given values or merges
+	several of its coins into one, signed by the server with the caller's
+	key or by the caller
+*/
+
+/*
+	splitMerge is the response to a split or merge, the transaction and the
+	coins it created
+*/
+type splitMerge struct {
+	Tx    *ledger.Transaction `json:"tx"`
+	Coins []ledger.Coin       `json:"coins"`
+}
+
+/*
+	coinSplitAPI splits the coin of the caller into coins of values on POST
+*/
+func (s *Server) coinSplitAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.methodNotAllowed(w, r, "POST")
+		return
+	}
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	type payload struct {
+		Coin   uuid.UUID `json:"coin"`
+		Values []int     `json:"values"`
+		R      string    `json:"r"`
+		S      string    `json:"s"`
+	}
+	var data payload
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	sigR, sigS, err := parseSignature(data.R, data.S)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	Tx, coins, err := s.ledger.Split(uid, data.Coin, data.Values, sigR, sigS)
+	if err != nil {
+		s.rejectTx(err)
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, splitMerge{Tx, coins})
+}
+
+/*
+	coinMergeAPI merges coins of the caller into one on POST
+*/
+func (s *Server) coinMergeAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.methodNotAllowed(w, r, "POST")
+		return
+	}
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	type payload struct {
+		Coins []uuid.UUID `json:"coins"`
+		R     string      `json:"r"`
+		S     string      `json:"s"`
+	}
+	var data payload
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	sigR, sigS, err := parseSignature(data.R, data.S)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	Tx, c, err := s.ledger.Merge(uid, data.Coins, sigR, sigS)
+	if err != nil {
+		s.rejectTx(err)
+		s.apiLogger(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, splitMerge{Tx, []ledger.Coin{c}})
+}
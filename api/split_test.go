@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSplitMergePaths(t *testing.T) {
+	s, goofy := newTestServer(t)
+	alice, err := s.ledger.RegisterUser("alice", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.ledger.Mint(goofy.UUID, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceToken, goofyToken := s.token(t, alice), s.token(t, goofy)
+	serve := s.Handler().ServeHTTP
+	coin := `"` + c.UUID.String() + `"`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"split with GET", "GET", "/api/coin/split", goofyToken, "", http.StatusMethodNotAllowed},
+		{"split malformed", "POST", "/api/coin/split", goofyToken, `{"coin": ` + coin + `, "values": "3"}`, http.StatusBadRequest},
+		{"split value lost", "POST", "/api/coin/split", goofyToken, `{"coin": ` + coin + `, "values": [3, 6]}`, http.StatusUnprocessableEntity},
+		{"split of another user", "POST", "/api/coin/split", aliceToken, `{"coin": ` + coin + `, "values": [3, 7]}`, http.StatusForbidden},
+		{"split", "POST", "/api/coin/split", goofyToken, `{"coin": ` + coin + `, "values": [3, 7]}`, http.StatusOK},
+		{"split spent coin", "POST", "/api/coin/split", goofyToken, `{"coin": ` + coin + `, "values": [3, 7]}`, http.StatusNotFound},
+		{"merge one coin", "POST", "/api/coin/merge", goofyToken, `{"coins": ["` + other.UUID.String() + `"]}`, http.StatusUnprocessableEntity},
+		{"merge with PUT", "PUT", "/api/coin/merge", goofyToken, "", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		if res := apiRequest(serve, test.method, test.path, test.token, test.body); res.Code != test.status {
+			t.Errorf("%s answered %d, want %d: %s", test.name, res.Code, test.status, res.Body)
+		}
+	}
+	if b, _ := s.ledger.Balance(goofy.UUID); b.Balance != 15 || len(b.Coins) != 3 {
+		t.Errorf("goofy has %+v", b)
+	}
+}
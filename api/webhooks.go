@@ -0,0 +1,424 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Webhooks
+	___________________________________________________________________________
+
+	A webhook receives every published event of its types as a JSON POST,
+	optionally only events involving one user or one coin. Only goofy
+	subscribes to the events of every user, the webhooks of other users are
+	bound to their owner. Loopback, link-local and private hosts are refused
+	unless Options.LocalWebhooks is set, at registration and again when
+	dialing since a name may resolve to them. The body is signed with
+	HMAC-SHA256 using the secret returned at registration and sent as
+	"X-Goofy-Signature: sha256=<hex>". Failed deliveries are retried with
+	exponential backoff and every attempt is kept in the delivery log.
+	Events are delivered concurrently, so they may arrive out of order
+*/
+
+const (
+	webhookAttempts = 5
+	maxDeliveries   = 1000
+)
+
+var webhookEvents = map[string]bool{"user": true, "multisig": true, "escrow": true, "invoice": true, "mint": true, "transfer": true, "split": true, "merge": true, "block": true, "reorg": true}
+
+type webhook struct {
+	ID     uuid.UUID `json:"id"`
+	URL    string    `json:"url"`
+	Events []string  `json:"events"`
+	User   uuid.UUID `json:"user"`
+	Coin   uuid.UUID `json:"coin"`
+	Owner  uuid.UUID `json:"owner"`
+	secret []byte
+}
+
+/*
+	delivery is one attempt to deliver an event to a webhook
+*/
+type delivery struct {
+	Webhook uuid.UUID `json:"webhook"`
+	Event   uuid.UUID `json:"event"`
+	Type    string    `json:"type"`
+	Attempt int       `json:"attempt"`
+	Status  int       `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+/*
+	matches() reports whether e is of a type h wants and involves its user
+	and coin filters
+*/
+func (h *webhook) matches(e ledger.Event) bool {
+	wanted := false
+	for _, typ := range h.Events {
+		wanted = wanted || typ == e.Type()
+	}
+	if !wanted {
+		return false
+	}
+	switch e := e.(type) {
+	case ledger.CoinMinted:
+		return h.matchesTx(e.Tx)
+	case ledger.CoinTransferred:
+		return h.matchesTx(e.Tx)
+	case ledger.CoinsSplit:
+		return h.matchesTx(e.Tx)
+	case ledger.CoinsMerged:
+		return h.matchesTx(e.Tx)
+	case ledger.UserCreated:
+		return (h.User == uuid.Nil || e.User.UUID == h.User) && h.Coin == uuid.Nil
+	case ledger.MultisigCreated:
+		return (h.User == uuid.Nil || e.Multisig.HasOwner(h.User)) && h.Coin == uuid.Nil
+	case ledger.EscrowChanged:
+		return (h.User == uuid.Nil || e.Escrow.HasParty(h.User)) && (h.Coin == uuid.Nil || e.Escrow.Coin == h.Coin)
+	case ledger.InvoiceChanged:
+		payer := e.Invoice.Payer
+		return (h.User == uuid.Nil || e.Invoice.Recipient == h.User || (payer != nil && *payer == h.User)) && h.Coin == uuid.Nil
+	}
+	return h.User == uuid.Nil && h.Coin == uuid.Nil
+}
+
+func (h *webhook) matchesTx(Tx *ledger.Transaction) bool {
+	if h.User != uuid.Nil && Tx.Sender != h.User && Tx.Receiver != h.User {
+		return false
+	}
+	if h.Coin == uuid.Nil || Tx.CoinID == h.Coin {
+		return true
+	}
+	for _, in := range Tx.Inputs {
+		if in.Coin == h.Coin {
+			return true
+		}
+	}
+	for _, out := range Tx.Outputs {
+		if out.Coin == h.Coin {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	sign() returns the signature header value of body
+*/
+func (h *webhook) sign(body []byte) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+	dispatch() starts a delivery of e to every webhook matching it when it
+	was published, each delivery runs on its own so the order of events is
+	not kept
+*/
+func (s *Server) dispatch(e ledger.Event) {
+	s.webhookMu.Lock()
+	var targets []*webhook
+	for _, h := range s.webhooks {
+		if h.matches(e) {
+			targets = append(targets, h)
+		}
+	}
+	s.webhookMu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{"id": id, "type": e.Type(), "time": time.Now().UTC(), "data": e.Payload()})
+	if err != nil {
+		return
+	}
+	for _, h := range targets {
+		s.webhookWG.Add(1)
+		go s.deliver(h, id, e.Type(), body)
+	}
+}
+
+/*
+	deliver() posts body to h, retrying failures with exponential backoff
+	until stopWebhooks() is called
+*/
+func (s *Server) deliver(h *webhook, id uuid.UUID, typ string, body []byte) {
+	defer s.webhookWG.Done()
+	backoff := s.webhookBackoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		d := delivery{Webhook: h.ID, Event: id, Type: typ, Attempt: attempt, Time: time.Now()}
+		err := s.post(h, id, typ, body, &d)
+		if err != nil {
+			d.Error = err.Error()
+		}
+		s.logDelivery(d)
+		if err == nil {
+			return
+		}
+		if attempt < webhookAttempts {
+			select {
+			case <-s.webhookCtx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+}
+
+func (s *Server) post(h *webhook, id uuid.UUID, typ string, body []byte, d *delivery) error {
+	req, err := http.NewRequestWithContext(s.webhookCtx, "POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goofy-Event", typ)
+	req.Header.Set("X-Goofy-Delivery", id.String())
+	req.Header.Set("X-Goofy-Signature", h.sign(body))
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	d.Status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("receiver answered " + resp.Status)
+	}
+	return nil
+}
+
+/*
+	stopWebhooks() aborts the deliveries in flight and waits for them
+*/
+func (s *Server) stopWebhooks() {
+	s.stopDeliveries()
+	s.webhookWG.Wait()
+}
+
+func (s *Server) logDelivery(d delivery) {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	s.deliveries = append(s.deliveries, d)
+	if len(s.deliveries) > maxDeliveries {
+		s.deliveries = s.deliveries[len(s.deliveries)-maxDeliveries:]
+	}
+}
+
+/*
+	localHost() reports whether host is a loopback, link-local, private or
+	unspecified address
+*/
+func localHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified())
+}
+
+/*
+	externalTransport() returns a transport which refuses to connect to
+	local addresses, whatever name they were resolved from
+*/
+func externalTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if localHost(host) {
+				return errors.New("refusing to deliver to local address " + host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+/*
+	registerWebhook() validates and stores a webhook owned by owner, the
+	returned secret is not retrievable later. The webhooks of users other
+	than goofy only receive the events involving their owner
+*/
+func (s *Server) registerWebhook(owner uuid.UUID, rawURL string, events []string, userID, coinID uuid.UUID) (*webhook, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", errkind.Failed("url must be an absolute http or https URL")
+	}
+	if !s.opts.LocalWebhooks && localHost(u.Hostname()) {
+		return nil, "", errkind.Failed("url must not point to a loopback, link-local or private host")
+	}
+	goofy, err := s.ledger.Goofy()
+	if err != nil {
+		return nil, "", err
+	}
+	if owner != goofy.UUID {
+		if userID != uuid.Nil && userID != owner {
+			return nil, "", errkind.New(errkind.Forbidden, "only goofy may watch the events of another user")
+		}
+		userID = owner
+	}
+	if len(events) == 0 {
+		return nil, "", errkind.Failed("at least one event type is required")
+	}
+	for _, typ := range events {
+		if !webhookEvents[typ] {
+			return nil, "", errkind.Failed("unknown event type " + strconv.Quote(typ))
+		}
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	h := &webhook{ID: id, URL: u.String(), Events: events, User: userID, Coin: coinID, Owner: owner, secret: secret}
+
+	s.webhookMu.Lock()
+	s.webhooks = append(s.webhooks, h)
+	s.webhookMu.Unlock()
+	return h, hex.EncodeToString(secret), nil
+}
+
+/*
+	ownWebhook() returns the webhook with id if owner may manage it, goofy
+	manages every webhook. The caller holds webhookMu
+*/
+func (s *Server) ownWebhook(owner uuid.UUID, goofy uuid.UUID, id uuid.UUID) (int, *webhook, error) {
+	for i, h := range s.webhooks {
+		if h.ID == id {
+			if h.Owner != owner && owner != goofy {
+				return 0, nil, errkind.New(errkind.Forbidden, "webhook belongs to another user")
+			}
+			return i, h, nil
+		}
+	}
+	return 0, nil, errkind.New(errkind.NotFound, "webhook not found")
+}
+
+/*
+	webhookAPI registers webhooks on POST, lists the caller's on GET and
+	removes ?id= on DELETE
+*/
+func (s *Server) webhookAPI(w http.ResponseWriter, r *http.Request) {
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	if r.Method == "POST" {
+		type payload struct {
+			URL    string    `json:"url"`
+			Events []string  `json:"events"`
+			User   uuid.UUID `json:"user"`
+			Coin   uuid.UUID `json:"coin"`
+		}
+		var data payload
+		err = json.NewDecoder(r.Body).Decode(&data)
+		if err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+		h, secret, err := s.registerWebhook(uid, data.URL, data.Events, data.User, data.Coin)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{"webhook": h, "secret": secret})
+	} else if r.Method == "GET" {
+		s.webhookMu.Lock()
+		defer s.webhookMu.Unlock()
+		own := []*webhook{}
+		for _, h := range s.webhooks {
+			if h.Owner == uid {
+				own = append(own, h)
+			}
+		}
+		s.writeJSON(w, http.StatusOK, own)
+	} else if r.Method == "DELETE" {
+		id, err := uuid.FromString(r.URL.Query().Get("id"))
+		if err != nil {
+			s.apiLogger(w, errkind.Malformed(err))
+			return
+		}
+		goofy, _ := s.ledger.Goofy()
+		s.webhookMu.Lock()
+		defer s.webhookMu.Unlock()
+		i, _, err := s.ownWebhook(uid, goofy.UUID, id)
+		if err != nil {
+			s.apiLogger(w, err)
+			return
+		}
+		s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		s.methodNotAllowed(w, r, "GET", "POST", "DELETE")
+	}
+}
+
+/*
+	webhookDeliveriesAPI returns the delivery log of webhook ?id=
+*/
+func (s *Server) webhookDeliveriesAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		s.methodNotAllowed(w, r, "GET")
+		return
+	}
+	uid, err := s.currentUser(r)
+	if err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	id, err := uuid.FromString(r.URL.Query().Get("id"))
+	if err != nil {
+		s.apiLogger(w, errkind.Malformed(err))
+		return
+	}
+	goofy, _ := s.ledger.Goofy()
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	if _, _, err := s.ownWebhook(uid, goofy.UUID, id); err != nil {
+		s.apiLogger(w, err)
+		return
+	}
+	log := []delivery{}
+	for _, d := range s.deliveries {
+		if d.Webhook == id {
+			log = append(log, d)
+		}
+	}
+	s.writeJSON(w, http.StatusOK, log)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/de7ign/goofy-coin/wallet"
+	"github.com/gofrs/uuid"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	s, goofy := newTestServer(t)
+	s.webhookBackoff = time.Millisecond
+	alice, err := s.ledger.RegisterUser("alice", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := s.ledger.RegisterUser("bob", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceToken := s.token(t, alice)
+	bobToken := s.token(t, bob)
+
+	// the receiver fails the first attempt and checks the signature
+	received := make(chan map[string]interface{}, 10)
+	var secret string
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		key, _ := hex.DecodeString(secret)
+		mac := hmac.New(sha256.New, key)
+		mac.Write(body)
+		if r.Header.Get("X-Goofy-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var e map[string]interface{}
+		json.Unmarshal(body, &e)
+		received <- e
+	}))
+	defer receiver.Close()
+
+	rec := apiRequest(s.webhookAPI, "POST", "/api/webhook", aliceToken, `{"url": "`+receiver.URL+`", "events": ["transfer"], "user": "`+alice.UUID.String()+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register: %d %s", rec.Code, rec.Body)
+	}
+	var reg struct {
+		Webhook webhook `json:"webhook"`
+		Secret  string  `json:"secret"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &reg)
+	secret = reg.Secret
+
+	// only the transfer to alice matches the filter
+	c, err := s.ledger.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ledger.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-received:
+		data := e["data"].(map[string]interface{})
+		if e["type"] != "transfer" || data["receiver"] != alice.UUID.String() {
+			t.Errorf("unexpected event %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	// the attempt is logged after the receiver answers
+	var log []delivery
+	for wait := 0; wait < 100 && len(log) < 2; wait++ {
+		time.Sleep(10 * time.Millisecond)
+		rec = apiRequest(s.webhookDeliveriesAPI, "GET", "/api/webhook/deliveries?id="+reg.Webhook.ID.String(), aliceToken, "")
+		json.Unmarshal(rec.Body.Bytes(), &log)
+	}
+	if len(log) != 2 || log[0].Status != http.StatusServiceUnavailable || log[1].Status != http.StatusOK || log[1].Attempt != 2 {
+		t.Errorf("unexpected delivery log %+v", log)
+	}
+	rec = apiRequest(s.webhookDeliveriesAPI, "GET", "/api/webhook/deliveries?id="+reg.Webhook.ID.String(), bobToken, "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("bob read alice's delivery log, status %d", rec.Code)
+	}
+	rec = apiRequest(s.webhookAPI, "DELETE", "/api/webhook?id="+reg.Webhook.ID.String(), aliceToken, "")
+	if rec.Code != http.StatusNoContent || len(s.webhooks) != 0 {
+		t.Errorf("webhook not removed, status %d", rec.Code)
+	}
+}
+
+func TestWebhookFilter(t *testing.T) {
+	alice := uuid.Must(uuid.NewV4())
+	bob := uuid.Must(uuid.NewV4())
+	coin := uuid.Must(uuid.NewV4())
+	tx := &ledger.Transaction{Sender: alice, Receiver: bob, CoinID: coin}
+	split := &ledger.Transaction{Sender: alice, Receiver: alice, Inputs: []ledger.Input{{Coin: coin}}, Outputs: []ledger.Output{{Coin: uuid.Must(uuid.NewV4())}}}
+
+	tests := []struct {
+		name string
+		hook webhook
+		e    ledger.Event
+		want bool
+	}{
+		{"type", webhook{Events: []string{"transfer"}}, ledger.CoinTransferred{Tx: tx}, true},
+		{"other type", webhook{Events: []string{"mint"}}, ledger.CoinTransferred{Tx: tx}, false},
+		{"receiver", webhook{Events: []string{"transfer"}, User: bob}, ledger.CoinTransferred{Tx: tx}, true},
+		{"other user", webhook{Events: []string{"transfer"}, User: uuid.Must(uuid.NewV4())}, ledger.CoinTransferred{Tx: tx}, false},
+		{"coin", webhook{Events: []string{"transfer"}, Coin: coin}, ledger.CoinTransferred{Tx: tx}, true},
+		{"split coin", webhook{Events: []string{"split"}, Coin: coin}, ledger.CoinsSplit{Tx: split}, true},
+		{"split created coin", webhook{Events: []string{"split"}, Coin: split.Outputs[0].Coin}, ledger.CoinsSplit{Tx: split}, true},
+		{"split other user", webhook{Events: []string{"split"}, User: bob}, ledger.CoinsSplit{Tx: split}, false},
+		{"new user", webhook{Events: []string{"user"}, User: alice}, ledger.UserCreated{User: wallet.User{UUID: alice}}, true},
+		{"block with filter", webhook{Events: []string{"block"}, Coin: coin}, ledger.BlockSealed{Block: &ledger.Block{}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.hook.matches(tt.e); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	s, goofy := newTestServer(t)
+	_, _, err := s.registerWebhook(alice, "ftp://example.com", []string{"mint"}, uuid.Nil, uuid.Nil)
+	if err == nil || !strings.Contains(err.Error(), "url") {
+		t.Errorf("ftp url accepted: %v", err)
+	}
+	_, _, err = s.registerWebhook(alice, "http://example.com", []string{"burn"}, uuid.Nil, uuid.Nil)
+	if err == nil {
+		t.Error("unknown event type accepted")
+	}
+
+	// only goofy watches every user
+	h, _, err := s.registerWebhook(alice, "http://example.com", []string{"mint"}, uuid.Nil, coin)
+	if err != nil || h.User != alice {
+		t.Errorf("webhook of alice watches %v, %v", h, err)
+	}
+	_, _, err = s.registerWebhook(alice, "http://example.com", []string{"mint"}, bob, uuid.Nil)
+	if !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("webhook of alice on bob returned %v", err)
+	}
+	if h, _, err := s.registerWebhook(goofy.UUID, "http://example.com", []string{"mint"}, uuid.Nil, uuid.Nil); err != nil || h.User != uuid.Nil {
+		t.Errorf("webhook of goofy watches %v, %v", h, err)
+	}
+
+	// local hosts are refused unless allowed
+	s.opts.LocalWebhooks = false
+	for _, host := range []string{"localhost", "127.0.0.1", "[::1]", "169.254.169.254", "0.0.0.0", "api.localhost.", "10.0.0.1", "192.168.1.10", "172.16.0.5", "[fd00::1]"} {
+		if _, _, err := s.registerWebhook(goofy.UUID, "http://"+host+":8080/hook", []string{"mint"}, uuid.Nil, uuid.Nil); err == nil {
+			t.Errorf("webhook to %s accepted", host)
+		}
+	}
+	if _, err := (&http.Client{Transport: externalTransport()}).Get("http://127.0.0.1:1/"); err == nil || !strings.Contains(err.Error(), "local address") {
+		t.Errorf("delivery to a local address returned %v", err)
+	}
+}
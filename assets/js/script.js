@@ -23,14 +23,32 @@ function createUser() {
     document.getElementById("createUserError").innerText =
       "only numbers and alphabets are allowed";
   } else {
-    // send request to backend
+    // send request to backend, the private key never leaves the browser
     document.getElementById("createUserError").innerText = "";
-    let payload = {};
-    payload.userName = userName;
-
-    request("http://localhost:8080/user", payload)
+    let keyPair;
+    generateIdentityKey()
+      .then(keys => {
+        keyPair = keys;
+        return exportPublicKey(keys.publicKey);
+      })
+      .then(publicKey => {
+        let payload = {};
+        payload.userName = userName;
+        payload.publicKey = publicKey;
+        return request("/api/user", payload);
+      })
       .then(response => {
-        console.log(response);
+        if (response.status !== 200) {
+          document.getElementById("createUserError").innerText =
+            response.data.message;
+          return;
+        }
+        return crypto.subtle
+          .exportKey("jwk", keyPair.privateKey)
+          .then(jwk => {
+            saveIdentity(response.data.uuid, response.data.name, jwk);
+            loadIdentities();
+          });
       })
       .catch(err => {
         console.log(err);
@@ -38,6 +56,258 @@ function createUser() {
   }
 }
 
+/**
+ *  Identities
+ *
+ *  Users created in this browser keep their P-256 private key in
+ *  localStorage, selecting one in 'selectUser' logs in by signing a
+ *  challenge from the server with that key
+ */
+
+const identityStore = "goofyIdentities";
+
+/**
+ *  generateIdentityKey() generates an extractable ECDSA P-256 key pair
+ *
+ *  @returns {Promise<CryptoKeyPair>}
+ */
+function generateIdentityKey() {
+  return crypto.subtle.generateKey({ name: "ECDSA", namedCurve: "P-256" }, true, [
+    "sign",
+    "verify"
+  ]);
+}
+
+/**
+ *  exportPublicKey() encodes a public key as PEM for '/api/user'
+ *
+ *  @param {CryptoKey} publicKey
+ *
+ *  @returns {Promise<string>}
+ */
+function exportPublicKey(publicKey) {
+  return crypto.subtle.exportKey("spki", publicKey).then(spki => {
+    const b64 = btoa(String.fromCharCode(...new Uint8Array(spki)));
+    const lines = b64.match(/.{1,64}/g).join("\n");
+    return "-----BEGIN PUBLIC KEY-----\n" + lines + "\n-----END PUBLIC KEY-----\n";
+  });
+}
+
+/**
+ *  identities() returns the users whose keys this browser holds
+ *
+ *  @returns {Array<{uuid: string, name: string, jwk: Object}>}
+ */
+function identities() {
+  return JSON.parse(localStorage.getItem(identityStore) || "[]");
+}
+
+function saveIdentity(uuid, name, jwk) {
+  const list = identities();
+  list.push({ uuid: uuid, name: name, jwk: jwk });
+  localStorage.setItem(identityStore, JSON.stringify(list));
+}
+
+/**
+ *  loadIdentities() fills 'selectUser' with Goofy and the stored identities
+ */
+function loadIdentities() {
+  const sel = document.getElementById("selectUser");
+  sel.innerHTML = '<option value="0">Goofy</option>';
+  identities().forEach(identity => {
+    const option = document.createElement("option");
+    option.value = identity.uuid;
+    option.text = identity.name;
+    sel.add(option);
+  });
+}
+
+/**
+ *  selectUser() logs in as the user selected in 'selectUser', Goofy's key is
+ *  held by the server operator so Goofy logs in with a password
+ */
+function selectUser() {
+  const sel = document.getElementById("selectUser");
+  const name = sel.options[sel.selectedIndex].text;
+  document.getElementById("selectUserError").innerText = "";
+
+  let login;
+  if (sel.value === "0") {
+    login = request("/api/login", {
+      userName: "goofy",
+      password: prompt("Goofy's password")
+    });
+  } else {
+    const identity = identities().find(i => i.uuid === sel.value);
+    login = request("/api/login/challenge", { userName: name }).then(
+      response =>
+        signChallenge(identity.jwk, response.data.challenge).then(sig =>
+          request("/api/login", {
+            userName: name,
+            challenge: response.data.challenge,
+            r: sig.r,
+            s: sig.s
+          })
+        )
+    );
+  }
+  login
+    .then(response => {
+      if (response.status !== 200) {
+        sessionStorage.removeItem("goofyToken");
+        document.getElementById("selectUserError").innerText =
+          response.data.message;
+        return;
+      }
+      sessionStorage.setItem("goofyToken", response.data.token);
+      loadReceivers(response.data.user.uuid).then(() => {
+        loadTxTable();
+        subscribe(response.data.user.uuid);
+      });
+    })
+    .catch(err => {
+      console.log(err);
+    });
+}
+
+/**
+ *  signChallenge() signs "goofy-coin login:" followed by the challenge bytes,
+ *  WebCrypto hashes them with SHA-256 as the server's challengeDigest() does
+ *
+ *  @param {Object} jwk           private key of the identity
+ *  @param {string} challengeHex  challenge issued by the server
+ *
+ *  @returns {Promise<{r: string, s: string}>} hex encoded signature
+ */
+function signChallenge(jwk, challengeHex) {
+  const prefix = new TextEncoder().encode("goofy-coin login:");
+  const nonce = challengeHex.match(/../g).map(h => parseInt(h, 16));
+  const data = new Uint8Array([...prefix, ...nonce]);
+  return crypto.subtle
+    .importKey("jwk", jwk, { name: "ECDSA", namedCurve: "P-256" }, false, [
+      "sign"
+    ])
+    .then(key =>
+      crypto.subtle.sign({ name: "ECDSA", hash: "SHA-256" }, key, data)
+    )
+    .then(sig => {
+      const hex = Array.from(new Uint8Array(sig))
+        .map(b => b.toString(16).padStart(2, "0"))
+        .join("");
+      return { r: hex.slice(0, 64), s: hex.slice(64) };
+    });
+}
+
+/**
+ *  loadReceivers() fills 'receiverPkeySelect' with every user but the
+ *  logged in one
+ *
+ *  @param {string} self  uuid of the logged in user
+ */
+function loadReceivers(self) {
+  return get("/api/user")
+    .then(response => {
+      const sel = document.getElementById("receiverPkeySelect");
+      sel.innerHTML = '<option value="0">Select Receiver</option>';
+      response.data.forEach(user => addUser(user, self));
+    })
+    .catch(err => {
+      console.log(err);
+    });
+}
+
+/**
+ *  Live updates
+ *
+ *  After login the dashboard subscribes to '/api/events' and keeps the
+ *  user selects and 'txTable' up to date without reloading
+ */
+
+// userNames maps uuids to names for the sender and receiver columns
+const userNames = {};
+let events = null;
+
+/**
+ *  addUser() records a user and offers it as receiver unless it is self
+ *
+ *  @param {Object} user  user as returned by '/api/user'
+ *  @param {string} self  uuid of the logged in user
+ */
+function addUser(user, self) {
+  userNames[user.uuid] = user.name;
+  if (user.uuid === self) {
+    return;
+  }
+  const option = document.createElement("option");
+  option.value = user.uuid;
+  option.text = user.name;
+  document.getElementById("receiverPkeySelect").add(option);
+}
+
+/**
+ *  loadTxTable() fills 'txTable' with the transactions so far
+ */
+function loadTxTable() {
+  document.getElementById("txRows").innerHTML = "";
+  get("/api/tx")
+    .then(response => {
+      response.data.forEach(addTxRow);
+    })
+    .catch(err => {
+      console.log(err);
+    });
+}
+
+/**
+ *  addTxRow() appends a transaction to 'txTable'
+ *
+ *  @param {Object} tx  transaction as returned by '/api/tx'
+ */
+function addTxRow(tx) {
+  const rows = document.getElementById("txRows");
+  const row = rows.insertRow();
+  row.insertCell().innerText = rows.rows.length;
+  row.insertCell().innerText = new Date(tx.timeStamp * 1000).toLocaleString();
+  row.insertCell().innerText = userNames[tx.sender] || tx.sender;
+  row.insertCell().innerText = userNames[tx.receiver] || tx.receiver;
+  row.insertCell().innerText = formatMemo(tx.memo);
+}
+
+/**
+ *  formatMemo() returns the key=value pairs of a memo sorted by key
+ *
+ *  @param {Object} memo  memo of a transaction, if any
+ */
+function formatMemo(memo) {
+  return Object.keys(memo || {})
+    .sort()
+    .map(key => key + "=" + memo[key])
+    .join(", ");
+}
+
+/**
+ *  subscribe() opens the event stream, replacing an earlier one
+ *
+ *  @param {string} self  uuid of the logged in user
+ */
+function subscribe(self) {
+  if (events) {
+    events.close();
+  }
+  const token = sessionStorage.getItem("goofyToken");
+  events = new EventSource("/api/events?token=" + encodeURIComponent(token));
+  events.addEventListener("user", e => addUser(JSON.parse(e.data), self));
+  events.addEventListener("mint", e => addTxRow(JSON.parse(e.data)));
+  events.addEventListener("transfer", e => addTxRow(JSON.parse(e.data)));
+  events.addEventListener("split", e => addTxRow(JSON.parse(e.data)));
+  events.addEventListener("merge", e => addTxRow(JSON.parse(e.data)));
+  events.addEventListener("block", e => {
+    const block = JSON.parse(e.data);
+    document.getElementById("blockHeight").innerText =
+      "Block " + block.height + " sealed with " + block.txCount + " transactions";
+  });
+}
+
 /**
  *  createCoin() can only be called by 'goofy'.
  *
@@ -122,6 +392,14 @@ function createTx() {
   }
 }
 
+/**
+ *  authHeaders() returns the Authorization header of the current session
+ */
+function authHeaders() {
+  const token = sessionStorage.getItem("goofyToken");
+  return token ? { Authorization: "Bearer " + token } : {};
+}
+
 /**
  *  request() is a wrapper around axios post request call
  *
@@ -132,7 +410,25 @@ function createTx() {
  */
 function request(url, data) {
   return axios
-    .post(url, data)
+    .post(url, data, { headers: authHeaders() })
+    .then(function(response) {
+      return response;
+    })
+    .catch(function(error) {
+      return error.response;
+    });
+}
+
+/**
+ *  get() is a wrapper around axios get request call
+ *
+ *  @param {string} url   url where you want request to
+ *
+ *  @returns {pro} returns a promise for whichever callback is executed
+ */
+function get(url) {
+  return axios
+    .get(url, { headers: authHeaders() })
     .then(function(response) {
       return response;
     })
@@ -140,3 +436,7 @@ function request(url, data) {
       return error.response;
     });
 }
+
+if (document.getElementById("selectUser")) {
+  loadIdentities();
+}
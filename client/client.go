@@ -0,0 +1,1161 @@
+/*
+	Package client is a typed Go client of the goofy coin HTTP API, one
+	method per operation of the OpenAPI document served at /api/openapi.json
+
+		c := client.New("http://localhost:8080")
+		s, err := c.Login(ctx, "goofy", password)
+		coin, err := c.Mint(ctx, 10)
+
+	Hashes are hex strings as on the wire, failed requests return an *Error
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Client sends requests to the server at BaseURL, with Token as bearer
+	token when it is set
+*/
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+/*
+	New() returns a Client of the server at baseURL using http.DefaultClient
+*/
+func New(baseURL string) *Client {
+	return &Client{BaseURL: baseURL, HTTPClient: http.DefaultClient}
+}
+
+/*
+	Error is a response with a status of 300 or above, Code and Message are
+	the fields of the server's error body
+*/
+type Error struct {
+	Status  int
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	}
+	return e.Code + ": " + e.Message
+}
+
+/*
+	Schemas
+	___________________________________________________________________________
+*/
+
+type User struct {
+	UUID uuid.UUID `json:"uuid"`
+	Name string    `json:"name"`
+}
+
+type Coin struct {
+	UUID     uuid.UUID `json:"uuid"`
+	Value    int       `json:"value"`
+	Owner    uuid.UUID `json:"owner"`
+	TxHash   string    `json:"txHash"`
+	Lock     *Lock     `json:"lock,omitempty"`
+	HashLock *HashLock `json:"hashLock,omitempty"`
+	Script   string    `json:"script,omitempty"`
+}
+
+/*
+	Lock keeps the receiver of a coin from spending it before the chain has
+	Height sealed blocks and before the unix time Time
+*/
+type Lock struct {
+	Height int   `json:"height,omitempty"`
+	Time   int64 `json:"time,omitempty"`
+}
+
+/*
+	HashLock lets the receiver of a coin spend it with the SHA-256 preimage
+	of Hash, and Refund, its sender, take it back after Timeout
+*/
+type HashLock struct {
+	Hash    string    `json:"hash"`
+	Timeout Lock      `json:"timeout"`
+	Refund  uuid.UUID `json:"refund,omitempty"`
+}
+
+/*
+	Transaction mints a coin when CoinPrev is empty and passes it from Sender
+	to Receiver otherwise, Signatures replace R and S when Sender is a
+	multisig. A split or merge spends the coins of Inputs for those of
+	Outputs instead
+*/
+type Transaction struct {
+	TimeStamp  int64             `json:"timeStamp"`
+	Message    string            `json:"message"`
+	Coin       uuid.UUID         `json:"coin"`
+	Sender     uuid.UUID         `json:"sender"`
+	Receiver   uuid.UUID         `json:"receiver"`
+	Amount     int               `json:"amount"`
+	CoinPrev   string            `json:"coinPrevHash"`
+	PrevHash   string            `json:"prevHash"`
+	CurrHash   string            `json:"currHash"`
+	R          string            `json:"r"`
+	S          string            `json:"s"`
+	Signatures []Signature       `json:"signatures,omitempty"`
+	Lock       *Lock             `json:"lock,omitempty"`
+	HashLock   *HashLock         `json:"hashLock,omitempty"`
+	Preimage   string            `json:"preimage,omitempty"`
+	Script     string            `json:"script,omitempty"`
+	Unlock     string            `json:"unlock,omitempty"`
+	Memo       map[string]string `json:"memo,omitempty"`
+	Inputs     []Input           `json:"inputs,omitempty"`
+	Outputs    []Output          `json:"outputs,omitempty"`
+}
+
+type Input struct {
+	Coin     uuid.UUID `json:"coin"`
+	PrevHash string    `json:"prevHash"`
+}
+
+type Output struct {
+	Coin  uuid.UUID `json:"coin"`
+	Value int       `json:"value"`
+}
+
+type Signature struct {
+	Signer uuid.UUID `json:"signer"`
+	R      string    `json:"r"`
+	S      string    `json:"s"`
+}
+
+/*
+	Multisig is an account whose coins are spent with the signatures of M of
+	its Owners
+*/
+type Multisig struct {
+	UUID   uuid.UUID   `json:"uuid"`
+	M      int         `json:"m"`
+	Owners []uuid.UUID `json:"owners"`
+}
+
+/*
+	Spend is a proposal to pass Coin from Multisig to Receiver, Tx is set
+	once the owners in Signers complete it
+*/
+type Spend struct {
+	ID       uuid.UUID    `json:"id"`
+	Multisig Multisig     `json:"multisig"`
+	Coin     uuid.UUID    `json:"coin"`
+	Receiver uuid.UUID    `json:"receiver"`
+	PrevHash string       `json:"prevHash"`
+	Signers  []uuid.UUID  `json:"signers"`
+	Tx       *Transaction `json:"tx,omitempty"`
+}
+
+/*
+	Escrow holds Coin in the 2-of-3 Multisig of Buyer, Seller and Arbiter
+	until it is released to the seller or refunded to the buyer
+*/
+type Escrow struct {
+	ID         uuid.UUID `json:"id"`
+	Multisig   uuid.UUID `json:"multisig"`
+	Coin       uuid.UUID `json:"coin"`
+	Amount     int       `json:"amount"`
+	Buyer      uuid.UUID `json:"buyer"`
+	Seller     uuid.UUID `json:"seller"`
+	Arbiter    uuid.UUID `json:"arbiter"`
+	State      string    `json:"state"`
+	Funding    string    `json:"funding"`
+	Settlement string    `json:"settlement,omitempty"`
+}
+
+/*
+	Invoice asks for Amount to Recipient until the unix time Expires, URI
+	encodes its signed terms, see ParseInvoiceURI() and VerifyInvoice()
+*/
+type Invoice struct {
+	ID        uuid.UUID  `json:"id"`
+	Recipient uuid.UUID  `json:"recipient"`
+	PublicKey string     `json:"publicKey"`
+	Amount    int        `json:"amount"`
+	Memo      string     `json:"memo,omitempty"`
+	Expires   int64      `json:"expires"`
+	Signature Signature  `json:"signature"`
+	State     string     `json:"state"`
+	Payer     *uuid.UUID `json:"payer,omitempty"`
+	Payments  []string   `json:"payments,omitempty"`
+	URI       string     `json:"uri"`
+}
+
+type Balance struct {
+	User      uuid.UUID `json:"user"`
+	Balance   int       `json:"balance"`
+	Spendable int       `json:"spendable"`
+	Locked    int       `json:"locked"`
+	Coins     []Coin    `json:"coins"`
+}
+
+type ChainStatus struct {
+	Valid  bool   `json:"valid"`
+	Length int    `json:"length"`
+	Height int    `json:"height"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Info struct {
+	Version        string `json:"version"`
+	Height         int    `json:"height"`
+	TipHash        string `json:"tipHash"`
+	GoofyPublicKey string `json:"goofyPublicKey"`
+	TotalSupply    int    `json:"totalSupply"`
+	Consensus      string `json:"consensus"`
+	Ready          bool   `json:"ready"`
+}
+
+type Session struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+	User    User      `json:"user"`
+}
+
+type Challenge struct {
+	Challenge string    `json:"challenge"`
+	Expires   time.Time `json:"expires"`
+}
+
+/*
+	CreateUserRequest registers UserName with PublicKey, a PEM encoded PKIX
+	P-256 key, the server generates and holds the key when it is empty
+*/
+type CreateUserRequest struct {
+	UserName  string `json:"userName"`
+	PublicKey string `json:"publicKey,omitempty"`
+	Password  string `json:"password,omitempty"`
+}
+
+/*
+	TransferRequest passes Coin to Receiver, locked with Lock, HashLock or the
+	hex locking Script if one is set. Preimage claims a hash locked coin,
+	Unlock is the hex unlocking script of a scripted one. PrevHash, R and S
+	are left empty when the server holds the owner's key, see SignTransfer()
+*/
+type TransferRequest struct {
+	Coin     uuid.UUID         `json:"coin"`
+	Receiver uuid.UUID         `json:"receiver"`
+	PrevHash string            `json:"prevHash,omitempty"`
+	Lock     *Lock             `json:"lock,omitempty"`
+	HashLock *HashLock         `json:"hashLock,omitempty"`
+	Preimage string            `json:"preimage,omitempty"`
+	Script   string            `json:"script,omitempty"`
+	Unlock   string            `json:"unlock,omitempty"`
+	Memo     map[string]string `json:"memo,omitempty"`
+	R        string            `json:"r,omitempty"`
+	S        string            `json:"s,omitempty"`
+}
+
+/*
+	SplitRequest splits Coin into coins of Values, MergeRequest merges Coins
+	into one. R and S are left empty when the server holds the owner's key,
+	see SignSplit() and SignMerge()
+*/
+type SplitRequest struct {
+	Coin   uuid.UUID `json:"coin"`
+	Values []int     `json:"values"`
+	R      string    `json:"r,omitempty"`
+	S      string    `json:"s,omitempty"`
+}
+
+type MergeRequest struct {
+	Coins []uuid.UUID `json:"coins"`
+	R     string      `json:"r,omitempty"`
+	S     string      `json:"s,omitempty"`
+}
+
+/*
+	SplitMerge is the transaction of a split or merge and the coins it
+	created
+*/
+type SplitMerge struct {
+	Tx    Transaction `json:"tx"`
+	Coins []Coin      `json:"coins"`
+}
+
+/*
+	History leads from the mints to Coin, Keys holds the hex compressed
+	public key the server has for every sender and multisig owner, which
+	VerifyHistory() does not trust
+*/
+type History struct {
+	Coin      Coin                 `json:"coin"`
+	Hops      []Hop                `json:"hops"`
+	Keys      map[uuid.UUID]string `json:"keys"`
+	Multisigs []Multisig           `json:"multisigs"`
+}
+
+/*
+	Hop is a transaction of a coin's history appended at Height
+*/
+type Hop struct {
+	Height int         `json:"height"`
+	Tx     Transaction `json:"tx"`
+}
+
+/*
+	SignRequest signs the proposal Spend, R and S are left empty when the
+	server holds the signer's key, see SignSpend()
+*/
+type SignRequest struct {
+	Spend uuid.UUID `json:"spend"`
+	R     string    `json:"r,omitempty"`
+	S     string    `json:"s,omitempty"`
+}
+
+/*
+	SettleRequest signs the spend of an escrowed coin To "seller" or
+	"buyer", R and S are left empty when the server holds the signer's key,
+	see SignSettlement()
+*/
+type SettleRequest struct {
+	To string `json:"to"`
+	R  string `json:"r,omitempty"`
+	S  string `json:"s,omitempty"`
+}
+
+/*
+	InvoiceRequest issues an invoice for Amount to the caller. ID is
+	generated and Expires set a day ahead when they are zero, R and S are
+	left empty when the server holds the caller's key, see SignInvoice()
+*/
+type InvoiceRequest struct {
+	ID      uuid.UUID `json:"id"`
+	Amount  int       `json:"amount"`
+	Memo    string    `json:"memo,omitempty"`
+	Expires int64     `json:"expires,omitempty"`
+	R       string    `json:"r,omitempty"`
+	S       string    `json:"s,omitempty"`
+}
+
+/*
+	PayInvoiceRequest pays an invoice with Coins worth exactly its amount,
+	see SignInvoicePayment()
+*/
+type PayInvoiceRequest struct {
+	Coins []InvoiceCoin `json:"coins"`
+}
+
+type InvoiceCoin struct {
+	Coin uuid.UUID `json:"coin"`
+	R    string    `json:"r,omitempty"`
+	S    string    `json:"s,omitempty"`
+}
+
+/*
+	WebhookRequest subscribes URL to Events, User and Coin narrow it down to
+	events involving them unless they are uuid.Nil
+*/
+type WebhookRequest struct {
+	URL    string    `json:"url"`
+	Events []string  `json:"events"`
+	User   uuid.UUID `json:"user"`
+	Coin   uuid.UUID `json:"coin"`
+}
+
+type Webhook struct {
+	ID     uuid.UUID `json:"id"`
+	URL    string    `json:"url"`
+	Events []string  `json:"events"`
+	User   uuid.UUID `json:"user"`
+	Coin   uuid.UUID `json:"coin"`
+	Owner  uuid.UUID `json:"owner"`
+}
+
+/*
+	WebhookRegistration holds the secret which signs deliveries to the
+	webhook, it is only returned when registering
+*/
+type WebhookRegistration struct {
+	Webhook Webhook `json:"webhook"`
+	Secret  string  `json:"secret"`
+}
+
+type Delivery struct {
+	Webhook uuid.UUID `json:"webhook"`
+	Event   uuid.UUID `json:"event"`
+	Type    string    `json:"type"`
+	Attempt int       `json:"attempt"`
+	Status  int       `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+/*
+	Operations
+	___________________________________________________________________________
+*/
+
+func (c *Client) Info(ctx context.Context) (*Info, error) {
+	var info Info
+	if err := c.do(ctx, "GET", "/api/info", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+/*
+	Login() logs in with a password and keeps the session token in c.Token
+*/
+func (c *Client) Login(ctx context.Context, name, password string) (*Session, error) {
+	return c.login(ctx, map[string]string{"userName": name, "password": password})
+}
+
+func (c *Client) Challenge(ctx context.Context, name string) (*Challenge, error) {
+	var ch Challenge
+	if err := c.do(ctx, "POST", "/api/login/challenge", map[string]string{"userName": name}, &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+/*
+	LoginWithKey() signs a login challenge with the user's key and keeps the
+	session token in c.Token
+*/
+func (c *Client) LoginWithKey(ctx context.Context, name string, key *ecdsa.PrivateKey) (*Session, error) {
+	ch, err := c.Challenge(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(ch.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := crypto.Sign(key, crypto.ChallengeDigest(nonce))
+	if err != nil {
+		return nil, err
+	}
+	return c.login(ctx, map[string]string{"userName": name, "challenge": ch.Challenge, "r": r.Text(16), "s": s.Text(16)})
+}
+
+func (c *Client) login(ctx context.Context, req map[string]string) (*Session, error) {
+	var s Session
+	if err := c.do(ctx, "POST", "/api/login", req, &s); err != nil {
+		return nil, err
+	}
+	c.Token = s.Token
+	return &s, nil
+}
+
+/*
+	Logout() ends the session and clears c.Token
+*/
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, "POST", "/api/logout", nil, nil); err != nil {
+		return err
+	}
+	c.Token = ""
+	return nil
+}
+
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (*User, error) {
+	var u User
+	if err := c.do(ctx, "POST", "/api/user", req, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (c *Client) Users(ctx context.Context) ([]User, error) {
+	var users []User
+	err := c.do(ctx, "GET", "/api/user", nil, &users)
+	return users, err
+}
+
+/*
+	Mint() creates a coin of amount, only goofy may
+*/
+func (c *Client) Mint(ctx context.Context, amount int) (*Coin, error) {
+	var cn Coin
+	if err := c.do(ctx, "POST", "/api/coin", map[string]int{"amount": amount}, &cn); err != nil {
+		return nil, err
+	}
+	return &cn, nil
+}
+
+func (c *Client) Coin(ctx context.Context, id uuid.UUID) (*Coin, error) {
+	var cn Coin
+	if err := c.do(ctx, "GET", "/api/coin?id="+id.String(), nil, &cn); err != nil {
+		return nil, err
+	}
+	return &cn, nil
+}
+
+func (c *Client) Coins(ctx context.Context) ([]Coin, error) {
+	var coins []Coin
+	err := c.do(ctx, "GET", "/api/coin", nil, &coins)
+	return coins, err
+}
+
+func (c *Client) CoinsOf(ctx context.Context, owner uuid.UUID) ([]Coin, error) {
+	var coins []Coin
+	err := c.do(ctx, "GET", "/api/coin?owner="+owner.String(), nil, &coins)
+	return coins, err
+}
+
+/*
+	History() returns every hop of coin id from its mints, check it with
+	VerifyHistory()
+*/
+func (c *Client) History(ctx context.Context, id uuid.UUID) (*History, error) {
+	var h History
+	if err := c.do(ctx, "GET", "/api/coin/"+id.String()+"/history", nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (c *Client) Split(ctx context.Context, req SplitRequest) (*SplitMerge, error) {
+	var res SplitMerge
+	if err := c.do(ctx, "POST", "/api/coin/split", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) Merge(ctx context.Context, req MergeRequest) (*SplitMerge, error) {
+	var res SplitMerge
+	if err := c.do(ctx, "POST", "/api/coin/merge", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) Transfer(ctx context.Context, req TransferRequest) (*Transaction, error) {
+	var tx Transaction
+	if err := c.do(ctx, "POST", "/api/tx", req, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (c *Client) Transactions(ctx context.Context) ([]Transaction, error) {
+	var txs []Transaction
+	err := c.do(ctx, "GET", "/api/tx", nil, &txs)
+	return txs, err
+}
+
+/*
+	TransactionsWithMemo() returns the transactions the caller may see whose
+	memo has the key of query, "key" or "key=value", with the value if one
+	is given
+*/
+func (c *Client) TransactionsWithMemo(ctx context.Context, query string) ([]Transaction, error) {
+	var txs []Transaction
+	err := c.do(ctx, "GET", "/api/tx?memo="+url.QueryEscape(query), nil, &txs)
+	return txs, err
+}
+
+func (c *Client) Transaction(ctx context.Context, hash string) (*Transaction, error) {
+	var tx Transaction
+	if err := c.do(ctx, "GET", "/api/tx?hash="+url.QueryEscape(hash), nil, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (c *Client) Balance(ctx context.Context, user uuid.UUID) (*Balance, error) {
+	var b Balance
+	if err := c.do(ctx, "GET", "/api/balance?user="+user.String(), nil, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (c *Client) VerifyChain(ctx context.Context) (*ChainStatus, error) {
+	var st ChainStatus
+	if err := c.do(ctx, "GET", "/api/chain/verify", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (c *Client) CreateMultisig(ctx context.Context, m int, owners []uuid.UUID) (*Multisig, error) {
+	var ms Multisig
+	if err := c.do(ctx, "POST", "/api/multisig", map[string]interface{}{"m": m, "owners": owners}, &ms); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+func (c *Client) Multisig(ctx context.Context, id uuid.UUID) (*Multisig, error) {
+	var ms Multisig
+	if err := c.do(ctx, "GET", "/api/multisig?id="+id.String(), nil, &ms); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+/*
+	Multisigs() returns the multisigs the caller is an owner of
+*/
+func (c *Client) Multisigs(ctx context.Context) ([]Multisig, error) {
+	var list []Multisig
+	err := c.do(ctx, "GET", "/api/multisig", nil, &list)
+	return list, err
+}
+
+/*
+	ProposeSpend() starts collecting the owners' signatures for passing coin
+	from its multisig to receiver
+*/
+func (c *Client) ProposeSpend(ctx context.Context, coin, receiver uuid.UUID) (*Spend, error) {
+	var sp Spend
+	if err := c.do(ctx, "POST", "/api/multisig/spend", map[string]uuid.UUID{"coin": coin, "receiver": receiver}, &sp); err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
+func (c *Client) Spend(ctx context.Context, id uuid.UUID) (*Spend, error) {
+	var sp Spend
+	if err := c.do(ctx, "GET", "/api/multisig/spend?id="+id.String(), nil, &sp); err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
+/*
+	Spends() returns the open proposals of the caller's multisigs
+*/
+func (c *Client) Spends(ctx context.Context) ([]Spend, error) {
+	var list []Spend
+	err := c.do(ctx, "GET", "/api/multisig/spend", nil, &list)
+	return list, err
+}
+
+/*
+	Sign() adds the caller's signature to a proposed spend, the returned
+	Spend holds the transaction if it completed the spend
+*/
+func (c *Client) Sign(ctx context.Context, req SignRequest) (*Spend, error) {
+	var sp Spend
+	if err := c.do(ctx, "POST", "/api/multisig/sign", req, &sp); err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
+/*
+	OpenEscrow() holds coin of the caller for seller until two of the
+	caller, the seller and arbiter settle it
+*/
+func (c *Client) OpenEscrow(ctx context.Context, seller, arbiter, coin uuid.UUID) (*Escrow, error) {
+	var e Escrow
+	if err := c.do(ctx, "POST", "/api/escrow", map[string]uuid.UUID{"seller": seller, "arbiter": arbiter, "coin": coin}, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+/*
+	Escrows() returns the escrows the caller is a party of
+*/
+func (c *Client) Escrows(ctx context.Context) ([]Escrow, error) {
+	var list []Escrow
+	err := c.do(ctx, "GET", "/api/escrow", nil, &list)
+	return list, err
+}
+
+func (c *Client) Escrow(ctx context.Context, id uuid.UUID) (*Escrow, error) {
+	var e Escrow
+	if err := c.do(ctx, "GET", "/api/escrow/"+id.String(), nil, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Client) Dispute(ctx context.Context, id uuid.UUID) (*Escrow, error) {
+	var e Escrow
+	if err := c.do(ctx, "POST", "/api/escrow/"+id.String()+"/dispute", nil, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+/*
+	Settle() adds the caller's signature to the spend of the escrowed coin,
+	the returned Spend holds the transaction if it completed the spend
+*/
+func (c *Client) Settle(ctx context.Context, id uuid.UUID, req SettleRequest) (*Spend, error) {
+	var sp Spend
+	if err := c.do(ctx, "POST", "/api/escrow/"+id.String()+"/settle", req, &sp); err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
+func (c *Client) CreateInvoice(ctx context.Context, req InvoiceRequest) (*Invoice, error) {
+	var inv Invoice
+	if err := c.do(ctx, "POST", "/api/invoice", req, &inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+/*
+	Invoices() returns the invoices the caller issued or paid
+*/
+func (c *Client) Invoices(ctx context.Context) ([]Invoice, error) {
+	var list []Invoice
+	err := c.do(ctx, "GET", "/api/invoice", nil, &list)
+	return list, err
+}
+
+func (c *Client) Invoice(ctx context.Context, id uuid.UUID) (*Invoice, error) {
+	var inv Invoice
+	if err := c.do(ctx, "GET", "/api/invoice/"+id.String(), nil, &inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+/*
+	PayInvoice() passes the coins of req to the recipient of invoice id, the
+	returned invoice lists the hashes of the payments
+*/
+func (c *Client) PayInvoice(ctx context.Context, id uuid.UUID, req PayInvoiceRequest) (*Invoice, error) {
+	var inv Invoice
+	if err := c.do(ctx, "POST", "/api/invoice/"+id.String()+"/pay", req, &inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (c *Client) RegisterWebhook(ctx context.Context, req WebhookRequest) (*WebhookRegistration, error) {
+	var reg WebhookRegistration
+	if err := c.do(ctx, "POST", "/api/webhook", req, &reg); err != nil {
+		return nil, err
+	}
+	return &reg, nil
+}
+
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	err := c.do(ctx, "GET", "/api/webhook", nil, &hooks)
+	return hooks, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, "DELETE", "/api/webhook?id="+id.String(), nil, nil)
+}
+
+func (c *Client) Deliveries(ctx context.Context, id uuid.UUID) ([]Delivery, error) {
+	var log []Delivery
+	err := c.do(ctx, "GET", "/api/webhook/deliveries?id="+id.String(), nil, &log)
+	return log, err
+}
+
+/*
+	Signing
+	___________________________________________________________________________
+*/
+
+/*
+	SignTransfer() signs the transfer of cn to receiver with the owner's key,
+	the server rejects it as a double spend if cn moved in the meantime
+*/
+func SignTransfer(key *ecdsa.PrivateKey, cn *Coin, receiver uuid.UUID) (TransferRequest, error) {
+	return SignLockedTransfer(key, cn, receiver, Lock{})
+}
+
+/*
+	SignLockedTransfer() signs the transfer of cn to receiver locked with
+	lock, a zero lock signs an unlocked transfer
+*/
+func SignLockedTransfer(key *ecdsa.PrivateKey, cn *Coin, receiver uuid.UUID, lock Lock) (TransferRequest, error) {
+	req := TransferRequest{Coin: cn.UUID, Receiver: receiver, PrevHash: cn.TxHash}
+	if lock != (Lock{}) {
+		req.Lock = &lock
+	}
+	prevHash, err := hex.DecodeString(cn.TxHash)
+	if err != nil {
+		return req, err
+	}
+	r, s, err := crypto.Sign(key, crypto.LockedSpendDigest(cn.UUID, receiver, prevHash, lock.Height, lock.Time))
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r.Text(16), s.Text(16)
+	return req, nil
+}
+
+/*
+	SignHashLockedTransfer() signs the transfer of cn to receiver hash locked
+	with the SHA-256 hash of a preimage and refundable after timeout
+*/
+func SignHashLockedTransfer(key *ecdsa.PrivateKey, cn *Coin, receiver uuid.UUID, hash []byte, timeout Lock) (TransferRequest, error) {
+	req := TransferRequest{Coin: cn.UUID, Receiver: receiver, PrevHash: cn.TxHash, HashLock: &HashLock{Hash: hex.EncodeToString(hash), Timeout: timeout}}
+	prevHash, err := hex.DecodeString(cn.TxHash)
+	if err != nil {
+		return req, err
+	}
+	r, s, err := crypto.Sign(key, crypto.HashLockedSpendDigest(cn.UUID, receiver, prevHash, hash, timeout.Height, timeout.Time))
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r.Text(16), s.Text(16)
+	return req, nil
+}
+
+/*
+	SignScriptTransfer() signs the transfer of cn to receiver locked with the
+	locking script
+*/
+func SignScriptTransfer(key *ecdsa.PrivateKey, cn *Coin, receiver uuid.UUID, script []byte) (TransferRequest, error) {
+	req := TransferRequest{Coin: cn.UUID, Receiver: receiver, PrevHash: cn.TxHash, Script: hex.EncodeToString(script)}
+	prevHash, err := hex.DecodeString(cn.TxHash)
+	if err != nil {
+		return req, err
+	}
+	r, s, err := crypto.Sign(key, crypto.ScriptSpendDigest(cn.UUID, receiver, prevHash, script))
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r.Text(16), s.Text(16)
+	return req, nil
+}
+
+/*
+	SignTransferRequest() signs req, which passes cn with its locks and
+	memo, with the owner's key
+*/
+func SignTransferRequest(key *ecdsa.PrivateKey, cn *Coin, req TransferRequest) (TransferRequest, error) {
+	digest, err := transferDigest(cn, req)
+	if err != nil {
+		return req, err
+	}
+	r, s, err := crypto.Sign(key, digest)
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r.Text(16), s.Text(16)
+	return req, nil
+}
+
+/*
+	SignSplit() signs the split of cn into coins of values with the owner's
+	key
+*/
+func SignSplit(key *ecdsa.PrivateKey, cn *Coin, values []int) (SplitRequest, error) {
+	req := SplitRequest{Coin: cn.UUID, Values: values}
+	r, s, err := signSplitMerge(key, []Coin{*cn}, values)
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r, s
+	return req, nil
+}
+
+/*
+	SignMerge() signs the merge of coins into one with the owner's key
+*/
+func SignMerge(key *ecdsa.PrivateKey, coins []Coin) (MergeRequest, error) {
+	req := MergeRequest{}
+	total := 0
+	for _, cn := range coins {
+		req.Coins = append(req.Coins, cn.UUID)
+		total += cn.Value
+	}
+	r, s, err := signSplitMerge(key, coins, []int{total})
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r, s
+	return req, nil
+}
+
+func signSplitMerge(key *ecdsa.PrivateKey, coins []Coin, values []int) (string, string, error) {
+	ids := make([]uuid.UUID, len(coins))
+	prevHashes := make([][]byte, len(coins))
+	for i, cn := range coins {
+		prevHash, err := hex.DecodeString(cn.TxHash)
+		if err != nil {
+			return "", "", err
+		}
+		ids[i], prevHashes[i] = cn.UUID, prevHash
+	}
+	r, s, err := crypto.Sign(key, crypto.SplitMergeDigest(ids, prevHashes, values))
+	if err != nil {
+		return "", "", err
+	}
+	return r.Text(16), s.Text(16), nil
+}
+
+/*
+	UnlockSignature() returns the signature of key for the unlocking script
+	of req, which spends the scripted coin cn, in the format CHECKSIG checks.
+	req must be complete but for Unlock
+*/
+func UnlockSignature(key *ecdsa.PrivateKey, cn *Coin, req TransferRequest) ([]byte, error) {
+	digest, err := transferDigest(cn, req)
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := crypto.Sign(key, digest)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.SignatureBytes(r, s), nil
+}
+
+/*
+	transferDigest() returns the digest the owner of cn signs for req
+*/
+func transferDigest(cn *Coin, req TransferRequest) ([]byte, error) {
+	prevHash, err := hex.DecodeString(cn.TxHash)
+	if err != nil {
+		return nil, err
+	}
+	var digest []byte
+	switch {
+	case req.Script != "":
+		lock, err := hex.DecodeString(req.Script)
+		if err != nil {
+			return nil, err
+		}
+		digest = crypto.ScriptSpendDigest(cn.UUID, req.Receiver, prevHash, lock)
+	case req.HashLock != nil:
+		hash, err := hex.DecodeString(req.HashLock.Hash)
+		if err != nil {
+			return nil, err
+		}
+		digest = crypto.HashLockedSpendDigest(cn.UUID, req.Receiver, prevHash, hash, req.HashLock.Timeout.Height, req.HashLock.Timeout.Time)
+	case req.Lock != nil:
+		digest = crypto.LockedSpendDigest(cn.UUID, req.Receiver, prevHash, req.Lock.Height, req.Lock.Time)
+	default:
+		digest = crypto.SpendDigest(cn.UUID, req.Receiver, prevHash)
+	}
+	return crypto.MemoDigest(digest, req.Memo), nil
+}
+
+/*
+	NewPreimage() returns a random preimage and its SHA-256 hash for locking
+	coins, the preimage is kept secret until the coins are claimed
+*/
+func NewPreimage() (preimage []byte, hash []byte, err error) {
+	preimage = make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(preimage)
+	return preimage, sum[:], nil
+}
+
+/*
+	SignSpend() signs the proposal sp with the key of one of its owners
+*/
+func SignSpend(key *ecdsa.PrivateKey, sp *Spend) (SignRequest, error) {
+	req := SignRequest{Spend: sp.ID}
+	prevHash, err := hex.DecodeString(sp.PrevHash)
+	if err != nil {
+		return req, err
+	}
+	r, s, err := crypto.Sign(key, crypto.SpendDigest(sp.Coin, sp.Receiver, prevHash))
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r.Text(16), s.Text(16)
+	return req, nil
+}
+
+/*
+	SignSettlement() signs the spend of the escrowed coin cn to the seller
+	or the buyer of e with the key of one of its parties
+*/
+func SignSettlement(key *ecdsa.PrivateKey, e *Escrow, cn *Coin, to string) (SettleRequest, error) {
+	req := SettleRequest{To: to}
+	receiver := e.Buyer
+	if to == "seller" {
+		receiver = e.Seller
+	}
+	prevHash, err := hex.DecodeString(cn.TxHash)
+	if err != nil {
+		return req, err
+	}
+	r, s, err := crypto.Sign(key, crypto.SpendDigest(cn.UUID, receiver, prevHash))
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r.Text(16), s.Text(16)
+	return req, nil
+}
+
+/*
+	SignInvoice() signs req with the key of the user it is payable to,
+	filling in a zero ID and Expires first
+*/
+func SignInvoice(key *ecdsa.PrivateKey, req InvoiceRequest) (InvoiceRequest, error) {
+	if req.ID == uuid.Nil {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return req, err
+		}
+		req.ID = id
+	}
+	if req.Expires == 0 {
+		req.Expires = time.Now().Add(24 * time.Hour).Unix()
+	}
+	digest := crypto.InvoiceDigest(req.ID, crypto.CompressPublicKey(&key.PublicKey), req.Amount, req.Memo, req.Expires)
+	r, s, err := crypto.Sign(key, digest)
+	if err != nil {
+		return req, err
+	}
+	req.R, req.S = r.Text(16), s.Text(16)
+	return req, nil
+}
+
+/*
+	SignInvoicePayment() signs the transfers of coins to the recipient of
+	inv with the payer's key
+*/
+func SignInvoicePayment(key *ecdsa.PrivateKey, inv *Invoice, coins []Coin) (PayInvoiceRequest, error) {
+	req := PayInvoiceRequest{Coins: []InvoiceCoin{}}
+	for _, cn := range coins {
+		signed, err := SignTransfer(key, &cn, inv.Recipient)
+		if err != nil {
+			return req, err
+		}
+		req.Coins = append(req.Coins, InvoiceCoin{Coin: cn.UUID, R: signed.R, S: signed.S})
+	}
+	return req, nil
+}
+
+/*
+	ParseInvoiceURI() decodes the terms and signature of a goofy:invoice URI,
+	the state of the invoice is only known to the server
+*/
+func ParseInvoiceURI(uri string) (*Invoice, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "goofy" || u.Opaque != "invoice" {
+		return nil, fmt.Errorf("%s is not a goofy:invoice URI", uri)
+	}
+	q := u.Query()
+	inv := &Invoice{PublicKey: q.Get("key"), Memo: q.Get("memo"), URI: uri}
+	if inv.ID, err = uuid.FromString(q.Get("id")); err != nil {
+		return nil, fmt.Errorf("invoice id: %v", err)
+	}
+	if inv.Recipient, err = uuid.FromString(q.Get("to")); err != nil {
+		return nil, fmt.Errorf("invoice recipient: %v", err)
+	}
+	if inv.Amount, err = strconv.Atoi(q.Get("amount")); err != nil {
+		return nil, fmt.Errorf("invoice amount: %v", err)
+	}
+	if inv.Expires, err = strconv.ParseInt(q.Get("expires"), 10, 64); err != nil {
+		return nil, fmt.Errorf("invoice expiry: %v", err)
+	}
+	sig, err := hex.DecodeString(q.Get("sig"))
+	if err != nil {
+		return nil, fmt.Errorf("invoice signature: %v", err)
+	}
+	r, s, err := crypto.SplitSignature(sig)
+	if err != nil {
+		return nil, fmt.Errorf("invoice signature: %v", err)
+	}
+	inv.Signature = Signature{Signer: inv.Recipient, R: r.Text(16), S: s.Text(16)}
+	return inv, nil
+}
+
+/*
+	VerifyInvoice() checks that inv is signed with its PublicKey, whether the
+	key is the recipient's is up to the payer
+*/
+func VerifyInvoice(inv *Invoice) error {
+	key, err := hex.DecodeString(inv.PublicKey)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.DecompressPublicKey(key)
+	if err != nil {
+		return err
+	}
+	r, s, err := crypto.ParseSignature(inv.Signature.R, inv.Signature.S)
+	if err != nil {
+		return err
+	}
+	if !crypto.Verify(pub, crypto.InvoiceDigest(inv.ID, key, inv.Amount, inv.Memo, inv.Expires), r, s) {
+		return fmt.Errorf("invoice %s has an invalid signature", inv.ID)
+	}
+	return nil
+}
+
+/*
+	Utilities
+	___________________________________________________________________________
+*/
+
+/*
+	do() sends req as JSON and decodes the response into res
+*/
+func (c *Client) do(ctx context.Context, method, path string, req interface{}, res interface{}) error {
+	var body io.Reader
+	if req != nil {
+		payload, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	r, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if req != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &Error{Status: resp.StatusCode}
+		json.Unmarshal(payload, apiErr)
+		return apiErr
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(payload, res)
+}
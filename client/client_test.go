@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/logout" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "unauthorized", "message": "invalid user name or password"}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	_, err := c.Login(context.Background(), "alice", "looking-glass")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Code != "unauthorized" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if c.Token != "" {
+		t.Error("token kept after a failed login")
+	}
+	err = c.Logout(context.Background())
+	if !errors.As(err, &apiErr) || apiErr.Error() != "502 Bad Gateway" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSessionToken(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" {
+			w.Write([]byte(`{"token": "t0k3n", "expires": "2030-01-01T00:00:00Z", "user": {"name": "alice"}}`))
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	s, err := c.Login(context.Background(), "alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.User.Name != "alice" || c.Token != "t0k3n" {
+		t.Errorf("unexpected session %+v", s)
+	}
+	users, err := c.Users(context.Background())
+	if err != nil || users == nil {
+		t.Fatalf("users %v, %v", users, err)
+	}
+	if auth != "Bearer t0k3n" {
+		t.Errorf("request sent with Authorization %q", auth)
+	}
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/script"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Provenance
+	___________________________________________________________________________
+
+	VerifyHistory() checks the history of a coin without trusting the
+	server which sent it. It recomputes the hash of every hop and checks
+	its signatures, the coin links, the locks and scripts it spent and the
+	value of splits and merges, the way the ledger checks its chain. The
+	caller pins goofy's key, which signs every mint, and the keys of the
+	other senders it trusts. The keys sent with the history are not used,
+	a server cannot vouch for the users it relays. The owners and threshold
+	of a multisig still come with the history, so a multisig spend is only
+	known to be signed by trusted owners
+*/
+
+/*
+	VerifyHistory() checks every hop of h, minted with goofy's key and sent
+	by users with a key in keys, and that the hops end in the coin of h. The
+	sender of goofy's mints is trusted with goofy's key. It returns why the
+	first invalid hop is invalid
+*/
+func VerifyHistory(h *History, goofy *ecdsa.PublicKey, keys map[uuid.UUID]*ecdsa.PublicKey) error {
+	trusted := map[uuid.UUID]*ecdsa.PublicKey{}
+	for id, key := range keys {
+		trusted[id] = key
+	}
+	coins := map[uuid.UUID]*Coin{}
+	height := 0
+	for i, hop := range h.Hops {
+		if hop.Height < height {
+			return fmt.Errorf("hop %d is out of chain order", i)
+		}
+		height = hop.Height
+		if err := h.verifyHop(hop, goofy, trusted, coins); err != nil {
+			return fmt.Errorf("hop %d, transaction %s: %w", i, hop.Tx.CurrHash, err)
+		}
+	}
+	c, ok := coins[h.Coin.UUID]
+	if !ok {
+		return fmt.Errorf("hops do not lead to coin %s", h.Coin.UUID)
+	}
+	if c.Owner != h.Coin.Owner || c.Value != h.Coin.Value || c.TxHash != h.Coin.TxHash || c.Script != h.Coin.Script {
+		return fmt.Errorf("hops lead to %d goofy coins of %s at %s, not to coin %s", c.Value, c.Owner, c.TxHash, h.Coin.UUID)
+	}
+	return nil
+}
+
+/*
+	verifyHop() checks the Tx of hop against coins as the hops before it
+	left them and moves them on, a mint adds its sender to keys
+*/
+func (h *History) verifyHop(hop Hop, goofy *ecdsa.PublicKey, keys map[uuid.UUID]*ecdsa.PublicKey, coins map[uuid.UUID]*Coin) error {
+	Tx := &hop.Tx
+	hash, err := txHash(Tx)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash) != Tx.CurrHash {
+		return errors.New("hash mismatch")
+	}
+	digest, err := spendDigest(Tx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(Tx.Inputs) > 0:
+		if err := splitMerge(Tx, hop.Height, coins); err != nil {
+			return err
+		}
+	case Tx.CoinPrev == "":
+		if Tx.Sender != Tx.Receiver || coins[Tx.Coin] != nil {
+			return errors.New("malformed mint")
+		}
+		coins[Tx.Coin] = &Coin{UUID: Tx.Coin, Value: Tx.Amount, Owner: Tx.Receiver, TxHash: Tx.CurrHash}
+		if err := verifySignature(goofy, digest, Tx.R, Tx.S); err != nil {
+			return fmt.Errorf("mint not signed by goofy: %w", err)
+		}
+		if _, ok := keys[Tx.Sender]; !ok {
+			keys[Tx.Sender] = goofy
+		}
+		return nil
+	default:
+		c, ok := coins[Tx.Coin]
+		if !ok || c.TxHash != Tx.CoinPrev {
+			return errors.New("broken coin link")
+		}
+		if Tx.Amount != c.Value {
+			return errors.New("changes the value of its coin")
+		}
+		if err := spendable(c, Tx, hop.Height, digest); err != nil {
+			return fmt.Errorf("spends a coin it may not: %w", err)
+		}
+		if Tx.HashLock != nil && Tx.HashLock.Refund != Tx.Sender {
+			return errors.New("hash lock refunds another user")
+		}
+		scripted := c.Script != ""
+		coins[Tx.Coin] = &Coin{UUID: Tx.Coin, Value: Tx.Amount, Owner: Tx.Receiver, TxHash: Tx.CurrHash, Lock: Tx.Lock, HashLock: Tx.HashLock, Script: Tx.Script}
+		if scripted {
+			// the locking script checked the signatures
+			return nil
+		}
+	}
+	return h.verifySender(Tx, digest, keys)
+}
+
+/*
+	splitMerge() spends the inputs of the split or merge Tx, appended at
+	height, for its outputs. It conserves their value
+*/
+func splitMerge(Tx *Transaction, height int, coins map[uuid.UUID]*Coin) error {
+	if Tx.Sender != Tx.Receiver || len(Tx.Outputs) == 0 || (len(Tx.Inputs) > 1 && len(Tx.Outputs) > 1) {
+		return errors.New("malformed split or merge")
+	}
+	in := 0
+	for _, input := range Tx.Inputs {
+		c, ok := coins[input.Coin]
+		if !ok || c.TxHash != input.PrevHash {
+			return errors.New("broken coin link")
+		}
+		if c.HashLock != nil || c.Script != "" {
+			return errors.New("splits or merges a locked coin")
+		}
+		if err := spendable(c, Tx, height, nil); err != nil {
+			return fmt.Errorf("spends a coin it may not: %w", err)
+		}
+		in += c.Value
+		delete(coins, input.Coin)
+	}
+	out := 0
+	first := Tx.Inputs[0]
+	for j, output := range Tx.Outputs {
+		if output.Value <= 0 || output.Coin != uuid.NewV5(first.Coin, first.PrevHash+":"+strconv.Itoa(j)) {
+			return errors.New("invalid output")
+		}
+		out += output.Value
+		coins[output.Coin] = &Coin{UUID: output.Coin, Value: output.Value, Owner: Tx.Receiver, TxHash: Tx.CurrHash}
+	}
+	if in != out || in != Tx.Amount {
+		return errors.New("does not conserve value")
+	}
+	return nil
+}
+
+/*
+	spendable() returns why the sender of Tx, appended at height, cannot
+	spend c, like the ledger: the owner of a plain coin once its lock
+	opened, the owner of a hash locked coin with the preimage, its refund
+	user after the timeout, anyone unlocking the script of a scripted coin
+*/
+func spendable(c *Coin, Tx *Transaction, height int, digest []byte) error {
+	hl := c.HashLock
+	if c.Script == "" && Tx.Unlock != "" {
+		return errors.New("coin has no locking script")
+	}
+	switch {
+	case c.Script != "":
+		if Tx.Preimage != "" {
+			return errors.New("coin is not hash locked")
+		}
+		lock, err := hex.DecodeString(c.Script)
+		if err != nil {
+			return err
+		}
+		unlock, err := hex.DecodeString(Tx.Unlock)
+		if err != nil {
+			return err
+		}
+		return script.Run(unlock, lock, script.Context{Digest: digest, Height: height, Time: Tx.TimeStamp})
+	case hl == nil:
+		if Tx.Sender != c.Owner {
+			return errors.New("only the owner can spend a coin")
+		}
+		if Tx.Preimage != "" {
+			return errors.New("coin is not hash locked")
+		}
+		return checkLock(c.Lock, height, Tx.TimeStamp)
+	case Tx.Sender == c.Owner && (Tx.Preimage != "" || Tx.Sender != hl.Refund):
+		preimage, err := hex.DecodeString(Tx.Preimage)
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(preimage)
+		if len(preimage) == 0 || hex.EncodeToString(hash[:]) != hl.Hash {
+			return errors.New("preimage does not match the hash lock")
+		}
+		return nil
+	case Tx.Sender == hl.Refund:
+		return checkLock(&hl.Timeout, height, Tx.TimeStamp)
+	default:
+		return errors.New("only the receiver or, after the timeout, the sender can spend a hash locked coin")
+	}
+}
+
+/*
+	checkLock() returns why a coin with lock lk cannot be spent at height by
+	a Tx stamped now, nil if it can
+*/
+func checkLock(lk *Lock, height int, now int64) error {
+	switch {
+	case lk == nil:
+		return nil
+	case height < lk.Height:
+		return fmt.Errorf("coin is locked until block %d, the chain has %d", lk.Height, height)
+	case now < lk.Time:
+		return fmt.Errorf("coin is locked until %s", time.Unix(lk.Time, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+/*
+	verifySender() checks the signature of the sender of Tx on digest, or
+	those of the owners when the sender is a multisig, with their keys
+*/
+func (h *History) verifySender(Tx *Transaction, digest []byte, keys map[uuid.UUID]*ecdsa.PublicKey) error {
+	if len(Tx.Signatures) == 0 {
+		return verify(keys, Tx.Sender, digest, Tx.R, Tx.S)
+	}
+	var ms *Multisig
+	for i := range h.Multisigs {
+		if h.Multisigs[i].UUID == Tx.Sender {
+			ms = &h.Multisigs[i]
+		}
+	}
+	if ms == nil {
+		return fmt.Errorf("multisig %s is not in the history", Tx.Sender)
+	}
+	if len(Tx.Signatures) < ms.M {
+		return fmt.Errorf("needs %d signatures, got %d", ms.M, len(Tx.Signatures))
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, sig := range Tx.Signatures {
+		owner := false
+		for _, o := range ms.Owners {
+			owner = owner || o == sig.Signer
+		}
+		if !owner || seen[sig.Signer] {
+			return fmt.Errorf("signer %s is not an owner or signed twice", sig.Signer)
+		}
+		seen[sig.Signer] = true
+		if err := verify(keys, sig.Signer, digest, sig.R, sig.S); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	verify() checks the signature r, s of signer on digest with its key in
+	keys
+*/
+func verify(keys map[uuid.UUID]*ecdsa.PublicKey, signer uuid.UUID, digest []byte, r, s string) error {
+	pub, ok := keys[signer]
+	if !ok {
+		return fmt.Errorf("key of %s is not trusted", signer)
+	}
+	if err := verifySignature(pub, digest, r, s); err != nil {
+		return fmt.Errorf("%s: %w", signer, err)
+	}
+	return nil
+}
+
+func verifySignature(pub *ecdsa.PublicKey, digest []byte, r, s string) error {
+	sigR, sigS, err := crypto.ParseSignature(r, s)
+	if err != nil {
+		return err
+	}
+	if !crypto.Verify(pub, digest, sigR, sigS) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+/*
+	spendDigest() returns the digest the sender of Tx signed
+*/
+func spendDigest(Tx *Transaction) ([]byte, error) {
+	if len(Tx.Inputs) == 0 {
+		cn := &Coin{UUID: Tx.Coin, TxHash: Tx.CoinPrev}
+		return transferDigest(cn, TransferRequest{Receiver: Tx.Receiver, Lock: Tx.Lock, HashLock: Tx.HashLock, Script: Tx.Script, Memo: Tx.Memo})
+	}
+	var d hexDecoder
+	coins := make([]uuid.UUID, len(Tx.Inputs))
+	prevHashes := make([][]byte, len(Tx.Inputs))
+	for i, in := range Tx.Inputs {
+		coins[i], prevHashes[i] = in.Coin, d.bytes(in.PrevHash)
+	}
+	values := make([]int, len(Tx.Outputs))
+	for i, out := range Tx.Outputs {
+		values[i] = out.Value
+	}
+	return crypto.SplitMergeDigest(coins, prevHashes, values), d.err
+}
+
+/*
+	txHash() returns the hash of Tx over the fields the ledger hashes, in
+	its order
+*/
+func txHash(Tx *Transaction) ([]byte, error) {
+	var d hexDecoder
+	data := [][]byte{[]byte(strconv.FormatInt(Tx.TimeStamp, 10)), []byte(Tx.Message), d.bytes(Tx.PrevHash), Tx.Coin.Bytes(), Tx.Sender.Bytes(), Tx.Receiver.Bytes(), []byte(strconv.Itoa(Tx.Amount)), d.bytes(Tx.CoinPrev), d.int(Tx.R), d.int(Tx.S)}
+	for _, sig := range Tx.Signatures {
+		data = append(data, sig.Signer.Bytes(), d.int(sig.R), d.int(sig.S))
+	}
+	if lk := Tx.Lock; lk != nil {
+		data = append(data, []byte(strconv.Itoa(lk.Height)), []byte(strconv.FormatInt(lk.Time, 10)))
+	}
+	if hl := Tx.HashLock; hl != nil {
+		data = append(data, d.bytes(hl.Hash), []byte(strconv.Itoa(hl.Timeout.Height)), []byte(strconv.FormatInt(hl.Timeout.Time, 10)), hl.Refund.Bytes())
+	}
+	if Tx.Preimage != "" {
+		data = append(data, d.bytes(Tx.Preimage))
+	}
+	if Tx.Script != "" || Tx.Unlock != "" {
+		data = append(data, []byte("script:"), d.bytes(Tx.Script), []byte("unlock:"), d.bytes(Tx.Unlock))
+	}
+	if len(Tx.Memo) > 0 {
+		data = append(data, []byte("memo:"), crypto.MemoBytes(Tx.Memo))
+	}
+	for _, in := range Tx.Inputs {
+		data = append(data, []byte("in:"), in.Coin.Bytes(), d.bytes(in.PrevHash))
+	}
+	for _, out := range Tx.Outputs {
+		data = append(data, []byte("out:"), out.Coin.Bytes(), []byte(strconv.Itoa(out.Value)))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	hash := sha256.Sum256(bytes.Join(data, []byte{}))
+	return hash[:], nil
+}
+
+/*
+	hexDecoder decodes hex fields and keeps the first error
+*/
+type hexDecoder struct {
+	err error
+}
+
+func (d *hexDecoder) bytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+	return b
+}
+
+/*
+	int() returns the bytes of the hex number s, nil for an empty s
+*/
+func (d *hexDecoder) int(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		if d.err == nil {
+			d.err = errors.New("malformed number " + strconv.Quote(s))
+		}
+		return nil
+	}
+	return n.Bytes()
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/de7ign/goofy-coin/script"
+	"github.com/gofrs/uuid"
+)
+
+func TestVerifyHistory(t *testing.T) {
+	l := ledger.New()
+	goofy, _ := l.RegisterUser("goofy", nil, "")
+	alice, _ := l.RegisterUser("alice", nil, "")
+	bob, _ := l.RegisterUser("bob", nil, "")
+	must := func(_ interface{}, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the coin goes locked and with a memo to alice, hash locked to bob,
+	// through a multisig, a script and a split and merge
+	c, _ := l.Mint(goofy.UUID, 10)
+	must(l.Pay(ledger.Payment{Spender: goofy.UUID, Coin: c.UUID, Receiver: alice.UUID, Lock: &ledger.Lock{Height: 1}, Memo: ledger.Memo{"order": "42"}}))
+	l.SealBlock()
+	preimage := []byte("open sesame")
+	hash := sha256.Sum256(preimage)
+	must(l.Pay(ledger.Payment{Spender: alice.UUID, Coin: c.UUID, Receiver: bob.UUID, HashLock: &ledger.HashLock{Hash: hash[:], Timeout: ledger.Lock{Height: 5}}}))
+	ms, err := l.CreateMultisig(2, []uuid.UUID{alice.UUID, bob.UUID})
+	must(ms, err)
+	must(l.Pay(ledger.Payment{Spender: bob.UUID, Coin: c.UUID, Receiver: ms.UUID, Preimage: preimage}))
+	c, _ = l.Coin(c.UUID)
+	digest := crypto.SpendDigest(c.UUID, bob.UUID, c.TxHash)
+	var sigs []ledger.Signature
+	for _, u := range []ledger.Signature{{Signer: alice.UUID}, {Signer: bob.UUID}} {
+		user, _ := l.User(u.Signer)
+		r, s, err := crypto.Sign(user.PrivateKey, digest)
+		must(nil, err)
+		sigs = append(sigs, ledger.Signature{Signer: u.Signer, R: r, S: s})
+	}
+	must(l.SpendMultisig(c.UUID, bob.UUID, nil, sigs))
+	lock := append(script.AppendData(nil, crypto.CompressPublicKey(alice.PublicKey)), byte(script.OpCheckSig))
+	must(l.Pay(ledger.Payment{Spender: bob.UUID, Coin: c.UUID, Receiver: alice.UUID, Script: lock}))
+	c, _ = l.Coin(c.UUID)
+	r, s, _ := crypto.Sign(alice.PrivateKey, crypto.SpendDigest(c.UUID, alice.UUID, c.TxHash))
+	must(l.Pay(ledger.Payment{Spender: bob.UUID, Coin: c.UUID, Receiver: alice.UUID, Unlock: script.AppendData(nil, crypto.SignatureBytes(r, s))}))
+	_, parts, err := l.Split(alice.UUID, c.UUID, []int{4, 6}, nil, nil)
+	must(parts, err)
+	more, _ := l.Mint(goofy.UUID, 5)
+	must(l.Transfer(goofy.UUID, more.UUID, alice.UUID, nil, nil, nil))
+	_, merged, err := l.Merge(alice.UUID, []uuid.UUID{parts[0].UUID, more.UUID}, nil, nil)
+	must(merged, err)
+	if err := l.VerifyChain(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := l.History(merged.UUID)
+	must(h, err)
+	data, err := json.Marshal(h)
+	must(data, err)
+	decode := func() *History {
+		var h History
+		if err := json.Unmarshal(data, &h); err != nil {
+			t.Fatal(err)
+		}
+		return &h
+	}
+	trusted := func() map[uuid.UUID]*ecdsa.PublicKey {
+		return map[uuid.UUID]*ecdsa.PublicKey{alice.UUID: alice.PublicKey, bob.UUID: bob.PublicKey}
+	}
+	if err := VerifyHistory(decode(), goofy.PublicKey, trusted()); err != nil {
+		t.Fatalf("valid history: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey)
+		want   string
+	}{
+		{"other goofy", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) {}, "mint not signed by goofy"},
+		{"memo", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { h.Hops[1].Tx.Memo["order"] = "43" }, "hash mismatch"},
+		{"dropped hop", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { h.Hops = append(h.Hops[:2], h.Hops[3:]...) }, "broken coin link"},
+		{"heights", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { h.Hops[1].Height = 2 }, "out of chain order"},
+		{"key", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { keys[alice.UUID] = bob.PublicKey }, "invalid signature"},
+		{"untrusted sender", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { delete(keys, bob.UUID) }, "not trusted"},
+		{"key of the server", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) {
+			delete(keys, alice.UUID)
+			h.Keys[alice.UUID] = h.Keys[bob.UUID]
+		}, "not trusted"},
+		{"no multisig", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { h.Multisigs = nil }, "not in the history"},
+		{"multisig of one", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { h.Multisigs[0].Owners = h.Multisigs[0].Owners[:1] }, "not an owner"},
+		{"other coin", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { h.Coin.Value = 15 }, "not to coin"},
+		{"spent coin", func(h *History, keys map[uuid.UUID]*ecdsa.PublicKey) { h.Coin.UUID = c.UUID }, "do not lead"},
+	}
+	for _, test := range tests {
+		h, key, keys := decode(), goofy.PublicKey, trusted()
+		if test.name == "other goofy" {
+			key = alice.PublicKey
+		}
+		test.tamper(h, keys)
+		if err := VerifyHistory(h, key, keys); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	// the hashes are those of the ledger
+	for i, hop := range decode().Hops {
+		if hash, _ := txHash(&hop.Tx); hex.EncodeToString(hash) != hex.EncodeToString(h.Hops[i].Tx.CurrHash) {
+			t.Errorf("hop %d hashes to %x", i, hash)
+		}
+	}
+}
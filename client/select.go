@@ -0,0 +1,135 @@
+package client
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+	Coin Selection
+	___________________________________________________________________________
+
+	Coins move whole, so a wallet pays an amount with coins worth at least
+	that much. SelectCoins() picks them by a Strategy and returns the
+	change, the value above the amount, which the payer splits off the last
+	coin first to pay exactly
+*/
+
+type Strategy string
+
+const (
+	// LargestFirst picks the fewest coins
+	LargestFirst Strategy = "largest-first"
+	// SmallestFirst spends small coins before they pile up
+	SmallestFirst Strategy = "smallest-first"
+	// ExactMatch picks coins worth exactly the amount, which need no split
+	ExactMatch Strategy = "exact-match"
+)
+
+// exactMatchSteps bounds the subset search of ExactMatch
+const exactMatchSteps = 100000
+
+var (
+	ErrInsufficientFunds = errors.New("coins are worth less than the amount")
+	ErrNoExactMatch      = errors.New("no coins add up to the amount")
+)
+
+/*
+	SelectCoins() returns coins to pay amount with by strategy and their
+	value above amount
+*/
+func SelectCoins(coins []Coin, amount int, strategy Strategy) ([]Coin, int, error) {
+	if amount <= 0 {
+		return nil, 0, errors.New("amount must be positive")
+	}
+	sorted := append([]Coin{}, coins...)
+	total := 0
+	for _, cn := range sorted {
+		total += cn.Value
+	}
+	if total < amount {
+		return nil, 0, ErrInsufficientFunds
+	}
+	switch strategy {
+	case LargestFirst:
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value > sorted[j].Value })
+	case SmallestFirst:
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value < sorted[j].Value })
+	case ExactMatch:
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value > sorted[j].Value })
+		selected := exactMatch(sorted, amount)
+		if selected == nil {
+			return nil, 0, ErrNoExactMatch
+		}
+		return selected, 0, nil
+	default:
+		return nil, 0, errors.New("unknown strategy " + strconv.Quote(string(strategy)))
+	}
+	selected := []Coin{}
+	sum := 0
+	for _, cn := range sorted {
+		if sum >= amount {
+			break
+		}
+		selected = append(selected, cn)
+		sum += cn.Value
+	}
+	return selected, sum - amount, nil
+}
+
+/*
+	exactMatch() returns coins of sorted, largest first, which add up to
+	amount, nil if it finds none
+*/
+func exactMatch(sorted []Coin, amount int) []Coin {
+	// rest[i] is the value of sorted[i:]
+	rest := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		rest[i] = rest[i+1] + sorted[i].Value
+	}
+	steps := 0
+	var picked []Coin
+	var search func(i, left int) bool
+	search = func(i, left int) bool {
+		if left == 0 {
+			return true
+		}
+		steps++
+		if i == len(sorted) || rest[i] < left || steps > exactMatchSteps {
+			return false
+		}
+		if sorted[i].Value <= left {
+			picked = append(picked, sorted[i])
+			if search(i+1, left-sorted[i].Value) {
+				return true
+			}
+			picked = picked[:len(picked)-1]
+		}
+		return search(i+1, left)
+	}
+	if !search(0, amount) {
+		return nil
+	}
+	return picked
+}
+
+/*
+	Spendable() returns the coins which their owner can pass on at time
+	now, those without a hash lock or script whose lock has no height and
+	opened by now
+*/
+func Spendable(coins []Coin, now time.Time) []Coin {
+	spendable := []Coin{}
+	for _, cn := range coins {
+		if cn.HashLock != nil || cn.Script != "" {
+			continue
+		}
+		if cn.Lock != nil && (cn.Lock.Height > 0 || cn.Lock.Time > now.Unix()) {
+			continue
+		}
+		spendable = append(spendable, cn)
+	}
+	return spendable
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func values(coins []Coin) []int {
+	v := []int{}
+	for _, cn := range coins {
+		v = append(v, cn.Value)
+	}
+	return v
+}
+
+func TestSelectCoins(t *testing.T) {
+	coins := []Coin{{Value: 5}, {Value: 1}, {Value: 10}, {Value: 3}, {Value: 2}}
+	tests := []struct {
+		strategy Strategy
+		amount   int
+		want     []int
+		change   int
+		err      error
+	}{
+		{LargestFirst, 12, []int{10, 5}, 3, nil},
+		{SmallestFirst, 12, []int{1, 2, 3, 5, 10}, 9, nil},
+		{SmallestFirst, 6, []int{1, 2, 3}, 0, nil},
+		{ExactMatch, 12, []int{10, 2}, 0, nil},
+		{ExactMatch, 9, []int{5, 3, 1}, 0, nil},
+		{ExactMatch, 21, []int{10, 5, 3, 2, 1}, 0, nil},
+		{LargestFirst, 22, nil, 0, ErrInsufficientFunds},
+		{ExactMatch, 22, nil, 0, ErrInsufficientFunds},
+	}
+	for _, tt := range tests {
+		selected, change, err := SelectCoins(coins, tt.amount, tt.strategy)
+		if err != tt.err || change != tt.change || (err == nil && !equalInts(values(selected), tt.want)) {
+			t.Errorf("%s of %d returned %v, %d, %v", tt.strategy, tt.amount, values(selected), change, err)
+		}
+	}
+	if _, _, err := SelectCoins([]Coin{{Value: 5}, {Value: 5}}, 7, ExactMatch); err != ErrNoExactMatch {
+		t.Errorf("exact match of 7 returned %v", err)
+	}
+	if _, _, err := SelectCoins(coins, 3, "random"); err == nil {
+		t.Error("unknown strategy succeeded")
+	}
+	if coins[0].Value != 5 {
+		t.Error("selection reordered the coins")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpendable(t *testing.T) {
+	now := time.Unix(1000, 0)
+	coins := []Coin{
+		{Value: 1},
+		{Value: 2, Lock: &Lock{Time: 999}},
+		{Value: 3, Lock: &Lock{Time: 1001}},
+		{Value: 4, Lock: &Lock{Height: 1}},
+		{Value: 5, HashLock: &HashLock{Hash: "00"}},
+		{Value: 6, Script: "51"},
+	}
+	if got := values(Spendable(coins, now)); !equalInts(got, []int{1, 2}) {
+		t.Errorf("spendable coins are worth %v", got)
+	}
+}
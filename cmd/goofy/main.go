@@ -0,0 +1,1008 @@
+/*
+	goofy is a command-line wallet and admin client for the goofy coin server
+
+	usage: goofy [-server URL] [-token TOKEN] [-o table|json] <command> [arguments]
+
+	commands
+	0. login [-key FILE] NAME       sign a challenge with FILE or read a password
+	   logout                       from stdin, the session token is kept in the
+	                                user config dir
+	1. key gen -out FILE            generate a P-256 key file
+	2. user create [-key FILE] [-password] NAME
+	                                register a user, with the public key of FILE
+	                                and a password read from stdin if given
+	3. user list                    list users
+	4. coin mint AMOUNT             goofy creates a coin
+	5. coin list [-owner UUID]      list coins
+	   coin split [-key FILE] COIN VALUE...
+	                                split a coin into coins of the values
+	   coin merge [-key FILE] COIN...
+	                                merge coins into one
+	   coin select [-strategy S] OWNER AMOUNT
+	                                pick spendable coins of OWNER for AMOUNT,
+	                                largest-first, smallest-first or
+	                                exact-match, and print the change
+	   coin history [-goofy-key FILE] [-keys FILE] COIN
+	                                every hop of a coin from its mints, checked
+	                                here against goofy's public key of FILE or
+	                                of the server and the keys of the other
+	                                senders in the JSON FILE of UUID to PEM
+	6. pay [-key FILE] [-lock-height N] [-lock-until TIME] [-hash HEX]
+	       [-preimage HEX] [-script ASM] [-unlock ASM] [-memo KEY=VALUE]...
+	       COIN RECEIVER
+	                                pass a coin, signed with FILE if given, which
+	                                the receiver cannot spend before block N or
+	                                the RFC 3339 TIME. With -hash the receiver
+	                                spends it with the preimage of HEX and the
+	                                sender takes it back after N or TIME,
+	                                -preimage claims such a coin. -script locks
+	                                the coin with a script, -unlock spends a
+	                                scripted coin. -memo adds a signed memo
+	7. secret gen                   a random preimage and its hash for -hash
+	8. script asm ASM | disasm HEX  translate a script between text and hex
+	   script sig -key FILE [-script ASM] COIN RECEIVER
+	                                signature for the unlocking script of a pay
+	9. balance USER                 balance and coins of a user
+	10. tx show [-memo KEY[=VALUE]] [HASH]
+	                                list your transactions, those with a memo,
+	                                or show one
+	11. chain verify                verify hash links and signatures of the chain
+	12. invoice create [-key FILE] [-memo TEXT] [-expires DURATION] AMOUNT
+	                                issue an invoice payable to you, signed with
+	                                FILE if given, and print its URI
+	    invoice show ID|URI         show an invoice and check its signature
+	    invoice pay [-key FILE] ID|URI COIN...
+	                                pay an invoice with coins worth its amount
+*/
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	goofy "github.com/de7ign/goofy-coin/client"
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/script"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	client holds the server address and output format shared by every command
+*/
+type client struct {
+	server    string
+	output    string
+	token     string
+	tokenFile string
+	in        io.Reader
+	out       io.Writer
+}
+
+func main() {
+	fs := flag.NewFlagSet("goofy", flag.ExitOnError)
+	server := fs.String("server", envOr("GOOFY_SERVER", "http://localhost:8080"), "goofy coin server `URL`")
+	token := fs.String("token", os.Getenv("GOOFY_TOKEN"), "session `TOKEN`, defaults to the one saved by login")
+	output := fs.String("o", "table", "output format, table or json")
+	fs.Parse(os.Args[1:])
+
+	c := &client{server: *server, output: *output, token: *token, in: os.Stdin, out: os.Stdout}
+	if dir, err := os.UserConfigDir(); err == nil {
+		c.tokenFile = filepath.Join(dir, "goofy", "token")
+	}
+	if c.token == "" && c.tokenFile != "" {
+		if data, err := ioutil.ReadFile(c.tokenFile); err == nil {
+			c.token = strings.TrimSpace(string(data))
+		}
+	}
+	if err := c.run(fs.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "goofy:", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+var errUsage = errors.New("usage: goofy [-server URL] [-token TOKEN] [-o table|json] login | logout | key gen | user create|list | coin mint|list|split|merge|select|history | pay | secret gen | script asm|disasm|sig | balance | tx show | chain verify | invoice create|show|pay")
+
+/*
+	run() dispatches args to the matching command
+*/
+func (c *client) run(args []string) error {
+	if c.output != "table" && c.output != "json" {
+		return errors.New("output must be table or json")
+	}
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch {
+	case cmd == "login":
+		return c.login(args)
+	case cmd == "logout":
+		return c.logout()
+	case cmd == "key" && sub == "gen":
+		return c.keyGen(args[1:])
+	case cmd == "user" && sub == "create":
+		return c.userCreate(args[1:])
+	case cmd == "user" && sub == "list":
+		return c.userList()
+	case cmd == "coin" && sub == "mint":
+		return c.coinMint(args[1:])
+	case cmd == "coin" && sub == "list":
+		return c.coinList(args[1:])
+	case cmd == "coin" && sub == "split":
+		return c.coinSplit(args[1:])
+	case cmd == "coin" && sub == "merge":
+		return c.coinMerge(args[1:])
+	case cmd == "coin" && sub == "select":
+		return c.coinSelect(args[1:])
+	case cmd == "coin" && sub == "history":
+		return c.coinHistory(args[1:])
+	case cmd == "pay":
+		return c.pay(args)
+	case cmd == "secret" && sub == "gen":
+		return c.secretGen()
+	case cmd == "script" && sub == "asm":
+		return c.scriptAsm(args[1:])
+	case cmd == "script" && sub == "disasm":
+		return c.scriptDisasm(args[1:])
+	case cmd == "script" && sub == "sig":
+		return c.scriptSig(args[1:])
+	case cmd == "balance":
+		return c.balance(args)
+	case cmd == "tx" && sub == "show":
+		return c.txShow(args[1:])
+	case cmd == "chain" && sub == "verify":
+		return c.chainVerify()
+	case cmd == "invoice" && sub == "create":
+		return c.invoiceCreate(args[1:])
+	case cmd == "invoice" && sub == "show":
+		return c.invoiceShow(args[1:])
+	case cmd == "invoice" && sub == "pay":
+		return c.invoicePay(args[1:])
+	}
+	return errUsage
+}
+
+/*
+	Commands
+	___________________________________________________________________________
+*/
+
+/*
+	api() returns a client of the server with the current session token
+*/
+func (c *client) api() *goofy.Client {
+	api := goofy.New(c.server)
+	api.Token = c.token
+	return api
+}
+
+func (c *client) login(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "sign a login challenge with key `FILE` instead of reading a password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: goofy login -key FILE NAME | echo PASSWORD | goofy login NAME")
+	}
+	api := c.api()
+	var res *goofy.Session
+	if *keyFile != "" {
+		priv, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		res, err = api.LoginWithKey(context.Background(), fs.Arg(0), priv)
+		if err != nil {
+			return err
+		}
+	} else {
+		password, err := bufio.NewReader(c.in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		res, err = api.Login(context.Background(), fs.Arg(0), strings.TrimRight(password, "\r\n"))
+		if err != nil {
+			return err
+		}
+	}
+	c.token = api.Token
+	if err := c.saveToken(); err != nil {
+		return err
+	}
+	return c.print(res, func(w io.Writer) {
+		fmt.Fprintf(w, "USER\t%s\nUUID\t%s\nEXPIRES\t%s\n", res.User.Name, res.User.UUID, res.Expires.Format(time.RFC3339))
+	})
+}
+
+func (c *client) logout() error {
+	if err := c.api().Logout(context.Background()); err != nil {
+		return err
+	}
+	c.token = ""
+	return c.saveToken()
+}
+
+/*
+	saveToken() keeps the session token for later invocations, an empty token
+	removes the file
+*/
+func (c *client) saveToken() error {
+	if c.tokenFile == "" {
+		return nil
+	}
+	if c.token == "" {
+		err := os.Remove(c.tokenFile)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.tokenFile), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.tokenFile, []byte(c.token), 0600)
+}
+
+func (c *client) keyGen(args []string) error {
+	fs := flag.NewFlagSet("key gen", flag.ContinueOnError)
+	out := fs.String("out", "", "key `FILE` to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("usage: goofy key gen -out FILE")
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	return ioutil.WriteFile(*out, data, 0600)
+}
+
+func (c *client) userCreate(args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "key `FILE` whose public key is registered")
+	withPassword := fs.Bool("password", false, "read a login password from stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: goofy user create [-key FILE] [-password] NAME")
+	}
+	req := goofy.CreateUserRequest{UserName: fs.Arg(0)}
+	if *withPassword {
+		password, err := bufio.NewReader(c.in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		req.Password = strings.TrimRight(password, "\r\n")
+	}
+	if *keyFile != "" {
+		priv, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+		if err != nil {
+			return err
+		}
+		req.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+	u, err := c.api().CreateUser(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return c.print(u, func(w io.Writer) {
+		fmt.Fprintln(w, "UUID\tNAME")
+		fmt.Fprintf(w, "%s\t%s\n", u.UUID, u.Name)
+	})
+}
+
+func (c *client) userList() error {
+	users, err := c.api().Users(context.Background())
+	if err != nil {
+		return err
+	}
+	return c.print(users, func(w io.Writer) {
+		fmt.Fprintln(w, "UUID\tNAME")
+		for _, u := range users {
+			fmt.Fprintf(w, "%s\t%s\n", u.UUID, u.Name)
+		}
+	})
+}
+
+func (c *client) coinMint(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: goofy coin mint AMOUNT")
+	}
+	amount, err := strconv.Atoi(args[0])
+	if err != nil {
+		return errors.New("amount must be an integer")
+	}
+	cn, err := c.api().Mint(context.Background(), amount)
+	if err != nil {
+		return err
+	}
+	return c.print(cn, func(w io.Writer) { printCoins(w, []goofy.Coin{*cn}) })
+}
+
+func (c *client) coinList(args []string) error {
+	fs := flag.NewFlagSet("coin list", flag.ContinueOnError)
+	owner := fs.String("owner", "", "only list coins of owner `UUID`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var coins []goofy.Coin
+	var err error
+	if *owner != "" {
+		var ownerID uuid.UUID
+		ownerID, err = uuid.FromString(*owner)
+		if err != nil {
+			return err
+		}
+		coins, err = c.api().CoinsOf(context.Background(), ownerID)
+	} else {
+		coins, err = c.api().Coins(context.Background())
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(coins, func(w io.Writer) { printCoins(w, coins) })
+}
+
+func (c *client) coinSplit(args []string) error {
+	fs := flag.NewFlagSet("coin split", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "key `FILE` of the coin owner, the server signs if omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 3 {
+		return errors.New("usage: goofy coin split [-key FILE] COIN VALUE...")
+	}
+	coinID, err := uuid.FromString(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var values []int
+	for _, arg := range fs.Args()[1:] {
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return errors.New("values must be integers")
+		}
+		values = append(values, v)
+	}
+	api := c.api()
+	req := goofy.SplitRequest{Coin: coinID, Values: values}
+	if *keyFile != "" {
+		priv, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		cn, err := api.Coin(context.Background(), coinID)
+		if err != nil {
+			return err
+		}
+		if req, err = goofy.SignSplit(priv, cn, values); err != nil {
+			return err
+		}
+	}
+	res, err := api.Split(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return c.print(res, func(w io.Writer) { printCoins(w, res.Coins) })
+}
+
+func (c *client) coinMerge(args []string) error {
+	fs := flag.NewFlagSet("coin merge", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "key `FILE` of the coin owner, the server signs if omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: goofy coin merge [-key FILE] COIN...")
+	}
+	api := c.api()
+	req := goofy.MergeRequest{}
+	var coins []goofy.Coin
+	for _, arg := range fs.Args() {
+		coinID, err := uuid.FromString(arg)
+		if err != nil {
+			return err
+		}
+		req.Coins = append(req.Coins, coinID)
+		if *keyFile != "" {
+			cn, err := api.Coin(context.Background(), coinID)
+			if err != nil {
+				return err
+			}
+			coins = append(coins, *cn)
+		}
+	}
+	if *keyFile != "" {
+		priv, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		if req, err = goofy.SignMerge(priv, coins); err != nil {
+			return err
+		}
+	}
+	res, err := api.Merge(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return c.print(res, func(w io.Writer) { printCoins(w, res.Coins) })
+}
+
+func (c *client) coinSelect(args []string) error {
+	fs := flag.NewFlagSet("coin select", flag.ContinueOnError)
+	strategy := fs.String("strategy", string(goofy.LargestFirst), "`S` is largest-first, smallest-first or exact-match")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: goofy coin select [-strategy S] OWNER AMOUNT")
+	}
+	owner, err := uuid.FromString(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	amount, err := strconv.Atoi(fs.Arg(1))
+	if err != nil {
+		return errors.New("amount must be an integer")
+	}
+	b, err := c.api().Balance(context.Background(), owner)
+	if err != nil {
+		return err
+	}
+	coins, change, err := goofy.SelectCoins(goofy.Spendable(b.Coins, time.Now()), amount, goofy.Strategy(*strategy))
+	if err != nil {
+		return err
+	}
+	selection := struct {
+		Coins  []goofy.Coin `json:"coins"`
+		Change int          `json:"change"`
+	}{coins, change}
+	return c.print(selection, func(w io.Writer) {
+		printCoins(w, coins)
+		fmt.Fprintf(w, "\nCHANGE\t%d\n", change)
+	})
+}
+
+func (c *client) coinHistory(args []string) error {
+	fs := flag.NewFlagSet("coin history", flag.ContinueOnError)
+	keyFile := fs.String("goofy-key", "", "PEM public key `FILE` of goofy, the server's if omitted")
+	keysFile := fs.String("keys", "", "JSON `FILE` mapping the UUIDs of the senders you trust to their PEM public keys")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: goofy coin history [-goofy-key FILE] [-keys FILE] COIN")
+	}
+	coinID, err := uuid.FromString(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	api := c.api()
+	var pemKey []byte
+	if *keyFile != "" {
+		if pemKey, err = ioutil.ReadFile(*keyFile); err != nil {
+			return err
+		}
+	} else {
+		info, err := api.Info(context.Background())
+		if err != nil {
+			return err
+		}
+		pemKey = []byte(info.GoofyPublicKey)
+	}
+	goofyKey, err := crypto.ParsePublicKey(pemKey)
+	if err != nil {
+		return err
+	}
+	trusted := map[uuid.UUID]*ecdsa.PublicKey{}
+	if *keysFile != "" {
+		data, err := ioutil.ReadFile(*keysFile)
+		if err != nil {
+			return err
+		}
+		var pems map[uuid.UUID]string
+		if err := json.Unmarshal(data, &pems); err != nil {
+			return errors.New(*keysFile + ": " + err.Error())
+		}
+		for id, pemKey := range pems {
+			if trusted[id], err = crypto.ParsePublicKey([]byte(pemKey)); err != nil {
+				return errors.New(*keysFile + ": key of " + id.String() + ": " + err.Error())
+			}
+		}
+	}
+	h, err := api.History(context.Background(), coinID)
+	if err != nil {
+		return err
+	}
+	if err := goofy.VerifyHistory(h, goofyKey, trusted); err != nil {
+		return errors.New("history of coin " + coinID.String() + " is invalid: " + err.Error())
+	}
+	return c.print(h, func(w io.Writer) {
+		fmt.Fprintln(w, "HEIGHT\tHASH\tSENDER\tRECEIVER\tAMOUNT\tMESSAGE")
+		for _, hop := range h.Hops {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n", hop.Height, hop.Tx.CurrHash, hop.Tx.Sender, hop.Tx.Receiver, hop.Tx.Amount, hop.Tx.Message)
+		}
+		fmt.Fprintf(w, "\nVERIFIED\t%d hops to %s\n", len(h.Hops), h.Coin.Owner)
+	})
+}
+
+func (c *client) pay(args []string) error {
+	fs := flag.NewFlagSet("pay", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "key `FILE` of the coin owner, the server signs if omitted")
+	lockHeight := fs.Int("lock-height", 0, "lock the coin until the chain has `N` blocks")
+	lockUntil := fs.String("lock-until", "", "lock the coin until the RFC 3339 `TIME`")
+	hashLock := fs.String("hash", "", "hash lock the coin to the SHA-256 `HEX`, the locks become the refund timeout")
+	preimage := fs.String("preimage", "", "claim a hash locked coin with the preimage `HEX`")
+	lockScript := fs.String("script", "", "lock the coin with the script `ASM`")
+	unlockScript := fs.String("unlock", "", "spend a scripted coin with the unlocking script `ASM`")
+	memo := memoFlag{}
+	fs.Var(memo, "memo", "add `KEY=VALUE` to the memo, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: goofy pay [-key FILE] [-lock-height N] [-lock-until TIME] [-hash HEX] [-preimage HEX] [-script ASM] [-unlock ASM] [-memo KEY=VALUE]... COIN RECEIVER")
+	}
+	hash, err := hex.DecodeString(*hashLock)
+	if err != nil {
+		return err
+	}
+	lockBytes, err := script.Assemble(*lockScript)
+	if err != nil {
+		return err
+	}
+	unlockBytes, err := script.Assemble(*unlockScript)
+	if err != nil {
+		return err
+	}
+	lock := goofy.Lock{Height: *lockHeight}
+	if *lockUntil != "" {
+		until, err := time.Parse(time.RFC3339, *lockUntil)
+		if err != nil {
+			return err
+		}
+		lock.Time = until.Unix()
+	}
+	coinID, err := uuid.FromString(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	receiver, err := uuid.FromString(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	api := c.api()
+	req := goofy.TransferRequest{Coin: coinID, Receiver: receiver}
+	if len(memo) > 0 {
+		req.Memo = memo
+	}
+	switch {
+	case len(lockBytes) > 0:
+		req.Script = hex.EncodeToString(lockBytes)
+	case len(hash) > 0:
+		req.HashLock = &goofy.HashLock{Hash: *hashLock, Timeout: lock}
+	case lock != (goofy.Lock{}):
+		req.Lock = &lock
+	}
+	if *keyFile != "" {
+		priv, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		cn, err := api.Coin(context.Background(), coinID)
+		if err != nil {
+			return err
+		}
+		req.PrevHash = cn.TxHash
+		if req, err = goofy.SignTransferRequest(priv, cn, req); err != nil {
+			return err
+		}
+	}
+	req.Preimage = *preimage
+	req.Unlock = hex.EncodeToString(unlockBytes)
+	tx, err := api.Transfer(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return c.print(tx, func(w io.Writer) { printTxs(w, []goofy.Transaction{*tx}) })
+}
+
+func (c *client) secretGen() error {
+	preimage, hash, err := goofy.NewPreimage()
+	if err != nil {
+		return err
+	}
+	secret := struct {
+		Preimage string `json:"preimage"`
+		Hash     string `json:"hash"`
+	}{hex.EncodeToString(preimage), hex.EncodeToString(hash)}
+	return c.print(secret, func(w io.Writer) {
+		fmt.Fprintln(w, "PREIMAGE\tHASH")
+		fmt.Fprintf(w, "%s\t%s\n", secret.Preimage, secret.Hash)
+	})
+}
+
+func (c *client) scriptAsm(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: goofy script asm ASM")
+	}
+	s, err := script.Assemble(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	return c.printScript(s)
+}
+
+func (c *client) scriptDisasm(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: goofy script disasm HEX")
+	}
+	s, err := hex.DecodeString(args[0])
+	if err != nil {
+		return err
+	}
+	return c.printScript(s)
+}
+
+func (c *client) scriptSig(args []string) error {
+	fs := flag.NewFlagSet("script sig", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "key `FILE` to sign with")
+	lockScript := fs.String("script", "", "locking script `ASM` the pay puts on the coin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || *keyFile == "" {
+		return errors.New("usage: goofy script sig -key FILE [-script ASM] COIN RECEIVER")
+	}
+	priv, err := readKey(*keyFile)
+	if err != nil {
+		return err
+	}
+	lockBytes, err := script.Assemble(*lockScript)
+	if err != nil {
+		return err
+	}
+	coinID, err := uuid.FromString(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	receiver, err := uuid.FromString(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	cn, err := c.api().Coin(context.Background(), coinID)
+	if err != nil {
+		return err
+	}
+	sig, err := goofy.UnlockSignature(priv, cn, goofy.TransferRequest{Coin: coinID, Receiver: receiver, Script: hex.EncodeToString(lockBytes)})
+	if err != nil {
+		return err
+	}
+	return c.printScript(script.AppendData(nil, sig))
+}
+
+/*
+	printScript() prints s as hex and assembler text, up to the error for a
+	malformed script
+*/
+func (c *client) printScript(s []byte) error {
+	text, err := script.Disassemble(s)
+	out := struct {
+		Hex string `json:"hex"`
+		Asm string `json:"asm"`
+	}{hex.EncodeToString(s), text}
+	if perr := c.print(out, func(w io.Writer) {
+		fmt.Fprintln(w, "HEX\tASM")
+		fmt.Fprintf(w, "%s\t%s\n", out.Hex, out.Asm)
+	}); perr != nil {
+		return perr
+	}
+	return err
+}
+
+func (c *client) balance(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: goofy balance USER")
+	}
+	userID, err := uuid.FromString(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := c.api().Balance(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+	return c.print(b, func(w io.Writer) {
+		fmt.Fprintf(w, "USER\t%s\nBALANCE\t%d\nSPENDABLE\t%d\nLOCKED\t%d\n\n", b.User, b.Balance, b.Spendable, b.Locked)
+		printCoins(w, b.Coins)
+	})
+}
+
+func (c *client) txShow(args []string) error {
+	fs := flag.NewFlagSet("tx show", flag.ContinueOnError)
+	memo := fs.String("memo", "", "list the transactions whose memo has `KEY` or KEY=VALUE")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || (fs.NArg() == 1 && *memo != "") {
+		return errors.New("usage: goofy tx show [-memo KEY[=VALUE]] [HASH]")
+	}
+	if fs.NArg() == 1 {
+		tx, err := c.api().Transaction(context.Background(), fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return c.print(tx, func(w io.Writer) {
+			fmt.Fprintf(w, "HASH\t%s\nPREV HASH\t%s\nTIME\t%d\nMESSAGE\t%s\nCOIN\t%s\nCOIN PREV HASH\t%s\nSENDER\t%s\nRECEIVER\t%s\nAMOUNT\t%d\nMEMO\t%s\nR\t%s\nS\t%s\n",
+				tx.CurrHash, tx.PrevHash, tx.TimeStamp, tx.Message, tx.Coin, tx.CoinPrev, tx.Sender, tx.Receiver, tx.Amount, formatMemo(tx.Memo), tx.R, tx.S)
+		})
+	}
+	var txs []goofy.Transaction
+	var err error
+	if *memo != "" {
+		txs, err = c.api().TransactionsWithMemo(context.Background(), *memo)
+	} else {
+		txs, err = c.api().Transactions(context.Background())
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(txs, func(w io.Writer) { printTxs(w, txs) })
+}
+
+func (c *client) chainVerify() error {
+	st, err := c.api().VerifyChain(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := c.print(st, func(w io.Writer) {
+		fmt.Fprintf(w, "VALID\t%t\nLENGTH\t%d\n", st.Valid, st.Length)
+		if st.Error != "" {
+			fmt.Fprintf(w, "ERROR\t%s\n", st.Error)
+		}
+	}); err != nil {
+		return err
+	}
+	if !st.Valid {
+		return errors.New("chain verification failed")
+	}
+	return nil
+}
+
+func (c *client) invoiceCreate(args []string) error {
+	fs := flag.NewFlagSet("invoice create", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "key `FILE` of the recipient, the server signs if omitted")
+	memo := fs.String("memo", "", "`TEXT` shown to the payer")
+	expires := fs.Duration("expires", 24*time.Hour, "the invoice can be paid for `DURATION`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: goofy invoice create [-key FILE] [-memo TEXT] [-expires DURATION] AMOUNT")
+	}
+	amount, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	req := goofy.InvoiceRequest{Amount: amount, Memo: *memo, Expires: time.Now().Add(*expires).Unix()}
+	if *keyFile != "" {
+		priv, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		if req, err = goofy.SignInvoice(priv, req); err != nil {
+			return err
+		}
+	}
+	inv, err := c.api().CreateInvoice(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return c.print(inv, func(w io.Writer) { printInvoice(w, inv) })
+}
+
+/*
+	invoice() fetches the invoice of an ID or goofy:invoice URI and checks
+	its signature
+*/
+func (c *client) invoice(ref string) (*goofy.Invoice, error) {
+	id, err := uuid.FromString(ref)
+	if err != nil {
+		parsed, perr := goofy.ParseInvoiceURI(ref)
+		if perr != nil {
+			return nil, perr
+		}
+		id = parsed.ID
+	}
+	inv, err := c.api().Invoice(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	if inv.URI != ref && id.String() != ref {
+		return nil, errors.New("the server's invoice differs from " + ref)
+	}
+	return inv, goofy.VerifyInvoice(inv)
+}
+
+func (c *client) invoiceShow(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: goofy invoice show ID|URI")
+	}
+	inv, err := c.invoice(args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(inv, func(w io.Writer) { printInvoice(w, inv) })
+}
+
+func (c *client) invoicePay(args []string) error {
+	fs := flag.NewFlagSet("invoice pay", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "key `FILE` of the payer, the server signs if omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: goofy invoice pay [-key FILE] ID|URI COIN...")
+	}
+	inv, err := c.invoice(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	api := c.api()
+	var coins []goofy.Coin
+	for _, arg := range fs.Args()[1:] {
+		coinID, err := uuid.FromString(arg)
+		if err != nil {
+			return err
+		}
+		cn, err := api.Coin(context.Background(), coinID)
+		if err != nil {
+			return err
+		}
+		coins = append(coins, *cn)
+	}
+	req := goofy.PayInvoiceRequest{}
+	for _, cn := range coins {
+		req.Coins = append(req.Coins, goofy.InvoiceCoin{Coin: cn.UUID})
+	}
+	if *keyFile != "" {
+		priv, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		if req, err = goofy.SignInvoicePayment(priv, inv, coins); err != nil {
+			return err
+		}
+	}
+	inv, err = api.PayInvoice(context.Background(), inv.ID, req)
+	if err != nil {
+		return err
+	}
+	return c.print(inv, func(w io.Writer) { printInvoice(w, inv) })
+}
+
+/*
+	Utilities
+	___________________________________________________________________________
+*/
+
+/*
+	print() writes v as JSON or hands a tabwriter to table
+*/
+func (c *client) print(v interface{}, table func(w io.Writer)) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func printCoins(w io.Writer, coins []goofy.Coin) {
+	fmt.Fprintln(w, "UUID\tVALUE\tOWNER")
+	for _, cn := range coins {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", cn.UUID, cn.Value, cn.Owner)
+	}
+}
+
+func printTxs(w io.Writer, txs []goofy.Transaction) {
+	fmt.Fprintln(w, "HASH\tTIME\tMESSAGE\tMEMO")
+	for _, tx := range txs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", tx.CurrHash, tx.TimeStamp, tx.Message, formatMemo(tx.Memo))
+	}
+}
+
+/*
+	formatMemo() returns the key=value pairs of memo sorted by key
+*/
+func formatMemo(memo map[string]string) string {
+	pairs := make([]string, 0, len(memo))
+	for k, v := range memo {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+/*
+	memoFlag collects the -memo KEY=VALUE flags of pay
+*/
+type memoFlag map[string]string
+
+func (m memoFlag) String() string {
+	return formatMemo(m)
+}
+
+func (m memoFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return errors.New("memo " + strconv.Quote(s) + " is not KEY=VALUE")
+	}
+	m[k] = v
+	return nil
+}
+
+func printInvoice(w io.Writer, inv *goofy.Invoice) {
+	fmt.Fprintf(w, "ID\t%s\nRECIPIENT\t%s\nAMOUNT\t%d\nMEMO\t%s\nEXPIRES\t%s\nSTATE\t%s\nPAYMENTS\t%s\nURI\t%s\n",
+		inv.ID, inv.Recipient, inv.Amount, inv.Memo, time.Unix(inv.Expires, 0).UTC().Format(time.RFC3339), inv.State, strings.Join(inv.Payments, " "), inv.URI)
+}
+
+/*
+	readKey() reads a PEM encoded EC private key written by key gen
+*/
+func readKey(file string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	blk, _ := pem.Decode(data)
+	if blk == nil {
+		return nil, errors.New(file + ": invalid key PEM")
+	}
+	return x509.ParseECPrivateKey(blk.Bytes)
+}
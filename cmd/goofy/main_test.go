@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	goofy "github.com/de7ign/goofy-coin/client"
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+	"github.com/gofrs/uuid"
+)
+
+func TestPaySignsWithKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "alice.key")
+	c := &client{output: "table", out: &bytes.Buffer{}}
+	if err := c.run([]string{"key", "gen", "-out", keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	priv, err := readKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coinID := uuid.Must(uuid.NewV4())
+	receiver := uuid.Must(uuid.NewV4())
+	prevHash := []byte{1, 2, 3}
+	verified := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			json.NewEncoder(w).Encode(goofy.Coin{UUID: coinID, Value: 10, TxHash: hex.EncodeToString(prevHash)})
+			return
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		sigR, _ := new(big.Int).SetString(req["r"], 16)
+		sigS, _ := new(big.Int).SetString(req["s"], 16)
+		verified = ecdsa.Verify(&priv.PublicKey, crypto.SpendDigest(coinID, receiver, prevHash), sigR, sigS)
+		json.NewEncoder(w).Encode(goofy.Transaction{Coin: coinID, Receiver: receiver})
+	}))
+	defer srv.Close()
+
+	c.server = srv.URL
+	if err := c.run([]string{"pay", "-key", keyFile, coinID.String(), receiver.String()}); err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Error("server could not verify signature")
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"uuid":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","name":"goofy"}]`))
+	}))
+	defer srv.Close()
+
+	out := &bytes.Buffer{}
+	c := &client{server: srv.URL, output: "json", out: out}
+	if err := c.run([]string{"user", "list"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"name": "goofy"`) {
+		t.Errorf("unexpected output %s", out)
+	}
+}
+
+func TestCoinListFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": {"code": "internal", "message": "ledger unavailable"}}`))
+	}))
+	defer srv.Close()
+
+	c := &client{server: srv.URL, output: "table", out: &bytes.Buffer{}}
+	for _, args := range [][]string{{"coin", "list"}, {"coin", "list", "-owner", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}} {
+		if err := c.run(args); err == nil {
+			t.Errorf("%v ignored a failed request", args)
+		}
+	}
+}
+
+func TestTxShowMemo(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.String())
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := &client{server: srv.URL, output: "table", out: &bytes.Buffer{}}
+	if err := c.run([]string{"tx", "show", "-memo", "order=42"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || requests[0] != "/api/tx?memo=order%3D42" {
+		t.Errorf("memo search requested %v", requests)
+	}
+}
+
+func TestScriptAsm(t *testing.T) {
+	out := &bytes.Buffer{}
+	c := &client{output: "json", out: out}
+	if err := c.run([]string{"script", "asm", "OP_DUP", "OP_SHA256", "0x0102", "OP_EQUAL"}); err != nil {
+		t.Fatal(err)
+	}
+	var asm struct{ Hex, Asm string }
+	if err := json.Unmarshal(out.Bytes(), &asm); err != nil || asm.Hex != "76a802010287" {
+		t.Fatalf("assembled %s, %v", out, err)
+	}
+	out.Reset()
+	if err := c.run([]string{"script", "disasm", asm.Hex}); err != nil || !strings.Contains(out.String(), `"asm": "OP_DUP OP_SHA256 0x0102 OP_EQUAL"`) {
+		t.Errorf("disassembled %s, %v", out, err)
+	}
+	if err := c.run([]string{"script", "disasm", "0301"}); err == nil {
+		t.Error("truncated script disassembled")
+	}
+}
+
+func TestInvoiceShowChecksURI(t *testing.T) {
+	priv, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := goofy.SignInvoice(priv, goofy.InvoiceRequest{Amount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := uuid.Must(uuid.NewV4())
+	key := hex.EncodeToString(crypto.CompressPublicKey(&priv.PublicKey))
+	inv := goofy.Invoice{ID: req.ID, Recipient: recipient, PublicKey: key, Amount: 10, Expires: req.Expires, Signature: goofy.Signature{Signer: recipient, R: req.R, S: req.S}, State: "open"}
+	r, _ := new(big.Int).SetString(req.R, 16)
+	s, _ := new(big.Int).SetString(req.S, 16)
+	inv.URI = "goofy:invoice?amount=10&expires=" + strconv.FormatInt(req.Expires, 10) + "&id=" + req.ID.String() + "&key=" + key + "&sig=" + hex.EncodeToString(crypto.SignatureBytes(r, s)) + "&to=" + recipient.String()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(inv)
+	}))
+	defer srv.Close()
+
+	c := &client{server: srv.URL, output: "json", out: &bytes.Buffer{}}
+	if err := c.run([]string{"invoice", "show", inv.URI}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run([]string{"invoice", "show", strings.Replace(inv.URI, "amount=10", "amount=1", 1)}); err == nil {
+		t.Error("invoice differing from the URI shown")
+	}
+	inv.Amount = 1
+	if err := c.run([]string{"invoice", "show", inv.ID.String()}); err == nil {
+		t.Error("invoice with a forged amount verified")
+	}
+}
+
+func TestLoginSavesToken(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" {
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["password"] != "wonderland" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "unauthorized", "message": "invalid user name or password"}`))
+				return
+			}
+			w.Write([]byte(`{"token": "t0k3n", "user": {"name": "alice"}}`))
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "goofy", "token")
+	c := &client{server: srv.URL, output: "table", tokenFile: tokenFile, in: strings.NewReader("looking-glass\n"), out: &bytes.Buffer{}}
+	if err := c.run([]string{"login", "alice"}); err == nil || !strings.Contains(err.Error(), "invalid user name or password") {
+		t.Errorf("expected login failure, got %v", err)
+	}
+
+	c.in = strings.NewReader("wonderland\n")
+	if err := c.run([]string{"login", "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	c = &client{server: srv.URL, output: "table", tokenFile: tokenFile, out: &bytes.Buffer{}}
+	data, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.token = string(data)
+	if err := c.run([]string{"user", "list"}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer t0k3n" {
+		t.Errorf("request sent with Authorization %q", auth)
+	}
+}
+
+func TestLoginWithKeySignsChallenge(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "alice.key")
+	c := &client{output: "table", out: &bytes.Buffer{}}
+	if err := c.run([]string{"key", "gen", "-out", keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	priv, err := readKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := []byte("0123456789abcdef0123456789abcdef")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login/challenge" {
+			json.NewEncoder(w).Encode(map[string]string{"challenge": hex.EncodeToString(nonce)})
+			return
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		sigR, _ := new(big.Int).SetString(req["r"], 16)
+		sigS, _ := new(big.Int).SetString(req["s"], 16)
+		if !ecdsa.Verify(&priv.PublicKey, crypto.ChallengeDigest(nonce), sigR, sigS) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"token": "t0k3n"}`))
+	}))
+	defer srv.Close()
+
+	c.server = srv.URL
+	if err := c.run([]string{"login", "-key", keyFile, "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.token != "t0k3n" {
+		t.Errorf("token %q not kept", c.token)
+	}
+}
+
+func TestCoinSelect(t *testing.T) {
+	owner := uuid.Must(uuid.NewV4())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coins := []goofy.Coin{{UUID: uuid.Must(uuid.NewV4()), Value: 5}, {UUID: uuid.Must(uuid.NewV4()), Value: 4}, {UUID: uuid.Must(uuid.NewV4()), Value: 9, Lock: &goofy.Lock{Height: 10}}}
+		json.NewEncoder(w).Encode(goofy.Balance{User: owner, Balance: 18, Spendable: 9, Locked: 9, Coins: coins})
+	}))
+	defer srv.Close()
+
+	out := &bytes.Buffer{}
+	c := &client{server: srv.URL, output: "json", out: out}
+	if err := c.run([]string{"coin", "select", owner.String(), "7"}); err != nil {
+		t.Fatal(err)
+	}
+	var selection struct {
+		Coins  []goofy.Coin
+		Change int
+	}
+	if err := json.Unmarshal(out.Bytes(), &selection); err != nil || len(selection.Coins) != 2 || selection.Change != 2 {
+		t.Errorf("selected %s, %v", out, err)
+	}
+	if err := c.run([]string{"coin", "select", "-strategy", "exact-match", owner.String(), "7"}); err != goofy.ErrNoExactMatch {
+		t.Errorf("exact match of 7 returned %v", err)
+	}
+}
+
+func TestCoinHistory(t *testing.T) {
+	l := ledger.New()
+	g, _ := l.RegisterUser("goofy", nil, "")
+	alice, _ := l.RegisterUser("alice", nil, "")
+	cn, _ := l.Mint(g.UUID, 10)
+	if _, err := l.Transfer(g.UUID, cn.UUID, alice.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	goofyKey, _ := crypto.EncodePublicKey(g.PublicKey)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/info" {
+			json.NewEncoder(w).Encode(goofy.Info{GoofyPublicKey: goofyKey})
+			return
+		}
+		h, _ := l.History(cn.UUID)
+		json.NewEncoder(w).Encode(h)
+	}))
+	defer srv.Close()
+
+	out := &bytes.Buffer{}
+	c := &client{server: srv.URL, output: "table", out: out}
+	if err := c.run([]string{"coin", "history", cn.UUID.String()}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "VERIFIED  2 hops to "+alice.UUID.String()) {
+		t.Errorf("history printed %s", out)
+	}
+	aliceKey, _ := crypto.EncodePublicKey(alice.PublicKey)
+	keyFile := filepath.Join(t.TempDir(), "goofy.pub")
+	if err := ioutil.WriteFile(keyFile, []byte(aliceKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run([]string{"coin", "history", "-goofy-key", keyFile, cn.UUID.String()}); err == nil || !strings.Contains(err.Error(), "mint not signed by goofy") {
+		t.Errorf("history minted by another key returned %v", err)
+	}
+
+	// alice's spend is only checked against a key the caller trusts
+	if _, err := l.Transfer(alice.UUID, cn.UUID, g.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run([]string{"coin", "history", cn.UUID.String()}); err == nil || !strings.Contains(err.Error(), "not trusted") {
+		t.Errorf("history with an unknown sender returned %v", err)
+	}
+	keys, _ := json.Marshal(map[uuid.UUID]string{alice.UUID: string(aliceKey)})
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	if err := ioutil.WriteFile(keysFile, keys, 0600); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := c.run([]string{"coin", "history", "-keys", keysFile, cn.UUID.String()}); err != nil || !strings.Contains(out.String(), "VERIFIED  3 hops to "+g.UUID.String()) {
+		t.Errorf("history printed %s, %v", out, err)
+	}
+}
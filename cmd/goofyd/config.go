@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/de7ign/goofy-coin/wallet"
+)
+
+/*
+	config holds the server settings, every field can be set from
+	1. a JSON config file (-config / GOOFY_CONFIG)
+	2. environment variables GOOFY_*
+	3. command-line flags, except GoofyPassword
+	where later sources override earlier ones
+*/
+type config struct {
+	Addr      string `json:"addr"`
+	DataDir   string `json:"dataDir"`
+	KeyFile   string `json:"keyFile"`
+	StaticDir string `json:"staticDir"`
+	TLSCert   string `json:"tlsCert"`
+	TLSKey    string `json:"tlsKey"`
+
+	// BlockInterval is how often the open block is sealed, e.g. "10s"
+	BlockInterval string `json:"blockInterval"`
+	blockInterval time.Duration
+
+	// LocalWebhooks allows webhooks to loopback, link-local and private
+	// hosts, it is only read from the config file
+	LocalWebhooks bool `json:"localWebhooks"`
+
+	// LogLevel is the least severe level logged: debug, info, warn or error
+	LogLevel string `json:"logLevel"`
+	level    slog.Level
+
+	// GoofyPassword lets goofy log in with a password, it is only read from
+	// the config file or GOOFY_PASSWORD to keep it out of the process list
+	GoofyPassword string `json:"goofyPassword"`
+}
+
+/*
+	defaultConfig() returns the settings used when nothing else is provided,
+	an empty StaticDir serves the embedded dashboard
+*/
+func defaultConfig() config {
+	return config{Addr: ":8080", DataDir: "./data", BlockInterval: "10s", LogLevel: "info"}
+}
+
+/*
+	loadConfig() builds the config from defaults, config file, env and args
+*/
+func loadConfig(args []string, getenv func(string) string) (config, error) {
+	c := defaultConfig()
+
+	fs := flag.NewFlagSet("goofyd", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("GOOFY_CONFIG"), "JSON config `file`")
+	addr := fs.String("addr", "", "listen `address` (default \""+c.Addr+"\")")
+	dataDir := fs.String("data", "", "data `directory` (default \""+c.DataDir+"\")")
+	keyFile := fs.String("key", "", "goofy's PEM private key `file` (default <data>/goofy.key)")
+	staticDir := fs.String("static", "", "serve public/ and assets/ from `directory` instead of the embedded copy")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate `file`")
+	tlsKey := fs.String("tls-key", "", "TLS private key `file`")
+	blockInterval := fs.String("block-interval", "", "seal a block every `duration` (default \""+c.BlockInterval+"\")")
+	logLevel := fs.String("log-level", "", "log `level`: debug, info, warn or error (default \""+c.LogLevel+"\")")
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return c, err
+		}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return c, errors.New(*configFile + ": " + err.Error())
+		}
+	}
+
+	override := func(field *string, env string, flagValue string) {
+		if v := getenv(env); v != "" {
+			*field = v
+		}
+		if flagValue != "" {
+			*field = flagValue
+		}
+	}
+	override(&c.Addr, "GOOFY_ADDR", *addr)
+	override(&c.DataDir, "GOOFY_DATA_DIR", *dataDir)
+	override(&c.KeyFile, "GOOFY_KEY_FILE", *keyFile)
+	override(&c.StaticDir, "GOOFY_STATIC_DIR", *staticDir)
+	override(&c.TLSCert, "GOOFY_TLS_CERT", *tlsCert)
+	override(&c.TLSKey, "GOOFY_TLS_KEY", *tlsKey)
+	override(&c.GoofyPassword, "GOOFY_PASSWORD", "")
+	override(&c.BlockInterval, "GOOFY_BLOCK_INTERVAL", *blockInterval)
+	override(&c.LogLevel, "GOOFY_LOG_LEVEL", *logLevel)
+
+	if c.KeyFile == "" {
+		c.KeyFile = filepath.Join(c.DataDir, "goofy.key")
+	}
+	var err error
+	c.blockInterval, err = time.ParseDuration(c.BlockInterval)
+	if err != nil {
+		return c, errors.New("invalid block interval: " + err.Error())
+	}
+	if err := c.level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return c, errors.New("invalid log level " + c.LogLevel)
+	}
+	return c, c.validate()
+}
+
+/*
+	validate() checks the config before the server starts, the data directory
+	is created if missing
+*/
+func (c config) validate() error {
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return errors.New("invalid listen address: " + err.Error())
+	}
+	if c.DataDir == "" {
+		return errors.New("data directory must be set")
+	}
+	if c.blockInterval <= 0 {
+		return errors.New("block interval must be positive")
+	}
+	if err := os.MkdirAll(c.DataDir, 0700); err != nil {
+		return err
+	}
+	if c.GoofyPassword != "" {
+		if err := wallet.ValidatePassword(c.GoofyPassword); err != nil {
+			return errors.New("goofy password: " + err.Error())
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("tls-cert and tls-key must be set together")
+	}
+	for _, file := range []string{c.TLSCert, c.TLSKey} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+	if c.StaticDir == "" {
+		return nil
+	}
+	for _, file := range []string{"public/index.html", "public/dashboard.html", "assets/js", "assets/css", "assets/openapi.json"} {
+		if _, err := os.Stat(filepath.Join(c.StaticDir, file)); err != nil {
+			return errors.New("static directory: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "goofy.json")
+	err := ioutil.WriteFile(configFile, []byte(`{"addr": ":9000", "dataDir": "`+filepath.Join(dir, "file")+`"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"GOOFY_CONFIG": configFile, "GOOFY_DATA_DIR": filepath.Join(dir, "env")}
+
+	c, err := loadConfig([]string{"-addr", "127.0.0.1:9001"}, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != "127.0.0.1:9001" {
+		t.Errorf("flag did not override addr, got %s", c.Addr)
+	}
+	if c.DataDir != filepath.Join(dir, "env") {
+		t.Errorf("env did not override dataDir, got %s", c.DataDir)
+	}
+	if c.KeyFile != filepath.Join(dir, "env", "goofy.key") {
+		t.Errorf("key file does not default to data dir, got %s", c.KeyFile)
+	}
+	if c.StaticDir != "" {
+		t.Errorf("static dir does not default to embedded assets, got %s", c.StaticDir)
+	}
+	if c.level != slog.LevelInfo {
+		t.Errorf("log level does not default to info, got %s", c.level)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"bad address", []string{"-addr", "8080"}},
+		{"cert without key", []string{"-tls-cert", filepath.Join(dir, "cert.pem")}},
+		{"missing cert", []string{"-tls-cert", filepath.Join(dir, "cert.pem"), "-tls-key", filepath.Join(dir, "key.pem")}},
+		{"missing static files", []string{"-static", dir}},
+		{"unknown log level", []string{"-log-level", "verbose"}},
+	}
+	for _, tt := range tests {
+		args := append([]string{"-data", filepath.Join(dir, "data")}, tt.args...)
+		_, err := loadConfig(args, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("%s: config accepted", tt.name)
+		}
+	}
+}
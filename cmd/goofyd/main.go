@@ -0,0 +1,73 @@
+/*
+	Command goofyd runs a goofy coin node, it serves the API and dashboard
+	of a ledger journaled to its data directory
+*/
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	goofycoin "github.com/de7ign/goofy-coin"
+	"github.com/de7ign/goofy-coin/api"
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+/*
+	version is set at build time with -ldflags "-X main.version=..."
+*/
+var version = "dev"
+
+/*
+	logLevel drops log messages below the configured level
+*/
+var logLevel = new(slog.LevelVar)
+
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+
+/*
+	fatal() logs err and exits
+*/
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func main() {
+	conf, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		fatal("invalid config", err)
+	}
+	logLevel.Set(conf.level)
+	slog.SetDefault(logger)
+	goofyKey, err := crypto.LoadOrCreateKey(conf.KeyFile)
+	if err != nil {
+		fatal("cannot load goofy's key", err)
+	}
+
+	l := ledger.New()
+	srv, err := api.New(l, api.Options{Static: goofycoin.Static, StaticDir: conf.StaticDir, Version: version, Logger: logger, LocalWebhooks: conf.LocalWebhooks})
+	if err != nil {
+		fatal("cannot load assets", err)
+	}
+	n := &node{conf: conf, ledger: l, api: srv, key: goofyKey}
+
+	ln, err := net.Listen("tcp", conf.Addr)
+	if err != nil {
+		fatal("cannot listen", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Info("App running", "addr", ln.Addr().String(), "tls", conf.TLSCert != "")
+	err = n.run(ctx, &http.Server{Handler: srv.Handler()}, ln)
+	if err != nil {
+		fatal("server stopped", err)
+	}
+	logger.Info("server stopped")
+}
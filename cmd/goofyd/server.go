@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/de7ign/goofy-coin/api"
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+/*
+	Server Lifecycle
+	___________________________________________________________________________
+
+	run() serves until ctx is cancelled, on SIGINT or SIGTERM in main(). It
+	then stops accepting connections, waits for in-flight requests, stops the
+	block sealer and webhook deliveries and closes the ledger journal, so a
+	transaction is either fully journaled or was never acknowledged
+*/
+
+const shutdownTimeout = 30 * time.Second
+
+/*
+	node is the ledger served by goofyd, key is goofy's private key
+*/
+type node struct {
+	conf   config
+	ledger *ledger.Ledger
+	api    *api.Server
+	key    *ecdsa.PrivateKey
+}
+
+/*
+	run() loads the ledger and serves srv on ln until ctx is done, serving
+	fails or the ledger cannot be loaded. Open event streams end when the
+	shutdown begins
+*/
+func (n *node) run(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	base, endStreams := context.WithCancel(context.Background())
+	defer endStreams()
+	srv.BaseContext = func(net.Listener) context.Context { return base }
+	srv.RegisterOnShutdown(endStreams)
+
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	failed := make(chan error, 1)
+	go func() {
+		defer wg.Done()
+		if err := n.api.Start(n.conf.DataDir, n.key, n.conf.GoofyPassword); err != nil {
+			failed <- errors.New("cannot load ledger: " + err.Error())
+			return
+		}
+		n.ledger.SealBlocks(workers, n.conf.blockInterval)
+	}()
+
+	served := make(chan error, 1)
+	go func() {
+		if n.conf.TLSCert != "" {
+			served <- srv.ServeTLS(ln, n.conf.TLSCert, n.conf.TLSKey)
+			return
+		}
+		served <- srv.Serve(ln)
+	}()
+
+	shutdown := func() error {
+		timeout, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := srv.Shutdown(timeout)
+		if serveErr := <-served; !errors.Is(serveErr, http.ErrServerClosed) {
+			err = serveErr
+		}
+		return err
+	}
+
+	var err error
+	select {
+	case err = <-served:
+	case err = <-failed:
+		shutdown()
+	case <-ctx.Done():
+		logger.Info("shutting down")
+		err = shutdown()
+	}
+
+	stopWorkers()
+	wg.Wait()
+	if closeErr := n.api.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/de7ign/goofy-coin/api"
+	goofy "github.com/de7ign/goofy-coin/client"
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/ledger"
+)
+
+func TestCorruptJournal(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ledger.jsonl"), []byte("not a record\n{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := ledger.New()
+	srv, err := api.New(l, api.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &node{conf: config{DataDir: dir, blockInterval: time.Second}, ledger: l, api: srv, key: key}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- n.run(context.Background(), &http.Server{Handler: srv.Handler()}, ln) }()
+	select {
+	case err := <-stopped:
+		if err == nil || !strings.Contains(err.Error(), "cannot load ledger") {
+			t.Errorf("run returned %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("server kept running without a ledger")
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := ledger.New()
+	srv, err := api.New(l, api.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &node{conf: config{DataDir: dir, GoofyPassword: "goofy-password", blockInterval: 5 * time.Millisecond}, ledger: l, api: srv, key: key}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + ln.Addr().String()
+	ctx, shutdown := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- n.run(ctx, &http.Server{Handler: srv.Handler()}, ln) }()
+
+	for wait := 0; ; wait++ {
+		res, err := http.Get(url + "/readyz")
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		if wait == 500 {
+			t.Fatal("ledger not ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c := goofy.New(url)
+	if _, err := c.Login(context.Background(), "goofy", "goofy-password"); err != nil {
+		t.Fatal(err)
+	}
+	token := c.Token
+
+	// an open event stream must not hold up the shutdown
+	req, _ := http.NewRequest("GET", url+"/api/events?token="+token, nil)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+
+	// mint until the server goes away, counting the acknowledged mints
+	var mu sync.Mutex
+	acked := 0
+	var clients sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for {
+				req, _ := http.NewRequest("POST", url+"/api/coin", strings.NewReader(`{"amount": 1}`))
+				req.Header.Set("Authorization", "Bearer "+token)
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					return
+				}
+				res.Body.Close()
+				if res.StatusCode != http.StatusOK {
+					return
+				}
+				mu.Lock()
+				acked++
+				mu.Unlock()
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	shutdown()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	clients.Wait()
+	if acked == 0 {
+		t.Fatal("no mint acknowledged before the shutdown")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		t.Error("journal ends in a partial record")
+	}
+	restored := ledger.New()
+	st, err := restored.OpenStore(filepath.Join(dir, "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if len(restored.Coins()) != acked {
+		t.Errorf("journal holds %d mints, %d were acknowledged", len(restored.Coins()), acked)
+	}
+}
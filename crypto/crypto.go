@@ -0,0 +1,293 @@
+/*
+	Package crypto holds the ECDSA P-256 keys, signatures and digests of goofy
+	coin. A coin owner signs SpendDigest() to pass the coin on, a user signs
+	ChallengeDigest() to log in with a key
+*/
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/gofrs/uuid"
+)
+
+/*
+	GenerateKeyPair() generates a P-256 private and public key pair
+*/
+func GenerateKeyPair() (*ecdsa.PrivateKey, *ecdsa.PublicKey, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, &privateKey.PublicKey, nil
+}
+
+/*
+	Sign() signs digest with priv
+*/
+func Sign(priv *ecdsa.PrivateKey, digest []byte) (*big.Int, *big.Int, error) {
+	return ecdsa.Sign(rand.Reader, priv, digest)
+}
+
+/*
+	Verify() reports whether r and s sign digest for pub
+*/
+func Verify(pub *ecdsa.PublicKey, digest []byte, r, s *big.Int) bool {
+	return ecdsa.Verify(pub, digest, r, s)
+}
+
+/*
+	ParseSignature() decodes the hex r and s of a signature
+*/
+func ParseSignature(r, s string) (*big.Int, *big.Int, error) {
+	sigR, okR := new(big.Int).SetString(r, 16)
+	sigS, okS := new(big.Int).SetString(s, 16)
+	if !okR || !okS {
+		return nil, nil, errors.New("malformed signature")
+	}
+	return sigR, sigS, nil
+}
+
+/*
+	SpendDigest() returns the digest an owner signs to pass a coin to receiver,
+	prevHash is the hash of the transaction which last moved the coin
+*/
+func SpendDigest(coinID uuid.UUID, receiver uuid.UUID, prevHash []byte) []byte {
+	data := bytes.Join([][]byte{coinID.Bytes(), receiver.Bytes(), prevHash}, []byte{})
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+/*
+	LockedSpendDigest() returns the digest an owner signs to pass a coin to
+	receiver which cannot spend it before block lockHeight and unix time
+	lockTime, it is SpendDigest() when neither is set
+*/
+func LockedSpendDigest(coinID uuid.UUID, receiver uuid.UUID, prevHash []byte, lockHeight int, lockTime int64) []byte {
+	if lockHeight == 0 && lockTime == 0 {
+		return SpendDigest(coinID, receiver, prevHash)
+	}
+	lock := []byte("lock:" + strconv.Itoa(lockHeight) + ":" + strconv.FormatInt(lockTime, 10))
+	data := bytes.Join([][]byte{coinID.Bytes(), receiver.Bytes(), prevHash, lock}, []byte{})
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+/*
+	SignatureBytes() returns r and s as 64 bytes, each padded to 32, the
+	signature format of scripts
+*/
+func SignatureBytes(r, s *big.Int) []byte {
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig
+}
+
+/*
+	SplitSignature() decodes a signature in the format of SignatureBytes()
+*/
+func SplitSignature(sig []byte) (*big.Int, *big.Int, error) {
+	if len(sig) != 64 {
+		return nil, nil, errors.New("malformed signature")
+	}
+	return new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]), nil
+}
+
+/*
+	CompressPublicKey() returns pub as a 33 byte compressed point, the public
+	key format of scripts
+*/
+func CompressPublicKey(pub *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(elliptic.P256(), pub.X, pub.Y)
+}
+
+/*
+	DecompressPublicKey() decodes a key in the format of CompressPublicKey()
+*/
+func DecompressPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), data)
+	if x == nil {
+		return nil, errors.New("malformed public key")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+/*
+	ScriptSpendDigest() returns the digest an owner signs to pass a coin to
+	receiver locked with the locking script, and the digest the CHECKSIG
+	operations of a script check when the coin moves on
+*/
+func ScriptSpendDigest(coinID uuid.UUID, receiver uuid.UUID, prevHash []byte, script []byte) []byte {
+	data := bytes.Join([][]byte{coinID.Bytes(), receiver.Bytes(), prevHash, []byte("script:"), script}, []byte{})
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+/*
+	HashLockedSpendDigest() returns the digest an owner signs to pass a coin
+	to receiver which can claim it with the SHA-256 preimage of hash, or
+	which the owner takes back once block timeoutHeight is sealed and unix
+	time timeoutTime has passed
+*/
+func HashLockedSpendDigest(coinID uuid.UUID, receiver uuid.UUID, prevHash []byte, hash []byte, timeoutHeight int, timeoutTime int64) []byte {
+	htlc := []byte("htlc:" + hex.EncodeToString(hash) + ":" + strconv.Itoa(timeoutHeight) + ":" + strconv.FormatInt(timeoutTime, 10))
+	data := bytes.Join([][]byte{coinID.Bytes(), receiver.Bytes(), prevHash, htlc}, []byte{})
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+/*
+	InvoiceDigest() returns the digest the recipient signs to request amount
+	to its compressed public key until unix time expires
+*/
+func InvoiceDigest(id uuid.UUID, key []byte, amount int, memo string, expires int64) []byte {
+	terms := []byte("invoice:" + strconv.Itoa(amount) + ":" + strconv.FormatInt(expires, 10) + ":" + memo)
+	data := bytes.Join([][]byte{id.Bytes(), key, terms}, []byte{})
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+/*
+	SplitMergeDigest() returns the digest an owner signs to turn its coins,
+	each with the hash of its last transaction in prevHashes, into new coins
+	of values. A split has one coin and a merge one value
+*/
+func SplitMergeDigest(coins []uuid.UUID, prevHashes [][]byte, values []int) []byte {
+	data := [][]byte{}
+	for i, coin := range coins {
+		data = append(data, coin.Bytes(), prevHashes[i])
+	}
+	outputs := "split:"
+	for _, v := range values {
+		outputs += strconv.Itoa(v) + ":"
+	}
+	data = append(data, []byte(outputs))
+	digest := sha256.Sum256(bytes.Join(data, []byte{}))
+	return digest[:]
+}
+
+/*
+	MemoBytes() returns the canonical encoding of a key-value memo, its
+	entries sorted by key and each string prefixed with its length, nil for
+	an empty memo
+*/
+func MemoBytes(memo map[string]string) []byte {
+	if len(memo) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(memo))
+	for k := range memo {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b []byte
+	for _, k := range keys {
+		for _, s := range []string{k, memo[k]} {
+			b = append(b, strconv.Itoa(len(s))+":"+s...)
+		}
+	}
+	return b
+}
+
+/*
+	MemoDigest() returns the digest an owner signs to pass a coin with memo,
+	the spend digest of the transfer when memo is empty
+*/
+func MemoDigest(spendDigest []byte, memo map[string]string) []byte {
+	if len(memo) == 0 {
+		return spendDigest
+	}
+	data := bytes.Join([][]byte{spendDigest, []byte("memo:"), MemoBytes(memo)}, []byte{})
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+/*
+	ChallengeDigest() returns the digest a user signs to log in with nonce,
+	the prefix keeps it apart from SpendDigest(). It equals SHA-256 over the
+	prefix and nonce, so WebCrypto's ECDSA with SHA-256 can sign the bytes
+	directly
+*/
+func ChallengeDigest(nonce []byte) []byte {
+	hash := sha256.Sum256(append([]byte("goofy-coin login:"), nonce...))
+	return hash[:]
+}
+
+/*
+	LoadOrCreateKey() reads a PEM encoded EC private key from file, a new key
+	is generated and written to file if it does not exist
+*/
+func LoadOrCreateKey(file string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		privateKey, _, err := GenerateKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		data = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		return privateKey, os.WriteFile(file, data, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	blk, _ := pem.Decode(data)
+	if blk == nil {
+		return nil, errors.New(file + ": invalid key PEM")
+	}
+	privateKey, err := x509.ParseECPrivateKey(blk.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if privateKey.Curve != elliptic.P256() {
+		return nil, errors.New(file + ": key is not ECDSA P-256")
+	}
+	return privateKey, nil
+}
+
+/*
+	ParsePublicKey() decodes a PEM encoded PKIX ECDSA P-256 public key
+*/
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	blk, _ := pem.Decode(data)
+	if blk == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+	key, err := x509.ParsePKIXPublicKey(blk.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return nil, errors.New("public key is not ECDSA P-256")
+	}
+	return pub, nil
+}
+
+/*
+	EncodePublicKey() returns pub as PEM encoded PKIX, the format
+	ParsePublicKey() reads
+*/
+func EncodePublicKey(pub *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
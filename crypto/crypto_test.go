@@ -0,0 +1,157 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestGenerateKeyPair(t *testing.T) {
+	_, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Error("cannot generate keypair")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Error("cannot generate kepair")
+	}
+	payload := SpendDigest(uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), nil)
+	r, s, err := Sign(priv, payload)
+	if err != nil {
+		t.Error("cannot sign payload")
+	}
+	if !Verify(pub, payload, r, s) {
+		t.Error("verification failed")
+	}
+	sigR, sigS, err := ParseSignature(r.Text(16), s.Text(16))
+	if err != nil || !Verify(pub, payload, sigR, sigS) {
+		t.Error("signature does not survive hex encoding")
+	}
+	if _, _, err := ParseSignature("xyz", "1"); err == nil {
+		t.Error("malformed signature parsed")
+	}
+}
+
+func TestLockedSpendDigest(t *testing.T) {
+	coin, receiver := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	if !bytes.Equal(LockedSpendDigest(coin, receiver, nil, 0, 0), SpendDigest(coin, receiver, nil)) {
+		t.Error("unlocked digest differs from the spend digest")
+	}
+	if bytes.Equal(LockedSpendDigest(coin, receiver, nil, 5, 0), SpendDigest(coin, receiver, nil)) ||
+		bytes.Equal(LockedSpendDigest(coin, receiver, nil, 5, 0), LockedSpendDigest(coin, receiver, nil, 0, 5)) {
+		t.Error("lock not covered by the digest")
+	}
+}
+
+func TestHashLockedSpendDigest(t *testing.T) {
+	coin, receiver := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	hash := sha256.Sum256([]byte("secret"))
+	digest := HashLockedSpendDigest(coin, receiver, nil, hash[:], 5, 0)
+	if bytes.Equal(digest, LockedSpendDigest(coin, receiver, nil, 5, 0)) ||
+		bytes.Equal(digest, HashLockedSpendDigest(coin, receiver, nil, hash[:1], 5, 0)) {
+		t.Error("hash lock not covered by the digest")
+	}
+}
+
+func TestInvoiceDigest(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+	digest := InvoiceDigest(id, []byte{2, 1}, 10, "tea", 100)
+	if bytes.Equal(digest, InvoiceDigest(id, []byte{2, 1}, 1, "0:tea", 100)) ||
+		bytes.Equal(digest, InvoiceDigest(id, []byte{2, 1}, 10, "tea", 101)) ||
+		bytes.Equal(digest, InvoiceDigest(id, []byte{2, 2}, 10, "tea", 100)) {
+		t.Error("invoice terms not covered by the digest")
+	}
+}
+
+func TestSplitMergeDigest(t *testing.T) {
+	a, b := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	digest := SplitMergeDigest([]uuid.UUID{a}, [][]byte{{1}}, []int{3, 7})
+	if bytes.Equal(digest, SplitMergeDigest([]uuid.UUID{a}, [][]byte{{1}}, []int{37})) ||
+		bytes.Equal(digest, SplitMergeDigest([]uuid.UUID{a}, [][]byte{{2}}, []int{3, 7})) ||
+		bytes.Equal(digest, SplitMergeDigest([]uuid.UUID{b}, [][]byte{{1}}, []int{3, 7})) ||
+		bytes.Equal(digest, SplitMergeDigest([]uuid.UUID{a}, [][]byte{{1}}, []int{7, 3})) {
+		t.Error("coins or values not covered by the digest")
+	}
+}
+
+func TestMemoDigest(t *testing.T) {
+	digest := SpendDigest(uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), nil)
+	if !bytes.Equal(MemoDigest(digest, nil), digest) {
+		t.Error("digest without memo differs from the spend digest")
+	}
+	memo := map[string]string{"order": "42", "note": "tea"}
+	if bytes.Equal(MemoDigest(digest, memo), digest) ||
+		bytes.Equal(MemoDigest(digest, memo), MemoDigest(digest, map[string]string{"order": "42", "note": "te"})) ||
+		bytes.Equal(MemoBytes(map[string]string{"a": "b:1:c"}), MemoBytes(map[string]string{"a": "b", "c": ""})) {
+		t.Error("memo not covered by the digest")
+	}
+}
+
+func TestScriptEncoding(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := SpendDigest(uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), nil)
+	r, s, err := Sign(priv, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigR, sigS, err := SplitSignature(SignatureBytes(r, s))
+	if err != nil || !Verify(pub, digest, sigR, sigS) {
+		t.Errorf("signature not restored: %v", err)
+	}
+	key, err := DecompressPublicKey(CompressPublicKey(pub))
+	if err != nil || !key.Equal(pub) {
+		t.Errorf("public key not restored: %v", err)
+	}
+	if _, err := DecompressPublicKey([]byte{2, 1}); err == nil {
+		t.Error("malformed public key decoded")
+	}
+}
+
+func TestPublicKeyPEM(t *testing.T) {
+	_, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodePublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePublicKey([]byte(data))
+	if err != nil || !parsed.Equal(pub) {
+		t.Errorf("public key not restored: %v", err)
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "goofy.key")
+	created, err := LoadOrCreateKey(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateKey(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.Equal(loaded) {
+		t.Error("loaded key differs from created key")
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(hash, "wonderland") || CheckPassword(hash, "looking-glass") || CheckPassword(nil, "") {
+		t.Error("password check failed")
+	}
+}
@@ -0,0 +1,43 @@
+package crypto
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+const (
+	passwordSaltLen    = 16
+	passwordKeyLen     = 32
+	passwordIterations = 100000
+)
+
+/*
+	HashPassword() returns a random salt followed by the PBKDF2 key of password
+*/
+func HashPassword(password string) ([]byte, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	return append(salt, key...), nil
+}
+
+/*
+	CheckPassword() compares password against a hash from HashPassword()
+*/
+func CheckPassword(hash []byte, password string) bool {
+	if len(hash) != passwordSaltLen+passwordKeyLen {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, hash[:passwordSaltLen], passwordIterations, passwordKeyLen)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, hash[passwordSaltLen:]) == 1
+}
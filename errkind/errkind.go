@@ -0,0 +1,54 @@
+/*
+	Package errkind classifies the errors of the ledger and the API, every
+	error wraps one kind so the API can answer it with the matching status
+*/
+package errkind
+
+import "errors"
+
+/*
+	Error kinds, test for them with errors.Is()
+*/
+var (
+	BadRequest   = errors.New("bad request")
+	Unauthorized = errors.New("unauthorized")
+	Forbidden    = errors.New("forbidden")
+	NotFound     = errors.New("not found")
+	Conflict     = errors.New("conflict")
+	DoubleSpend  = errors.New("coin already spent")
+	Invalid      = errors.New("validation failed")
+	Unavailable  = errors.New("unavailable")
+)
+
+/*
+	Error is a message of a given kind
+*/
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.Kind }
+
+/*
+	New() returns an error of kind with message
+*/
+func New(kind error, message string) error {
+	return &Error{kind, message}
+}
+
+/*
+	Malformed() marks err, e.g. of a JSON decoder, as a bad request
+*/
+func Malformed(err error) error {
+	return &Error{BadRequest, err.Error()}
+}
+
+/*
+	Failed() returns a validation error with message
+*/
+func Failed(message string) error {
+	return &Error{Invalid, message}
+}
@@ -0,0 +1,199 @@
+package ledger
+
+import (
+	"sync"
+
+	"github.com/de7ign/goofy-coin/wallet"
+)
+
+/*
+	Event Bus
+	___________________________________________________________________________
+
+	Ledger mutations publish a typed event instead of calling their
+	consumers. Synchronous subscribers run in the publisher's goroutine while
+	it holds the ledger's lock, so they must be quick and must not call the
+	Ledger. Asynchronous subscribers receive events on a buffered channel.
+	Neither may subscribe or cancel from within a publish. Events carry
+	copies of coins and transactions or blocks which are not changed later,
+	so subscribers can read them without the lock
+*/
+
+/*
+	Event is one of UserCreated, MultisigCreated, EscrowChanged,
+	InvoiceChanged, CoinMinted, CoinTransferred, CoinsSplit, CoinsMerged,
+	BlockSealed and Reorg
+*/
+type Event interface {
+	// Type names the event: user, multisig, escrow, invoice, mint, transfer,
+	// split, merge, block or reorg
+	Type() string
+	// Payload is the value the API reports for the event
+	Payload() interface{}
+}
+
+type UserCreated struct {
+	User wallet.User
+}
+
+type MultisigCreated struct {
+	Multisig Multisig
+}
+
+/*
+	EscrowChanged is published when an escrow is opened and whenever its
+	state changes
+*/
+type EscrowChanged struct {
+	Escrow Escrow
+}
+
+/*
+	InvoiceChanged is published when an invoice is issued and when it is
+	paid
+*/
+type InvoiceChanged struct {
+	Invoice Invoice
+}
+
+type CoinMinted struct {
+	Tx   *Transaction
+	Coin Coin
+}
+
+type CoinTransferred struct {
+	Tx   *Transaction
+	Coin Coin
+}
+
+/*
+	CoinsSplit carries the coins a split created
+*/
+type CoinsSplit struct {
+	Tx    *Transaction
+	Coins []Coin
+}
+
+/*
+	CoinsMerged carries the coin a merge created
+*/
+type CoinsMerged struct {
+	Tx   *Transaction
+	Coin Coin
+}
+
+type BlockSealed struct {
+	Block *Block
+}
+
+/*
+	Reorg replaces the chain above Height, its tip From by To. The ledger
+	has a single writer and never publishes it yet
+*/
+type Reorg struct {
+	Height int      `json:"height"`
+	From   HexBytes `json:"from"`
+	To     HexBytes `json:"to"`
+}
+
+func (e UserCreated) Type() string     { return "user" }
+func (e MultisigCreated) Type() string { return "multisig" }
+func (e EscrowChanged) Type() string   { return "escrow" }
+func (e InvoiceChanged) Type() string  { return "invoice" }
+func (e CoinMinted) Type() string      { return "mint" }
+func (e CoinTransferred) Type() string { return "transfer" }
+func (e CoinsSplit) Type() string      { return "split" }
+func (e CoinsMerged) Type() string     { return "merge" }
+func (e BlockSealed) Type() string     { return "block" }
+func (e Reorg) Type() string           { return "reorg" }
+
+func (e UserCreated) Payload() interface{}     { return e.User }
+func (e MultisigCreated) Payload() interface{} { return e.Multisig }
+func (e EscrowChanged) Payload() interface{}   { return e.Escrow }
+func (e InvoiceChanged) Payload() interface{}  { return e.Invoice }
+func (e CoinMinted) Payload() interface{}      { return e.Tx }
+func (e CoinTransferred) Payload() interface{} { return e.Tx }
+func (e CoinsSplit) Payload() interface{}      { return e.Tx }
+func (e CoinsMerged) Payload() interface{}     { return e.Tx }
+func (e BlockSealed) Payload() interface{}     { return e.Block }
+func (e Reorg) Payload() interface{}           { return e }
+
+type syncSubscriber struct {
+	id int
+	fn func(Event)
+}
+
+type bus struct {
+	mu     sync.Mutex
+	nextID int
+	sync   []syncSubscriber
+	async  map[int]chan Event
+}
+
+/*
+	Subscribe() calls fn with every event of l until cancel is called
+*/
+func (l *Ledger) Subscribe(fn func(Event)) (cancel func()) {
+	return l.bus.subscribe(fn)
+}
+
+/*
+	SubscribeAsync() returns a channel receiving every event of l until
+	cancel is called, events are dropped while its buffer is full
+*/
+func (l *Ledger) SubscribeAsync(buffer int) (<-chan Event, func()) {
+	return l.bus.subscribeAsync(buffer)
+}
+
+func (b *bus) subscribe(fn func(Event)) (cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.sync = append(b.sync, syncSubscriber{id, fn})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.sync {
+			if s.id == id {
+				b.sync = append(b.sync[:i:i], b.sync[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (b *bus) subscribeAsync(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.async == nil {
+		b.async = map[int]chan Event{}
+	}
+	id := b.nextID
+	b.nextID++
+	ch := make(chan Event, buffer)
+	b.async[id] = ch
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.async, id)
+		b.mu.Unlock()
+	}
+}
+
+/*
+	publish() runs the synchronous subscribers in order of subscription and
+	then hands e to the asynchronous ones without blocking
+*/
+func (b *bus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.sync {
+		s.fn(e)
+	}
+	for _, ch := range b.async {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ledger
+
+import "testing"
+
+func TestEventBus(t *testing.T) {
+	b := &bus{}
+	var got []string
+	cancelFirst := b.subscribe(func(e Event) { got = append(got, "first "+e.Type()) })
+	cancelSecond := b.subscribe(func(e Event) { got = append(got, "second "+e.Type()) })
+	ch, cancelAsync := b.subscribeAsync(1)
+
+	b.publish(UserCreated{})
+	b.publish(BlockSealed{&Block{}})
+	cancelFirst()
+	b.publish(CoinMinted{})
+
+	want := []string{"first user", "second user", "first block", "second block", "second mint"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+
+	// the buffer holds one event, the others are dropped
+	if e := <-ch; e.Type() != "user" {
+		t.Errorf("async subscriber got %s first", e.Type())
+	}
+	select {
+	case e := <-ch:
+		t.Errorf("full buffer received %s", e.Type())
+	default:
+	}
+	cancelAsync()
+	cancelSecond()
+	b.publish(Reorg{})
+	if len(got) != len(want) || len(ch) != 0 {
+		t.Error("cancelled subscriber received an event")
+	}
+}
+
+func TestLedgerEvents(t *testing.T) {
+	l := New()
+	var got []Event
+	defer l.Subscribe(func(e Event) { got = append(got, e) })()
+
+	goofy, err := l.RegisterUser("goofy", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := l.RegisterUser("alice", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Tx, err := l.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := l.SealBlock()
+
+	if len(got) != 5 {
+		t.Fatalf("got %d events, want 5", len(got))
+	}
+	if e, ok := got[1].(UserCreated); !ok || e.User.UUID != alice.UUID {
+		t.Errorf("unexpected event %#v", got[1])
+	}
+	if e, ok := got[2].(CoinMinted); !ok || e.Coin.UUID != c.UUID {
+		t.Errorf("unexpected event %#v", got[2])
+	}
+	if e, ok := got[3].(CoinTransferred); !ok || e.Tx != Tx || e.Coin.Owner != alice.UUID {
+		t.Errorf("unexpected event %#v", got[3])
+	}
+	if e, ok := got[4].(BlockSealed); !ok || e.Block != b {
+		t.Errorf("unexpected event %#v", got[4])
+	}
+}
@@ -0,0 +1,246 @@
+package ledger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Escrow
+	___________________________________________________________________________
+
+	An escrow holds a coin of the buyer in a 2-of-3 multisig of the buyer,
+	the seller and an arbiter. Any two of them pass it on: buyer and seller
+	agree, or the arbiter sides with one of them. The coin may only go to
+	the seller, which releases the escrow, or back to the buyer, which
+	refunds it. Either party may dispute a funded escrow, from then on the
+	arbiter must be one of the two signers
+*/
+
+/*
+	EscrowState is funded until the coin is released or refunded, or
+	disputed in between
+*/
+type EscrowState string
+
+const (
+	EscrowFunded   EscrowState = "funded"
+	EscrowDisputed EscrowState = "disputed"
+	EscrowReleased EscrowState = "released"
+	EscrowRefunded EscrowState = "refunded"
+)
+
+var ErrEscrowNotFound = &errkind.Error{Kind: errkind.NotFound, Message: "escrow not found"}
+
+/*
+	Escrow is a coin held by Multisig for the buyer and the seller, Funding
+	is the hash of the Tx passing it to the multisig and Settlement of the
+	Tx passing it on
+*/
+type Escrow struct {
+	ID         uuid.UUID   `json:"id"`
+	Multisig   uuid.UUID   `json:"multisig"`
+	Coin       uuid.UUID   `json:"coin"`
+	Amount     int         `json:"amount"`
+	Buyer      uuid.UUID   `json:"buyer"`
+	Seller     uuid.UUID   `json:"seller"`
+	Arbiter    uuid.UUID   `json:"arbiter"`
+	State      EscrowState `json:"state"`
+	Funding    HexBytes    `json:"funding"`
+	Settlement HexBytes    `json:"settlement,omitempty"`
+}
+
+/*
+	Open() reports whether the coin of e is still held
+*/
+func (e *Escrow) Open() bool {
+	return e.State == EscrowFunded || e.State == EscrowDisputed
+}
+
+/*
+	HasParty() reports whether id is the buyer, the seller or the arbiter
+*/
+func (e *Escrow) HasParty(id uuid.UUID) bool {
+	return id == e.Buyer || id == e.Seller || id == e.Arbiter
+}
+
+/*
+	allows() checks that the multisig spend of e's coin to receiver signed
+	by sigs settles it
+*/
+func (e *Escrow) allows(receiver uuid.UUID, sigs []Signature) error {
+	if receiver != e.Seller && receiver != e.Buyer {
+		return errkind.New(errkind.Forbidden, "an escrowed coin goes to the seller or back to the buyer")
+	}
+	if e.State != EscrowDisputed {
+		return nil
+	}
+	for _, sig := range sigs {
+		if sig.Signer == e.Arbiter {
+			return nil
+		}
+	}
+	return errkind.New(errkind.Forbidden, "a disputed escrow is settled with the arbiter")
+}
+
+/*
+	OpenEscrow() holds coinID of buyer in escrow for seller with arbiter. A
+	coin of the buyer is passed to a new 2-of-3 multisig signed with the
+	buyer's key held by the ledger, a buyer holding its own key creates the
+	multisig and pays the coin to it first. The other owners of a multisig
+	cannot open an escrow of a coin the buyer did not pay in
+*/
+func (l *Ledger) OpenEscrow(buyer uuid.UUID, seller uuid.UUID, arbiter uuid.UUID, coinID uuid.UUID) (Escrow, error) {
+	if buyer == seller || buyer == arbiter || seller == arbiter {
+		return Escrow{}, errkind.Failed("buyer, seller and arbiter must be different users")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, err := l.coin(coinID)
+	if err != nil {
+		return Escrow{}, err
+	}
+	if l.escrowOf(c.UUID) != nil {
+		return Escrow{}, errkind.New(errkind.Conflict, "coin is already in escrow")
+	}
+	if c.HashLock != nil || c.Script != nil {
+		return Escrow{}, errkind.Failed("a hash locked or scripted coin cannot be escrowed")
+	}
+	owners := []uuid.UUID{buyer, seller, arbiter}
+	var ms Multisig
+	if c.Owner == buyer {
+		u, err := l.users.User(buyer)
+		if err != nil {
+			return Escrow{}, err
+		}
+		if u.PrivateKey == nil {
+			return Escrow{}, ErrSignatureRequired
+		}
+		if err := c.Lock.check(len(l.chain), time.Now().Unix()); err != nil {
+			return Escrow{}, err
+		}
+		if ms, err = l.createMultisig(2, owners); err != nil {
+			return Escrow{}, err
+		}
+		if _, err := l.pay(Payment{Spender: buyer, Coin: c.UUID, Receiver: ms.UUID}); err != nil {
+			return Escrow{}, err
+		}
+	} else {
+		ms, err = l.multisig(c.Owner)
+		if err != nil || ms.M != 2 || len(ms.Owners) != 3 || !ms.HasOwner(buyer) || !ms.HasOwner(seller) || !ms.HasOwner(arbiter) {
+			return Escrow{}, errkind.New(errkind.Forbidden, "coin is not owned by the buyer or its 2-of-3 multisig with seller and arbiter")
+		}
+		if !l.paidBy(c, buyer) {
+			return Escrow{}, errkind.New(errkind.Forbidden, "coin was not paid to the multisig by the buyer")
+		}
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Escrow{}, err
+	}
+	e := &Escrow{ID: id, Multisig: ms.UUID, Coin: c.UUID, Amount: c.Value, Buyer: buyer, Seller: seller, Arbiter: arbiter, State: EscrowFunded, Funding: c.TxHash}
+	l.escrows = append(l.escrows, e)
+	l.bus.publish(EscrowChanged{*e})
+	return *e, nil
+}
+
+/*
+	paidBy() reports whether the last Tx of c is a payment of sender, the
+	caller holds the lock
+*/
+func (l *Ledger) paidBy(c *Coin, sender uuid.UUID) bool {
+	for _, Tx := range l.allTx() {
+		if bytes.Equal(Tx.CurrHash, c.TxHash) {
+			return Tx.Sender == sender && len(Tx.Inputs) == 0
+		}
+	}
+	return false
+}
+
+/*
+	Dispute() marks the funded escrow id as disputed by its buyer or seller
+*/
+func (l *Ledger) Dispute(user uuid.UUID, id uuid.UUID) (Escrow, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, err := l.escrow(id)
+	if err != nil {
+		return Escrow{}, err
+	}
+	if user != e.Buyer && user != e.Seller {
+		return Escrow{}, errkind.New(errkind.Forbidden, "only the buyer or the seller can dispute an escrow")
+	}
+	if e.State != EscrowFunded {
+		return Escrow{}, errkind.New(errkind.Conflict, "escrow is "+string(e.State))
+	}
+	e.State = EscrowDisputed
+	l.bus.publish(EscrowChanged{*e})
+	return *e, nil
+}
+
+/*
+	settle() records the spend Tx of the escrowed coin in e
+*/
+func (l *Ledger) settle(e *Escrow, Tx *Transaction) {
+	e.State = EscrowRefunded
+	if Tx.Receiver == e.Seller {
+		e.State = EscrowReleased
+	}
+	e.Settlement = Tx.CurrHash
+	l.bus.publish(EscrowChanged{*e})
+}
+
+func (l *Ledger) escrow(id uuid.UUID) (*Escrow, error) {
+	for _, e := range l.escrows {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, ErrEscrowNotFound
+}
+
+/*
+	escrowOf() returns the open escrow holding coinID, nil if there is none
+*/
+func (l *Ledger) escrowOf(coinID uuid.UUID) *Escrow {
+	for _, e := range l.escrows {
+		if e.Coin == coinID && e.Open() {
+			return e
+		}
+	}
+	return nil
+}
+
+/*
+	Escrow() returns the escrow with provided id
+*/
+func (l *Ledger) Escrow(id uuid.UUID) (Escrow, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	e, err := l.escrow(id)
+	if err != nil {
+		return Escrow{}, err
+	}
+	return *e, nil
+}
+
+/*
+	EscrowsOf() returns every escrow user is a party of
+*/
+func (l *Ledger) EscrowsOf(user uuid.UUID) []Escrow {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	list := []Escrow{}
+	for _, e := range l.escrows {
+		if e.HasParty(user) {
+			list = append(list, *e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,107 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+func TestEscrow(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob", "claire", "dave")
+	goofy, alice, bob, claire, dave := users[0], users[1], users[2], users[3], users[4]
+
+	mint := func(owner uuid.UUID) Coin {
+		c, err := l.Mint(goofy.UUID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.Transfer(goofy.UUID, c.UUID, owner, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		c, _ = l.Coin(c.UUID)
+		return c
+	}
+
+	// alice buys from bob with claire as arbiter
+	c := mint(alice.UUID)
+	e, err := l.OpenEscrow(alice.UUID, bob.UUID, claire.UUID, c.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+	if e.State != EscrowFunded || c.Owner != e.Multisig || e.Amount != 10 || string(e.Funding) != string(c.TxHash) {
+		t.Errorf("unexpected escrow %+v of coin owned by %s", e, c.Owner)
+	}
+	if _, err := l.OpenEscrow(alice.UUID, bob.UUID, claire.UUID, c.UUID); !errors.Is(err, errkind.Conflict) {
+		t.Errorf("escrow of an escrowed coin returned %v", err)
+	}
+	if got := l.EscrowsOf(claire.UUID); len(got) != 1 || got[0].ID != e.ID {
+		t.Errorf("claire is party of %v", got)
+	}
+	if _, err := l.SpendMultisig(c.UUID, dave.UUID, nil, signSpend(t, c, dave.UUID, alice, bob)); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("spend to an outsider returned %v", err)
+	}
+
+	// once disputed, buyer and seller alone cannot settle
+	if _, err := l.Dispute(claire.UUID, e.ID); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("dispute by the arbiter returned %v", err)
+	}
+	if e, err = l.Dispute(bob.UUID, e.ID); err != nil || e.State != EscrowDisputed {
+		t.Fatalf("dispute left %s, %v", e.State, err)
+	}
+	if _, err := l.Dispute(alice.UUID, e.ID); !errors.Is(err, errkind.Conflict) {
+		t.Errorf("second dispute returned %v", err)
+	}
+	if _, err := l.SpendMultisig(c.UUID, bob.UUID, nil, signSpend(t, c, bob.UUID, alice, bob)); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("settlement without the arbiter returned %v", err)
+	}
+	Tx, err := l.SpendMultisig(c.UUID, alice.UUID, nil, signSpend(t, c, alice.UUID, alice, claire))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ = l.Escrow(e.ID); e.State != EscrowRefunded || string(e.Settlement) != string(Tx.CurrHash) {
+		t.Errorf("refund left %+v", e)
+	}
+
+	// a buyer with its own key funds the multisig before opening the escrow
+	ms, err := l.CreateMultisig(2, []uuid.UUID{bob.UUID, claire.UUID, dave.UUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = mint(ms.UUID)
+	if _, err := l.OpenEscrow(dave.UUID, bob.UUID, claire.UUID, c.UUID); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("escrow of a coin paid in by goofy returned %v", err)
+	}
+	c = mint(dave.UUID)
+	if _, err := l.Transfer(dave.UUID, c.UUID, ms.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+	if _, err := l.OpenEscrow(alice.UUID, bob.UUID, claire.UUID, c.UUID); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("escrow of another multisig returned %v", err)
+	}
+	if _, err := l.OpenEscrow(bob.UUID, dave.UUID, claire.UUID, c.UUID); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("escrow by an owner who did not pay returned %v", err)
+	}
+	e, err = l.OpenEscrow(dave.UUID, bob.UUID, claire.UUID, c.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.SpendMultisig(c.UUID, bob.UUID, nil, signSpend(t, c, bob.UUID, dave, bob)); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ = l.Escrow(e.ID); e.State != EscrowReleased {
+		t.Errorf("release left %s", e.State)
+	}
+	if _, err := l.OpenEscrow(alice.UUID, alice.UUID, claire.UUID, c.UUID); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("escrow with the buyer as seller returned %v", err)
+	}
+	if _, err := l.Escrow(uuid.Must(uuid.NewV4())); !errors.Is(err, ErrEscrowNotFound) {
+		t.Errorf("unknown escrow returned %v", err)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,131 @@
+package ledger
+
+import (
+	"encoding/hex"
+	"sort"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Provenance
+	___________________________________________________________________________
+
+	The history of a coin is every Tx it descends from, back to the mint of
+	each coin merged into it. Each hop carries the height of its block, the
+	locks and scripts it spent were checked at that height. Anyone holding
+	goofy's key and the keys of the senders checks the history without the
+	chain, see client.VerifyHistory(). The keys sent with it name the keys
+	the ledger knows, they are not proof of them
+*/
+
+/*
+	Hop is a Tx of a coin's history appended at Height
+*/
+type Hop struct {
+	Height int          `json:"height"`
+	Tx     *Transaction `json:"tx"`
+}
+
+/*
+	History leads from the mints to Coin. Keys maps every sender and
+	multisig owner to its public key in the compressed format of scripts
+*/
+type History struct {
+	Coin      Coin                   `json:"coin"`
+	Hops      []Hop                  `json:"hops"`
+	Keys      map[uuid.UUID]HexBytes `json:"keys"`
+	Multisigs []Multisig             `json:"multisigs"`
+}
+
+/*
+	History() returns the hops of coinID in chain order, from its mints to
+	the Tx which passed it to its owner
+*/
+func (l *Ledger) History(coinID uuid.UUID) (History, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	c, err := l.coin(coinID)
+	if err != nil {
+		return History{}, err
+	}
+	hops := []Hop{}
+	index := map[string]int{}
+	for height, b := range append(append([]*Block{}, l.chain...), &l.open) {
+		for _, Tx := range b.Tx {
+			index[hex.EncodeToString(Tx.CurrHash)] = len(hops)
+			hops = append(hops, Hop{height, Tx})
+		}
+	}
+
+	// walk back from the coin's last Tx to the mints
+	seen := map[int]bool{}
+	todo := [][]byte{c.TxHash}
+	for len(todo) > 0 {
+		i, ok := index[hex.EncodeToString(todo[0])]
+		todo = todo[1:]
+		if !ok || seen[i] {
+			continue
+		}
+		seen[i] = true
+		Tx := hops[i].Tx
+		for _, in := range Tx.Inputs {
+			todo = append(todo, in.PrevHash)
+		}
+		if Tx.CoinPrev != nil {
+			todo = append(todo, Tx.CoinPrev)
+		}
+	}
+	order := make([]int, 0, len(seen))
+	for i := range seen {
+		order = append(order, i)
+	}
+	sort.Ints(order)
+
+	h := History{Coin: *c, Hops: []Hop{}, Keys: map[uuid.UUID]HexBytes{}, Multisigs: []Multisig{}}
+	for _, i := range order {
+		Tx := hops[i].Tx
+		h.Hops = append(h.Hops, hops[i])
+		if _, ok := h.Keys[Tx.Sender]; ok {
+			continue
+		}
+		if ms, err := l.multisig(Tx.Sender); err == nil {
+			if !h.hasMultisig(ms.UUID) {
+				h.Multisigs = append(h.Multisigs, ms)
+				for _, owner := range ms.Owners {
+					if err := l.addKey(h.Keys, owner); err != nil {
+						return History{}, err
+					}
+				}
+			}
+			continue
+		}
+		if err := l.addKey(h.Keys, Tx.Sender); err != nil {
+			return History{}, err
+		}
+	}
+	return h, nil
+}
+
+func (h *History) hasMultisig(id uuid.UUID) bool {
+	for _, ms := range h.Multisigs {
+		if ms.UUID == id {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	addKey() adds the compressed public key of user to keys
+*/
+func (l *Ledger) addKey(keys map[uuid.UUID]HexBytes, user uuid.UUID) error {
+	pub, err := l.users.PublicKey(user)
+	if err != nil {
+		return err
+	}
+	keys[user] = crypto.CompressPublicKey(pub)
+	return nil
+}
@@ -0,0 +1,76 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/gofrs/uuid"
+)
+
+func TestHistory(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob")
+	goofy, alice, bob := users[0], users[1], users[2]
+
+	c, _ := l.Mint(goofy.UUID, 10)
+	l.Transfer(goofy.UUID, c.UUID, bob.UUID, nil, nil, nil)
+	l.SealBlock()
+	_, parts, err := l.Split(bob.UUID, c.UUID, []int{3, 7}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Transfer(bob.UUID, parts[0].UUID, alice.UUID, nil, nil, nil)
+	l.Transfer(bob.UUID, parts[1].UUID, goofy.UUID, nil, nil, nil)
+	more, _ := l.Mint(goofy.UUID, 5)
+	l.Transfer(goofy.UUID, more.UUID, alice.UUID, nil, nil, nil)
+	_, merged, err := l.Merge(alice.UUID, []uuid.UUID{parts[0].UUID, more.UUID}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := l.History(merged.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := l.Transactions()
+	// every Tx but bob's transfer of the 7 coin to goofy
+	want := append(append([]*Transaction{}, txs[:4]...), txs[5:]...)
+	if len(h.Hops) != len(want) {
+		t.Fatalf("history has %d hops, want %d", len(h.Hops), len(want))
+	}
+	for i, hop := range h.Hops {
+		if hop.Tx != want[i] {
+			t.Errorf("hop %d is %s", i, hop.Tx.Message)
+		}
+	}
+	if h.Hops[1].Height != 0 || h.Hops[2].Height != 1 || h.Coin.UUID != merged.UUID {
+		t.Errorf("history has heights %d and %d for %s", h.Hops[1].Height, h.Hops[2].Height, h.Coin.UUID)
+	}
+	if len(h.Keys) != 3 || len(h.Multisigs) != 0 {
+		t.Errorf("history has %d keys and %d multisigs", len(h.Keys), len(h.Multisigs))
+	}
+	if pub, _ := l.users.PublicKey(bob.UUID); !bytes.Equal(h.Keys[bob.UUID], crypto.CompressPublicKey(pub)) {
+		t.Errorf("history has key %x for bob", h.Keys[bob.UUID])
+	}
+
+	// a multisig sender brings its owners' keys
+	ms, err := l.CreateMultisig(1, []uuid.UUID{alice.UUID, bob.UUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := l.Mint(goofy.UUID, 2)
+	l.Transfer(goofy.UUID, fresh.UUID, ms.UUID, nil, nil, nil)
+	fresh, _ = l.Coin(fresh.UUID)
+	u, _ := l.users.User(alice.UUID)
+	r, s, _ := crypto.Sign(u.PrivateKey, crypto.SpendDigest(fresh.UUID, goofy.UUID, fresh.TxHash))
+	if _, err := l.SpendMultisig(fresh.UUID, goofy.UUID, nil, []Signature{{alice.UUID, r, s}}); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := l.History(fresh.UUID); err != nil || len(h.Hops) != 3 || len(h.Multisigs) != 1 || len(h.Keys) != 3 {
+		t.Errorf("history of the multisig coin is %+v, %v", h, err)
+	}
+
+	if _, err := l.History(c.UUID); err != ErrCoinNotFound {
+		t.Errorf("history of the split coin returned %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package ledger
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Hash Locks
+	___________________________________________________________________________
+
+	A transfer may hash lock the coin: the receiver spends it only by
+	revealing the preimage of Hash, which the spending Tx records, and the
+	sender, Refund, takes it back once the chain reached Timeout. Two users
+	on different nodes swap coins atomically by locking them to the same
+	hash, the one who picked the preimage claims first and so reveals it to
+	the other
+*/
+
+/*
+	HashLock makes a coin claimable with the SHA-256 preimage of Hash and
+	refundable to Refund after Timeout
+*/
+type HashLock struct {
+	Hash    HexBytes  `json:"hash"`
+	Timeout Lock      `json:"timeout"`
+	Refund  uuid.UUID `json:"refund"`
+}
+
+/*
+	validate() checks the hash and timeout of hl and returns a copy refunding
+	to sender
+*/
+func (hl *HashLock) validate(sender uuid.UUID) (*HashLock, error) {
+	if hl == nil {
+		return nil, nil
+	}
+	if len(hl.Hash) != sha256.Size {
+		return nil, errkind.Failed("hash lock must be a SHA-256 hash")
+	}
+	timeout, err := hl.Timeout.validate()
+	if err != nil {
+		return nil, err
+	}
+	if timeout == nil {
+		return nil, errkind.Failed("hash lock needs a timeout")
+	}
+	return &HashLock{Hash: append(HexBytes{}, hl.Hash...), Timeout: *timeout, Refund: sender}, nil
+}
+
+/*
+	unlocks() reports whether preimage is the preimage of hl's hash
+*/
+func (hl *HashLock) unlocks(preimage []byte) bool {
+	hash := sha256.Sum256(preimage)
+	return len(preimage) > 0 && bytes.Equal(hash[:], hl.Hash)
+}
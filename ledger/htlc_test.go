@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/errkind"
+)
+
+func TestHashLock(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob", "claire")
+	goofy, alice, bob, claire := users[0], users[1], users[2], users[3]
+	preimage := []byte("open sesame")
+	hash := sha256.Sum256(preimage)
+	hl := &HashLock{Hash: hash[:], Timeout: Lock{Height: 2}}
+
+	var coins []Coin
+	for i := 0; i < 2; i++ {
+		c, _ := l.Mint(goofy.UUID, 10)
+		if _, err := l.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		Tx, err := l.Pay(Payment{Spender: alice.UUID, Coin: c.UUID, Receiver: bob.UUID, HashLock: hl})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Tx.HashLock.Refund != alice.UUID {
+			t.Errorf("hash lock refunds %s", Tx.HashLock.Refund)
+		}
+		coins = append(coins, c)
+	}
+	if b, _ := l.Balance(bob.UUID); b.Locked != 20 || b.Spendable != 0 {
+		t.Errorf("bob has %+v", b)
+	}
+
+	tests := []struct {
+		name string
+		p    Payment
+		kind error
+	}{
+		{"no preimage", Payment{Spender: bob.UUID, Coin: coins[0].UUID, Receiver: claire.UUID}, errkind.Invalid},
+		{"wrong preimage", Payment{Spender: bob.UUID, Coin: coins[0].UUID, Receiver: claire.UUID, Preimage: []byte("guess")}, errkind.Invalid},
+		{"early refund", Payment{Spender: alice.UUID, Coin: coins[0].UUID, Receiver: alice.UUID}, errkind.Invalid},
+		{"stranger", Payment{Spender: claire.UUID, Coin: coins[0].UUID, Receiver: claire.UUID, Preimage: preimage}, errkind.Forbidden},
+		{"no timeout", Payment{Spender: goofy.UUID, Coin: coins[0].UUID, Receiver: bob.UUID, HashLock: &HashLock{Hash: hash[:]}}, errkind.Invalid},
+		{"short hash", Payment{Spender: goofy.UUID, Coin: coins[0].UUID, Receiver: bob.UUID, HashLock: &HashLock{Hash: hash[:4], Timeout: Lock{Height: 1}}}, errkind.Invalid},
+	}
+	for _, test := range tests {
+		if _, err := l.Pay(test.p); !errors.Is(err, test.kind) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.kind)
+		}
+	}
+
+	// bob claims the first coin and reveals the preimage
+	Tx, err := l.Pay(Payment{Spender: bob.UUID, Coin: coins[0].UUID, Receiver: claire.UUID, Preimage: preimage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(Tx.Preimage) != string(preimage) {
+		t.Error("claim does not reveal the preimage")
+	}
+	if c, _ := l.Coin(coins[0].UUID); c.Owner != claire.UUID || c.HashLock != nil {
+		t.Errorf("claimed coin is %+v", c)
+	}
+
+	// alice takes the second coin back after the timeout
+	for i := 0; i < 2; i++ {
+		l.SealBlock()
+		l.Mint(goofy.UUID, 1)
+	}
+	if _, err := l.Pay(Payment{Spender: alice.UUID, Coin: coins[1].UUID, Receiver: alice.UUID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+	Tx.Preimage = []byte("guess")
+	if err := l.VerifyChain(); err == nil {
+		t.Error("claim with a tampered preimage verified")
+	}
+}
@@ -0,0 +1,277 @@
+package ledger
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Invoices
+	___________________________________________________________________________
+
+	A user requests a payment with an invoice signed by its key: an amount,
+	a memo and an expiry under a unique ID. The invoice travels as a
+	goofy:invoice URI, short enough for a QR code, which carries the
+	compressed key so anyone can check the signature offline. The payer
+	passes coins worth exactly the amount to the recipient, the invoice
+	records their transactions and is paid
+*/
+
+const (
+	MaxInvoiceMemo = 140
+	InvoiceTTL     = 24 * time.Hour
+)
+
+/*
+	InvoiceState is open until the invoice is paid, an open invoice past
+	its expiry is reported as expired
+*/
+type InvoiceState string
+
+const (
+	InvoiceOpen    InvoiceState = "open"
+	InvoicePaid    InvoiceState = "paid"
+	InvoiceExpired InvoiceState = "expired"
+)
+
+var ErrInvoiceNotFound = &errkind.Error{Kind: errkind.NotFound, Message: "invoice not found"}
+
+/*
+	Invoice asks for Amount to Recipient until the unix time Expires.
+	Signature signs crypto.InvoiceDigest() with the key PublicKey, in the
+	compressed format of scripts. Payments are the hashes of the
+	transactions of Payer which paid it
+*/
+type Invoice struct {
+	ID        uuid.UUID    `json:"id"`
+	Recipient uuid.UUID    `json:"recipient"`
+	PublicKey HexBytes     `json:"publicKey"`
+	Amount    int          `json:"amount"`
+	Memo      string       `json:"memo,omitempty"`
+	Expires   int64        `json:"expires"`
+	Signature Signature    `json:"signature"`
+	State     InvoiceState `json:"state"`
+	Payer     *uuid.UUID   `json:"payer,omitempty"`
+	Payments  []HexBytes   `json:"payments,omitempty"`
+}
+
+/*
+	digest() returns the digest the recipient signs
+*/
+func (inv *Invoice) digest() []byte {
+	return crypto.InvoiceDigest(inv.ID, inv.PublicKey, inv.Amount, inv.Memo, inv.Expires)
+}
+
+/*
+	URI() encodes the signed terms of inv as a goofy:invoice URI
+*/
+func (inv Invoice) URI() string {
+	q := url.Values{}
+	q.Set("id", inv.ID.String())
+	q.Set("to", inv.Recipient.String())
+	q.Set("key", hex.EncodeToString(inv.PublicKey))
+	q.Set("amount", strconv.Itoa(inv.Amount))
+	q.Set("expires", strconv.FormatInt(inv.Expires, 10))
+	if inv.Memo != "" {
+		q.Set("memo", inv.Memo)
+	}
+	if inv.Signature.R != nil && inv.Signature.S != nil {
+		q.Set("sig", hex.EncodeToString(crypto.SignatureBytes(inv.Signature.R, inv.Signature.S)))
+	}
+	return "goofy:invoice?" + q.Encode()
+}
+
+func (inv Invoice) MarshalJSON() ([]byte, error) {
+	type invoice Invoice
+	return json.Marshal(struct {
+		invoice
+		URI string `json:"uri"`
+	}{invoice(inv), inv.URI()})
+}
+
+/*
+	at() returns inv as it stands at unix time now
+*/
+func (inv Invoice) at(now int64) Invoice {
+	if inv.State == InvoiceOpen && now >= inv.Expires {
+		inv.State = InvoiceExpired
+	}
+	return inv
+}
+
+/*
+	CreateInvoice() issues an invoice for the terms ID, Recipient, Amount,
+	Memo and Expires. A nil ID is generated and a zero Expires is InvoiceTTL
+	from now. The ledger signs with the recipient's key when it holds it
+	and terms carry no signature
+*/
+func (l *Ledger) CreateInvoice(terms Invoice) (Invoice, error) {
+	if err := ValidateAmount(int64(terms.Amount)); err != nil {
+		return Invoice{}, err
+	}
+	if len(terms.Memo) > MaxInvoiceMemo {
+		return Invoice{}, errkind.Failed("memo is longer than " + strconv.Itoa(MaxInvoiceMemo) + " bytes")
+	}
+	now := time.Now()
+	if terms.Expires == 0 {
+		terms.Expires = now.Add(InvoiceTTL).Unix()
+	}
+	if terms.Expires <= now.Unix() {
+		return Invoice{}, errkind.Failed("invoice expires in the past")
+	}
+	if terms.ID == uuid.Nil {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return Invoice{}, err
+		}
+		terms.ID = id
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.invoice(terms.ID); err == nil {
+		return Invoice{}, errkind.New(errkind.Conflict, "invoice "+terms.ID.String()+" exists")
+	}
+	u, err := l.users.User(terms.Recipient)
+	if err != nil {
+		return Invoice{}, err
+	}
+	inv := &Invoice{ID: terms.ID, Recipient: u.UUID, PublicKey: crypto.CompressPublicKey(u.PublicKey), Amount: terms.Amount, Memo: terms.Memo, Expires: terms.Expires, State: InvoiceOpen}
+	r, s := terms.Signature.R, terms.Signature.S
+	if r == nil || s == nil {
+		if u.PrivateKey == nil {
+			return Invoice{}, ErrSignatureRequired
+		}
+		r, s, err = crypto.Sign(u.PrivateKey, inv.digest())
+		if err != nil {
+			return Invoice{}, err
+		}
+	}
+	if !l.verify(u.PublicKey, inv.digest(), r, s) {
+		return Invoice{}, ErrInvalidSignature
+	}
+	inv.Signature = Signature{Signer: u.UUID, R: r, S: s}
+	l.invoices = append(l.invoices, inv)
+	l.bus.publish(InvoiceChanged{*inv})
+	return *inv, nil
+}
+
+/*
+	PayInvoice() passes the coins of payments from payer to the recipient of
+	invoice id, R and S of each payment sign crypto.SpendDigest() unless
+	the ledger holds the payer's key. The coins must be worth exactly the
+	amount and either all of them move or none
+*/
+func (l *Ledger) PayInvoice(payer uuid.UUID, id uuid.UUID, payments []Payment) (Invoice, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inv, err := l.invoice(id)
+	if err != nil {
+		return Invoice{}, err
+	}
+	now := time.Now().Unix()
+	switch inv.at(now).State {
+	case InvoicePaid:
+		return Invoice{}, errkind.New(errkind.Conflict, "invoice is paid")
+	case InvoiceExpired:
+		return Invoice{}, errkind.Failed("invoice expired")
+	}
+	if payer == inv.Recipient {
+		return Invoice{}, errkind.Failed("the recipient cannot pay its own invoice")
+	}
+	sender, err := l.users.User(payer)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	// check every coin before any moves
+	total := 0
+	seen := map[uuid.UUID]bool{}
+	for _, p := range payments {
+		c, err := l.coin(p.Coin)
+		if err != nil {
+			return Invoice{}, err
+		}
+		if seen[c.UUID] {
+			return Invoice{}, errkind.Failed("coin " + c.UUID.String() + " is listed twice")
+		}
+		seen[c.UUID] = true
+		if c.HashLock != nil || c.Script != nil {
+			return Invoice{}, errkind.Failed("an invoice is paid with plain coins")
+		}
+		Tx := &Transaction{TimeStamp: now, CoinID: c.UUID, Sender: payer, Receiver: inv.Recipient, CoinPrev: c.TxHash}
+		if err := c.spendableBy(Tx, len(l.chain)); err != nil {
+			return Invoice{}, err
+		}
+		if p.R == nil || p.S == nil {
+			if sender.PrivateKey == nil {
+				return Invoice{}, ErrSignatureRequired
+			}
+		} else if !l.verify(sender.PublicKey, crypto.SpendDigest(c.UUID, inv.Recipient, c.TxHash), p.R, p.S) {
+			return Invoice{}, ErrInvalidSignature
+		}
+		total += c.Value
+	}
+	if total != inv.Amount {
+		return Invoice{}, errkind.Failed("coins are worth " + strconv.Itoa(total) + ", the invoice asks for " + strconv.Itoa(inv.Amount))
+	}
+
+	for _, p := range payments {
+		Tx, err := l.pay(Payment{Spender: payer, Coin: p.Coin, Receiver: inv.Recipient, R: p.R, S: p.S})
+		if err != nil {
+			return Invoice{}, err
+		}
+		inv.Payments = append(inv.Payments, Tx.CurrHash)
+	}
+	inv.State = InvoicePaid
+	inv.Payer = &payer
+	l.bus.publish(InvoiceChanged{*inv})
+	return *inv, nil
+}
+
+func (l *Ledger) invoice(id uuid.UUID) (*Invoice, error) {
+	for _, inv := range l.invoices {
+		if inv.ID == id {
+			return inv, nil
+		}
+	}
+	return nil, ErrInvoiceNotFound
+}
+
+/*
+	Invoice() returns the invoice with provided id
+*/
+func (l *Ledger) Invoice(id uuid.UUID) (Invoice, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	inv, err := l.invoice(id)
+	if err != nil {
+		return Invoice{}, err
+	}
+	return inv.at(time.Now().Unix()), nil
+}
+
+/*
+	InvoicesOf() returns every invoice user issued or paid
+*/
+func (l *Ledger) InvoicesOf(user uuid.UUID) []Invoice {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	now := time.Now().Unix()
+	list := []Invoice{}
+	for _, inv := range l.invoices {
+		if inv.Recipient == user || (inv.Payer != nil && *inv.Payer == user) {
+			list = append(list, inv.at(now))
+		}
+	}
+	return list
+}
@@ -0,0 +1,112 @@
+package ledger
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+func TestInvoice(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob")
+	goofy, alice, bob := users[0], users[1], users[2]
+
+	var coins []Coin
+	for _, value := range []int{3, 4, 5} {
+		c, err := l.Mint(goofy.UUID, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.Transfer(goofy.UUID, c.UUID, bob.UUID, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		c, _ = l.Coin(c.UUID)
+		coins = append(coins, c)
+	}
+
+	inv, err := l.CreateInvoice(Invoice{Recipient: alice.UUID, Amount: 7, Memo: "tea & cake"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.State != InvoiceOpen || inv.Expires <= time.Now().Unix() {
+		t.Errorf("unexpected invoice %+v", inv)
+	}
+	// the URI carries everything needed to check the signature
+	uri, err := url.Parse(inv.URI())
+	if err != nil || uri.Scheme != "goofy" || !strings.HasPrefix(uri.Opaque, "invoice") || uri.Query().Get("memo") != "tea & cake" {
+		t.Fatalf("malformed URI %s: %v", inv.URI(), err)
+	}
+	if !crypto.Verify(alice.PublicKey, inv.digest(), inv.Signature.R, inv.Signature.S) {
+		t.Error("invoice not signed by alice")
+	}
+	if _, err := l.CreateInvoice(Invoice{ID: inv.ID, Recipient: alice.UUID, Amount: 1}); !errors.Is(err, errkind.Conflict) {
+		t.Errorf("reused id returned %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		payer    uuid.UUID
+		payments []Payment
+		kind     error
+	}{
+		{"too little", bob.UUID, []Payment{{Coin: coins[0].UUID}}, errkind.Invalid},
+		{"too much", bob.UUID, []Payment{{Coin: coins[1].UUID}, {Coin: coins[2].UUID}}, errkind.Invalid},
+		{"coin twice", bob.UUID, []Payment{{Coin: coins[0].UUID}, {Coin: coins[0].UUID}}, errkind.Invalid},
+		{"coins of another", goofy.UUID, []Payment{{Coin: coins[0].UUID}, {Coin: coins[1].UUID}}, errkind.Forbidden},
+		{"by the recipient", alice.UUID, nil, errkind.Invalid},
+	}
+	for _, test := range tests {
+		if _, err := l.PayInvoice(test.payer, inv.ID, test.payments); !errors.Is(err, test.kind) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.kind)
+		}
+	}
+	if c, _ := l.Coin(coins[0].UUID); c.Owner != bob.UUID {
+		t.Fatal("a refused payment moved a coin")
+	}
+
+	inv, err = l.PayInvoice(bob.UUID, inv.ID, []Payment{{Coin: coins[0].UUID}, {Coin: coins[1].UUID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.State != InvoicePaid || len(inv.Payments) != 2 || *inv.Payer != bob.UUID {
+		t.Errorf("unexpected paid invoice %+v", inv)
+	}
+	if b, _ := l.Balance(alice.UUID); b.Balance != 7 {
+		t.Errorf("alice has %d", b.Balance)
+	}
+	if _, err := l.PayInvoice(bob.UUID, inv.ID, []Payment{{Coin: coins[2].UUID}}); !errors.Is(err, errkind.Conflict) {
+		t.Errorf("second payment returned %v", err)
+	}
+	if got := l.InvoicesOf(bob.UUID); len(got) != 1 || got[0].ID != inv.ID {
+		t.Errorf("bob paid %v", got)
+	}
+
+	if _, err := l.CreateInvoice(Invoice{Recipient: alice.UUID, Amount: 1, Expires: time.Now().Unix() - 1}); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("expired invoice returned %v", err)
+	}
+	if _, err := l.CreateInvoice(Invoice{Recipient: alice.UUID, Amount: 1, Memo: strings.Repeat("x", MaxInvoiceMemo+1)}); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("long memo returned %v", err)
+	}
+	if _, err := l.CreateInvoice(Invoice{Recipient: alice.UUID, Amount: MaxAmount + 1}); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("invoice over the maximum amount returned %v", err)
+	}
+	expiring, err := l.CreateInvoice(Invoice{Recipient: alice.UUID, Amount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.invoices[len(l.invoices)-1].Expires = time.Now().Unix()
+	if _, err := l.PayInvoice(bob.UUID, expiring.ID, []Payment{{Coin: coins[2].UUID}}); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("payment of an expired invoice returned %v", err)
+	}
+	if inv, _ := l.Invoice(expiring.ID); inv.State != InvoiceExpired {
+		t.Errorf("invoice is %s", inv.State)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,971 @@
+/*
+	Package ledger keeps the coins of goofy coin and the chain of signed
+	transactions which moved them. Goofy, the first user of the wallet,
+	mints coins and every owner passes a coin on by signing
+	crypto.SpendDigest() for the receiver
+*/
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/wallet"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrCoinNotFound      = &errkind.Error{Kind: errkind.NotFound, Message: "coin not found"}
+	ErrTxNotFound        = &errkind.Error{Kind: errkind.NotFound, Message: "transaction not found"}
+	ErrSignatureRequired = &errkind.Error{Kind: errkind.Invalid, Message: "signature required"}
+	ErrInvalidSignature  = &errkind.Error{Kind: errkind.Invalid, Message: "invalid signature"}
+)
+
+/*
+	HexBytes is a byte slice which is represented as a hex string in JSON
+*/
+type HexBytes []byte
+
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+func (h *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+/*
+	Coin is a unit of value minted by goofy, TxHash points to the transaction
+	which last passed the coin on, H() in the README diagrams. Lock,
+	HashLock and Script are the locks of that transaction, if any
+*/
+type Coin struct {
+	UUID     uuid.UUID `json:"uuid"`
+	Value    int       `json:"value"`
+	Owner    uuid.UUID `json:"owner"`
+	TxHash   HexBytes  `json:"txHash"`
+	Lock     *Lock     `json:"lock,omitempty"`
+	HashLock *HashLock `json:"hashLock,omitempty"`
+	Script   HexBytes  `json:"script,omitempty"`
+}
+
+/*
+	Transaction passes CoinID from Sender to Receiver, R and S sign
+	crypto.SpendDigest() with the sender's key, or Sigs with the keys of the
+	owners when Sender is a multisig. A Lock keeps the receiver from
+	spending the coin early, a HashLock until it reveals the Preimage. A
+	Script locks it instead, the next Tx unlocks it with Unlock. A mint is
+	sent by goofy to goofy without CoinPrev. Memo is signed and hashed with
+	the rest. A split or merge has no CoinID, it spends the coins of Inputs
+	for those of Outputs and its Sender is its Receiver. Transactions are
+	not changed once appended
+*/
+type Transaction struct {
+	TimeStamp int64
+	Message   []byte
+	PrevHash  []byte
+	CurrHash  []byte
+	CoinID    uuid.UUID
+	Sender    uuid.UUID
+	Receiver  uuid.UUID
+	Amount    int
+	CoinPrev  []byte
+	R         *big.Int
+	S         *big.Int
+	Sigs      []Signature
+	Lock      *Lock
+	HashLock  *HashLock
+	Preimage  []byte
+	Script    []byte
+	Unlock    []byte
+	Memo      Memo
+	Inputs    []Input
+	Outputs   []Output
+}
+
+/*
+	Block groups transactions, each linked to its predecessor by PrevHash
+*/
+type Block struct {
+	Tx        []*Transaction
+	Height    int
+	TimeStamp int64
+	PrevHash  []byte
+	Hash      []byte
+}
+
+/*
+	Ledger holds the wallet, the multisig accounts, the escrows, the
+	invoices, the coins, the open block receiving new transactions and the
+	chain of sealed blocks. It is safe for concurrent use
+*/
+type Ledger struct {
+	mu        sync.RWMutex
+	users     *wallet.Wallet
+	multisigs []Multisig
+	escrows   []*Escrow
+	invoices  []*Invoice
+	coins     []*Coin
+	open      Block
+	chain     []*Block
+	bus       bus
+
+	// OnVerify, if set before the ledger is used, is called with the
+	// duration of every signature verification
+	OnVerify func(time.Duration)
+}
+
+/*
+	New() returns an empty ledger, its first user becomes goofy
+*/
+func New() *Ledger {
+	return &Ledger{users: wallet.New()}
+}
+
+/*
+	User Utilities
+	___________________________________________________________________________
+*/
+
+/*
+	RegisterUser() adds a user, if pubKey is nil a key pair is generated and
+	held by the ledger, otherwise the user keeps the private key. Without a
+	password the user cannot log in with one
+*/
+func (l *Ledger) RegisterUser(name string, pubKey *ecdsa.PublicKey, password string) (wallet.User, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u, err := l.users.Register(name, pubKey, password)
+	if err != nil {
+		return wallet.User{}, err
+	}
+	l.bus.publish(UserCreated{u})
+	return u, nil
+}
+
+/*
+	AttachGoofy() creates goofy with key in an empty ledger, otherwise it
+	gives the replayed goofy its private key and password
+*/
+func (l *Ledger) AttachGoofy(key *ecdsa.PrivateKey, password string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	goofy, err := l.users.Goofy()
+	if err != nil {
+		u, err := l.users.Add("goofy", key, &key.PublicKey, password)
+		if err != nil {
+			return err
+		}
+		l.bus.publish(UserCreated{u})
+		return nil
+	}
+	return l.users.Attach(goofy.UUID, key, password)
+}
+
+/*
+	Goofy() returns the user who mints coins
+*/
+func (l *Ledger) Goofy() (wallet.User, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.users.Goofy()
+}
+
+/*
+	User() returns the user with provided uuid
+*/
+func (l *Ledger) User(id uuid.UUID) (wallet.User, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.users.User(id)
+}
+
+/*
+	UserByName() returns the user with provided name
+*/
+func (l *Ledger) UserByName(name string) (wallet.User, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.users.UserByName(name)
+}
+
+/*
+	Users() returns every user in order of registration
+*/
+func (l *Ledger) Users() []wallet.User {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.users.Users()
+}
+
+/*
+	accountName() returns the name of the user or multisig with provided
+	uuid, which can both own coins
+*/
+func (l *Ledger) accountName(id uuid.UUID) (string, error) {
+	if u, err := l.users.User(id); err == nil {
+		return u.Name, nil
+	}
+	if ms, err := l.multisig(id); err == nil {
+		return ms.String(), nil
+	}
+	return "", wallet.ErrUserNotFound
+}
+
+/*
+	AccountName() returns the name of the user or multisig with provided uuid
+*/
+func (l *Ledger) AccountName(id uuid.UUID) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.accountName(id)
+}
+
+/*
+	Transaction Utilities
+	___________________________________________________________________________
+*/
+
+/*
+	mintMessage() returns the Tx message for goofy creating a coin
+*/
+func mintMessage(coinID uuid.UUID, amount int) []byte {
+	message := [][]byte{[]byte("Goofy created"), []byte(strconv.Itoa(amount)), []byte("goofy coins with uuid"), []byte(coinID.String())}
+	return bytes.Join(message, []byte(" "))
+}
+
+/*
+	transferMessage() returns the Tx message for sender paying receiver
+*/
+func transferMessage(sender, receiver string, amount int) []byte {
+	message := [][]byte{[]byte(sender), []byte("paid"), []byte(receiver), []byte(strconv.Itoa(amount)), []byte("goofy coins")}
+	return bytes.Join(message, []byte(" "))
+}
+
+/*
+	appendTx() stamps, hashes and appends Tx to the open block
+*/
+func (l *Ledger) appendTx(Tx *Transaction) {
+	Tx.TimeStamp = time.Now().Unix()
+	Tx.PrevHash = l.tip()
+	Tx.CurrHash = Tx.hash()
+	l.open.Tx = append(l.open.Tx, Tx)
+}
+
+/*
+	hash() returns the SHA-256 hash over every field of Tx except CurrHash
+*/
+func (Tx *Transaction) hash() []byte {
+	timestamp := []byte(strconv.FormatInt(Tx.TimeStamp, 10))
+	data := [][]byte{timestamp, Tx.Message, Tx.PrevHash, Tx.CoinID.Bytes(), Tx.Sender.Bytes(), Tx.Receiver.Bytes(), []byte(strconv.Itoa(Tx.Amount)), Tx.CoinPrev, bigBytes(Tx.R), bigBytes(Tx.S)}
+	for _, sig := range Tx.Sigs {
+		data = append(data, sig.Signer.Bytes(), bigBytes(sig.R), bigBytes(sig.S))
+	}
+	if Tx.Lock != nil {
+		data = append(data, []byte(strconv.Itoa(Tx.Lock.Height)), []byte(strconv.FormatInt(Tx.Lock.Time, 10)))
+	}
+	if Tx.HashLock != nil {
+		hl := Tx.HashLock
+		data = append(data, hl.Hash, []byte(strconv.Itoa(hl.Timeout.Height)), []byte(strconv.FormatInt(hl.Timeout.Time, 10)), hl.Refund.Bytes())
+	}
+	if Tx.Preimage != nil {
+		data = append(data, Tx.Preimage)
+	}
+	if Tx.Script != nil || Tx.Unlock != nil {
+		data = append(data, []byte("script:"), Tx.Script, []byte("unlock:"), Tx.Unlock)
+	}
+	if len(Tx.Memo) > 0 {
+		data = append(data, []byte("memo:"), Tx.Memo.bytes())
+	}
+	for _, in := range Tx.Inputs {
+		data = append(data, []byte("in:"), in.Coin.Bytes(), in.PrevHash)
+	}
+	for _, out := range Tx.Outputs {
+		data = append(data, []byte("out:"), out.Coin.Bytes(), []byte(strconv.Itoa(out.Value)))
+	}
+	hash := sha256.Sum256(bytes.Join(data, []byte{}))
+	return hash[:]
+}
+
+func bigBytes(n *big.Int) []byte {
+	if n == nil {
+		return nil
+	}
+	return n.Bytes()
+}
+
+/*
+	MarshalJSON() exposes Tx to the API
+*/
+func (Tx *Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TimeStamp int64       `json:"timeStamp"`
+		Message   string      `json:"message"`
+		Coin      uuid.UUID   `json:"coin"`
+		Sender    uuid.UUID   `json:"sender"`
+		Receiver  uuid.UUID   `json:"receiver"`
+		Amount    int         `json:"amount"`
+		CoinPrev  HexBytes    `json:"coinPrevHash"`
+		PrevHash  HexBytes    `json:"prevHash"`
+		CurrHash  HexBytes    `json:"currHash"`
+		R         string      `json:"r"`
+		S         string      `json:"s"`
+		Sigs      []Signature `json:"signatures,omitempty"`
+		Lock      *Lock       `json:"lock,omitempty"`
+		HashLock  *HashLock   `json:"hashLock,omitempty"`
+		Preimage  HexBytes    `json:"preimage,omitempty"`
+		Script    HexBytes    `json:"script,omitempty"`
+		Unlock    HexBytes    `json:"unlock,omitempty"`
+		Memo      Memo        `json:"memo,omitempty"`
+		Inputs    []Input     `json:"inputs,omitempty"`
+		Outputs   []Output    `json:"outputs,omitempty"`
+	}{Tx.TimeStamp, string(Tx.Message), Tx.CoinID, Tx.Sender, Tx.Receiver, Tx.Amount, Tx.CoinPrev, Tx.PrevHash, Tx.CurrHash, bigHex(Tx.R), bigHex(Tx.S), Tx.Sigs, Tx.Lock, Tx.HashLock, Tx.Preimage, Tx.Script, Tx.Unlock, Tx.Memo, Tx.Inputs, Tx.Outputs})
+}
+
+func bigHex(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.Text(16)
+}
+
+/*
+	allTx() returns every Tx of the sealed blocks followed by the open block
+*/
+func (l *Ledger) allTx() []*Transaction {
+	txs := []*Transaction{}
+	for _, b := range l.chain {
+		txs = append(txs, b.Tx...)
+	}
+	return append(txs, l.open.Tx...)
+}
+
+/*
+	tip() returns the hash of the last Tx, nil for an empty chain
+*/
+func (l *Ledger) tip() []byte {
+	if len(l.open.Tx) > 0 {
+		return l.open.Tx[len(l.open.Tx)-1].CurrHash
+	}
+	if len(l.chain) > 0 {
+		sealed := l.chain[len(l.chain)-1].Tx
+		return sealed[len(sealed)-1].CurrHash
+	}
+	return nil
+}
+
+/*
+	Transactions() returns every Tx of the sealed blocks followed by the
+	open block
+*/
+func (l *Ledger) Transactions() []*Transaction {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.allTx()
+}
+
+/*
+	TransactionsOf() returns every Tx user may see, in chain order, see
+	VisibleTo()
+*/
+func (l *Ledger) TransactionsOf(user uuid.UUID) []*Transaction {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	txs := []*Transaction{}
+	for _, Tx := range l.allTx() {
+		if l.visibleTo(user, Tx) {
+			txs = append(txs, Tx)
+		}
+	}
+	return txs
+}
+
+/*
+	VisibleTo() reports whether user may see Tx: goofy sees every Tx, other
+	users those sent or received by them or by a multisig they own
+*/
+func (l *Ledger) VisibleTo(user uuid.UUID, Tx *Transaction) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.visibleTo(user, Tx)
+}
+
+func (l *Ledger) visibleTo(user uuid.UUID, Tx *Transaction) bool {
+	if Tx.Sender == user || Tx.Receiver == user {
+		return true
+	}
+	if goofy, err := l.users.Goofy(); err == nil && goofy.UUID == user {
+		return true
+	}
+	for _, party := range []uuid.UUID{Tx.Sender, Tx.Receiver} {
+		if ms, err := l.multisig(party); err == nil && ms.HasOwner(user) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Transaction() returns the Tx with provided hash
+*/
+func (l *Ledger) Transaction(hash []byte) (*Transaction, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, Tx := range l.allTx() {
+		if bytes.Equal(Tx.CurrHash, hash) {
+			return Tx, nil
+		}
+	}
+	return nil, ErrTxNotFound
+}
+
+/*
+	Ledger Utilities
+	___________________________________________________________________________
+*/
+
+/*
+	coin() returns the coin with provided uuid
+*/
+func (l *Ledger) coin(id uuid.UUID) (*Coin, error) {
+	for _, c := range l.coins {
+		if c.UUID == id {
+			return c, nil
+		}
+	}
+	return nil, ErrCoinNotFound
+}
+
+/*
+	Coin() returns a copy of the coin with provided uuid
+*/
+func (l *Ledger) Coin(id uuid.UUID) (Coin, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c, err := l.coin(id)
+	if err != nil {
+		return Coin{}, err
+	}
+	return *c, nil
+}
+
+/*
+	Coins() returns every coin in order of minting
+*/
+func (l *Ledger) Coins() []Coin {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	coins := make([]Coin, 0, len(l.coins))
+	for _, c := range l.coins {
+		coins = append(coins, *c)
+	}
+	return coins
+}
+
+func (l *Ledger) coinsOf(owner uuid.UUID) []Coin {
+	coins := []Coin{}
+	for _, c := range l.coins {
+		if c.Owner == owner {
+			coins = append(coins, *c)
+		}
+	}
+	return coins
+}
+
+/*
+	CoinsOf() returns every coin owned by provided uuid
+*/
+func (l *Ledger) CoinsOf(owner uuid.UUID) []Coin {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.coinsOf(owner)
+}
+
+/*
+	Balance is the value of the coins of a user, Locked of them cannot be
+	spent yet
+*/
+type Balance struct {
+	User      uuid.UUID `json:"user"`
+	Balance   int       `json:"balance"`
+	Spendable int       `json:"spendable"`
+	Locked    int       `json:"locked"`
+	Coins     []Coin    `json:"coins"`
+}
+
+/*
+	Balance() returns the value and coins owned by a user or multisig
+*/
+func (l *Ledger) Balance(user uuid.UUID) (Balance, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if _, err := l.accountName(user); err != nil {
+		return Balance{}, err
+	}
+	b := Balance{User: user, Coins: l.coinsOf(user)}
+	now := time.Now().Unix()
+	for _, c := range b.Coins {
+		b.Balance += c.Value
+		if c.HashLock == nil && c.Script == nil && c.Lock.opened(len(l.chain), now) {
+			b.Spendable += c.Value
+		} else {
+			b.Locked += c.Value
+		}
+	}
+	return b, nil
+}
+
+func (l *Ledger) totalSupply() int {
+	supply := 0
+	for _, c := range l.coins {
+		supply += c.Value
+	}
+	return supply
+}
+
+/*
+	TotalSupply() returns the value of every coin minted
+*/
+func (l *Ledger) TotalSupply() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.totalSupply()
+}
+
+/*
+	Len() returns the number of transactions in sealed blocks and the open
+	block
+*/
+func (l *Ledger) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	n := len(l.open.Tx)
+	for _, b := range l.chain {
+		n += len(b.Tx)
+	}
+	return n
+}
+
+/*
+	verify() checks a signature, timing it for OnVerify
+*/
+func (l *Ledger) verify(pub *ecdsa.PublicKey, digest []byte, r, s *big.Int) bool {
+	start := time.Now()
+	ok := crypto.Verify(pub, digest, r, s)
+	if l.OnVerify != nil {
+		l.OnVerify(time.Since(start))
+	}
+	return ok
+}
+
+/*
+	Mint() creates a coin of amount owned by goofy, signed by goofy's key,
+	minter must be goofy
+*/
+func (l *Ledger) Mint(minter uuid.UUID, amount int) (Coin, error) {
+	if err := ValidateAmount(int64(amount)); err != nil {
+		return Coin{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.totalSupply() > MaxSupply-amount {
+		return Coin{}, errkind.Failed("total supply would exceed " + strconv.Itoa(MaxSupply))
+	}
+	goofy, err := l.users.Goofy()
+	if err != nil {
+		return Coin{}, err
+	}
+	if minter != goofy.UUID {
+		return Coin{}, errkind.New(errkind.Forbidden, "only goofy can create coins")
+	}
+	if goofy.PrivateKey == nil {
+		return Coin{}, errors.New("goofy key not held by server")
+	}
+	coinID, err := uuid.NewV4()
+	if err != nil {
+		return Coin{}, err
+	}
+	r, s, err := crypto.Sign(goofy.PrivateKey, crypto.SpendDigest(coinID, goofy.UUID, nil))
+	if err != nil {
+		return Coin{}, err
+	}
+	Tx := &Transaction{Message: mintMessage(coinID, amount), CoinID: coinID, Sender: goofy.UUID, Receiver: goofy.UUID, Amount: amount, R: r, S: s}
+	l.appendTx(Tx)
+
+	c := &Coin{UUID: coinID, Value: amount, Owner: goofy.UUID, TxHash: Tx.CurrHash}
+	l.coins = append(l.coins, c)
+	l.bus.publish(CoinMinted{Tx, *c})
+	return *c, nil
+}
+
+/*
+	Transfer() passes a coin from its owner to receiver, r and s sign
+	crypto.SpendDigest() with the owner's key, if they are nil the ledger
+	signs with the owner's key when it holds it. A non-nil prevHash must be
+	the hash of the coin's last Tx, otherwise the coin was spent meanwhile.
+	Only the owner, spender, can pass a coin on
+*/
+func (l *Ledger) Transfer(spender uuid.UUID, coinID uuid.UUID, receiver uuid.UUID, prevHash []byte, r, s *big.Int) (*Transaction, error) {
+	return l.TransferLocked(spender, coinID, receiver, nil, prevHash, r, s)
+}
+
+/*
+	TransferLocked() is Transfer() locking the coin for receiver with lock,
+	r and s sign crypto.LockedSpendDigest()
+*/
+func (l *Ledger) TransferLocked(spender uuid.UUID, coinID uuid.UUID, receiver uuid.UUID, lock *Lock, prevHash []byte, r, s *big.Int) (*Transaction, error) {
+	return l.Pay(Payment{Spender: spender, Coin: coinID, Receiver: receiver, Lock: lock, PrevHash: prevHash, R: r, S: s})
+}
+
+/*
+	Payment describes a transfer for Pay(), at most one of Lock, HashLock
+	and Script is set. Preimage claims a hash locked coin, Unlock unlocks a
+	scripted one. Memo travels with the coin
+*/
+type Payment struct {
+	Spender  uuid.UUID
+	Coin     uuid.UUID
+	Receiver uuid.UUID
+	Lock     *Lock
+	HashLock *HashLock
+	Preimage []byte
+	Script   []byte
+	Unlock   []byte
+	PrevHash []byte
+	Memo     Memo
+	R, S     *big.Int
+}
+
+/*
+	Pay() is Transfer() with the locks, preimage and scripts of p, R and S
+	sign crypto.LockedSpendDigest(), crypto.HashLockedSpendDigest() or
+	crypto.ScriptSpendDigest() when the payment locks the coin, wrapped in
+	crypto.MemoDigest() when it carries a memo. The sender of a hash locked
+	coin may spend it after the timeout, a scripted coin is spent without R
+	and S by any user who unlocks it
+*/
+func (l *Ledger) Pay(p Payment) (*Transaction, error) {
+	lock, err := p.Lock.validate()
+	if err != nil {
+		return nil, err
+	}
+	hashLock, err := p.HashLock.validate(p.Spender)
+	if err != nil {
+		return nil, err
+	}
+	lockScript, err := validateScript(p.Script)
+	if err != nil {
+		return nil, err
+	}
+	if (lock != nil && hashLock != nil) || (lockScript != nil && (lock != nil || hashLock != nil)) {
+		return nil, errkind.Failed("a transfer takes one of a lock, a hash lock and a script")
+	}
+	memo, err := p.Memo.validate()
+	if err != nil {
+		return nil, err
+	}
+	p.Lock, p.HashLock, p.Script, p.Memo = lock, hashLock, lockScript, memo
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pay(p)
+}
+
+/*
+	pay() is Pay() with the validated locks and memo of p, the caller holds
+	the lock
+*/
+func (l *Ledger) pay(p Payment) (*Transaction, error) {
+	r, s := p.R, p.S
+	c, err := l.coin(p.Coin)
+	if err != nil {
+		return nil, err
+	}
+	Tx := &Transaction{TimeStamp: time.Now().Unix(), CoinID: c.UUID, Sender: p.Spender, Receiver: p.Receiver, Amount: c.Value, CoinPrev: c.TxHash, Lock: p.Lock, HashLock: p.HashLock, Preimage: nilIfEmpty(p.Preimage), Script: p.Script, Unlock: nilIfEmpty(p.Unlock), Memo: p.Memo}
+	if err := c.spendableBy(Tx, len(l.chain)); err != nil {
+		return nil, err
+	}
+	if p.PrevHash != nil && !bytes.Equal(p.PrevHash, c.TxHash) {
+		return nil, errkind.DoubleSpend
+	}
+	sender, err := l.users.User(p.Spender)
+	if err != nil {
+		return nil, err
+	}
+	toName, err := l.accountName(p.Receiver)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.multisig(p.Receiver); err == nil && p.HashLock != nil {
+		return nil, errkind.Failed("a multisig cannot claim a hash locked coin")
+	}
+	Tx.Message = transferMessage(sender.Name, toName, c.Value)
+	if c.Script != nil {
+		l.pass(c, Tx)
+		return Tx, nil
+	}
+
+	digest := Tx.spendDigest()
+	if r == nil || s == nil {
+		if sender.PrivateKey == nil {
+			return nil, ErrSignatureRequired
+		}
+		r, s, err = crypto.Sign(sender.PrivateKey, digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !l.verify(sender.PublicKey, digest, r, s) {
+		return nil, ErrInvalidSignature
+	}
+
+	Tx.R, Tx.S = r, s
+	l.pass(c, Tx)
+	return Tx, nil
+}
+
+/*
+	pass() appends the verified Tx and moves c to its receiver
+*/
+func (l *Ledger) pass(c *Coin, Tx *Transaction) {
+	l.appendTx(Tx)
+	c.Owner = Tx.Receiver
+	c.TxHash = Tx.CurrHash
+	c.Lock = Tx.Lock
+	c.HashLock = Tx.HashLock
+	c.Script = Tx.Script
+	l.bus.publish(CoinTransferred{Tx, *c})
+}
+
+/*
+	VerifyChain() checks the hash links, every owner signature and the locks
+	and scripts of the chain
+*/
+func (l *Ledger) VerifyChain() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var prevBlock []byte
+	for i, b := range l.chain {
+		if b.Height != i || !bytes.Equal(b.PrevHash, prevBlock) {
+			return errors.New("broken block link at block " + strconv.Itoa(i))
+		}
+		if !bytes.Equal(b.blockHash(), b.Hash) {
+			return errors.New("hash mismatch at block " + strconv.Itoa(i))
+		}
+		prevBlock = b.Hash
+	}
+
+	// a Tx is appended to the open block at the height of the chain then
+	blocks := append(append([]*Block{}, l.chain...), &l.open)
+	coins := map[uuid.UUID]*Coin{}
+	used := map[uuid.UUID]bool{}
+	var prevHash []byte
+	n := 0
+	for height, b := range blocks {
+		for _, Tx := range b.Tx {
+			if err := l.verifyTx(n, height, Tx, prevHash, coins, used); err != nil {
+				return err
+			}
+			prevHash = Tx.CurrHash
+			n++
+		}
+	}
+	return nil
+}
+
+/*
+	verifyTx() checks Tx, the i-th of the chain appended at height, against
+	the hash of its predecessor and the coins as the transactions before it
+	left them. used holds every coin ID created so far, a mint by goofy
+	creates a new one
+*/
+func (l *Ledger) verifyTx(i int, height int, Tx *Transaction, prevHash []byte, coins map[uuid.UUID]*Coin, used map[uuid.UUID]bool) error {
+	if !bytes.Equal(Tx.PrevHash, prevHash) {
+		return errors.New("broken hash link at transaction " + strconv.Itoa(i))
+	}
+	if !bytes.Equal(Tx.hash(), Tx.CurrHash) {
+		return errors.New("hash mismatch at transaction " + strconv.Itoa(i))
+	}
+	scripted := false
+	if len(Tx.Inputs) > 0 {
+		if err := verifySplitMerge(i, height, Tx, coins); err != nil {
+			return err
+		}
+	} else if Tx.CoinPrev != nil {
+		c, ok := coins[Tx.CoinID]
+		if !ok || !bytes.Equal(Tx.CoinPrev, c.TxHash) {
+			return errors.New("broken coin link at transaction " + strconv.Itoa(i))
+		}
+		if Tx.Amount != c.Value {
+			return errors.New("transaction " + strconv.Itoa(i) + " changes the value of its coin")
+		}
+		if err := c.spendableBy(Tx, height); err != nil {
+			return errors.New("transaction " + strconv.Itoa(i) + " spends a coin it may not: " + err.Error())
+		}
+		scripted = c.Script != nil
+	} else {
+		goofy, err := l.users.Goofy()
+		if err != nil {
+			return err
+		}
+		if Tx.Sender != goofy.UUID || Tx.Receiver != goofy.UUID {
+			return errors.New("transaction " + strconv.Itoa(i) + " mints a coin without goofy")
+		}
+		if used[Tx.CoinID] {
+			return errors.New("transaction " + strconv.Itoa(i) + " mints a coin ID used before")
+		}
+	}
+	used[Tx.CoinID] = true
+	for _, out := range Tx.Outputs {
+		used[out.Coin] = true
+	}
+	if Tx.HashLock != nil && Tx.HashLock.Refund != Tx.Sender {
+		return errors.New("hash lock refunds another user at transaction " + strconv.Itoa(i))
+	}
+	if len(Tx.Inputs) == 0 {
+		coins[Tx.CoinID] = &Coin{Value: Tx.Amount, Owner: Tx.Receiver, TxHash: Tx.CurrHash, Lock: Tx.Lock, HashLock: Tx.HashLock, Script: Tx.Script}
+	}
+	if scripted {
+		// the locking script checked the signatures
+		return nil
+	}
+
+	digest := Tx.spendDigest()
+	if len(Tx.Sigs) > 0 {
+		ms, err := l.multisig(Tx.Sender)
+		if err != nil {
+			return err
+		}
+		if err := l.verifyMultisig(ms, digest, Tx.Sigs); err != nil {
+			return errors.New("invalid signatures at transaction " + strconv.Itoa(i) + ": " + err.Error())
+		}
+		return nil
+	}
+	if Tx.R == nil || Tx.S == nil {
+		return errors.New("unsigned transaction " + strconv.Itoa(i))
+	}
+	pub, err := l.users.PublicKey(Tx.Sender)
+	if err != nil {
+		return err
+	}
+	if !l.verify(pub, digest, Tx.R, Tx.S) {
+		return errors.New("invalid signature at transaction " + strconv.Itoa(i))
+	}
+	return nil
+}
+
+/*
+	Block Utilities
+	___________________________________________________________________________
+*/
+
+/*
+	blockHash() returns the SHA-256 hash over the header of b and the hash of
+	every Tx in it
+*/
+func (b *Block) blockHash() []byte {
+	data := [][]byte{[]byte(strconv.Itoa(b.Height)), []byte(strconv.FormatInt(b.TimeStamp, 10)), b.PrevHash}
+	for _, Tx := range b.Tx {
+		data = append(data, Tx.CurrHash)
+	}
+	hash := sha256.Sum256(bytes.Join(data, []byte{}))
+	return hash[:]
+}
+
+/*
+	MarshalJSON() exposes the header of b to the API
+*/
+func (b *Block) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Height    int      `json:"height"`
+		TimeStamp int64    `json:"timeStamp"`
+		PrevHash  HexBytes `json:"prevHash"`
+		Hash      HexBytes `json:"hash"`
+		TxCount   int      `json:"txCount"`
+	}{b.Height, b.TimeStamp, b.PrevHash, b.Hash, len(b.Tx)})
+}
+
+/*
+	Height() returns the number of sealed blocks
+*/
+func (l *Ledger) Height() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.chain)
+}
+
+/*
+	TipHash() returns the hash of the last sealed block, nil before the
+	first is sealed
+*/
+func (l *Ledger) TipHash() []byte {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.chain) == 0 {
+		return nil
+	}
+	return l.chain[len(l.chain)-1].Hash
+}
+
+/*
+	SealBlock() appends the open block to the chain and opens a new one,
+	nothing is sealed while the open block is empty
+*/
+func (l *Ledger) SealBlock() *Block {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.open.Tx) == 0 {
+		return nil
+	}
+	b := &Block{Tx: l.open.Tx, Height: len(l.chain), TimeStamp: time.Now().Unix()}
+	if len(l.chain) > 0 {
+		b.PrevHash = l.chain[len(l.chain)-1].Hash
+	}
+	b.Hash = b.blockHash()
+	l.chain = append(l.chain, b)
+	l.open = Block{}
+	l.bus.publish(BlockSealed{b})
+	return b
+}
+
+/*
+	SealBlocks() seals the open block every interval until ctx is done
+*/
+func (l *Ledger) SealBlocks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.SealBlock()
+		}
+	}
+}
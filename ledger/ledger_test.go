@@ -0,0 +1,258 @@
+package ledger
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/wallet"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	newLedger() returns a ledger with goofy and users of the given names,
+	their keys held by the ledger
+*/
+func newLedger(t *testing.T, names ...string) (*Ledger, []wallet.User) {
+	l := New()
+	var users []wallet.User
+	for _, name := range append([]string{"goofy"}, names...) {
+		u, err := l.RegisterUser(name, nil, "")
+		if err != nil {
+			t.Fatalf("cannot create user %s: %v", name, err)
+		}
+		users = append(users, u)
+	}
+	return l, users
+}
+
+func TestHashLinks(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob", "claire")
+	goofy := users[0]
+
+	c, err := l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users[1:] {
+		if _, err := l.Transfer(c.Owner, c.UUID, u.UUID, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		c, _ = l.Coin(c.UUID)
+	}
+
+	txs := l.Transactions()
+	for i, Tx := range txs {
+		if i != 0 && !bytes.Equal(Tx.PrevHash, txs[i-1].CurrHash) {
+			t.Error("error in hashing")
+		}
+		t.Logf("time stamp : %d", Tx.TimeStamp)
+		t.Logf("tx message : %s", Tx.Message)
+		t.Logf("prevhash   : %x", Tx.PrevHash)
+		t.Logf("currhash   : %x", Tx.CurrHash)
+	}
+	if string(txs[2].Message) != "alice paid bob 10 goofy coins" {
+		t.Errorf("unexpected message %q", txs[2].Message)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	l, users := newLedger(t, "alice")
+	goofy, alice := users[0], users[1]
+	bobPriv, bobPub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := l.RegisterUser("bob", bobPub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Transfer(alice.UUID, c.UUID, bob.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+	if c.Owner != bob.UUID {
+		t.Error("coin not passed to bob")
+	}
+
+	// the ledger does not hold bob's key, he has to sign himself
+	_, err = l.Transfer(bob.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != ErrSignatureRequired {
+		t.Errorf("transfer without signature returned %v", err)
+	}
+	r, s, err := crypto.Sign(bobPriv, crypto.SpendDigest(c.UUID, alice.UUID, c.TxHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Transfer(bob.UUID, c.UUID, goofy.UUID, nil, r, s)
+	if err != ErrInvalidSignature {
+		t.Errorf("signature for another receiver returned %v", err)
+	}
+	_, err = l.Transfer(bob.UUID, c.UUID, alice.UUID, c.TxHash, r, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.VerifyChain()
+	if err != nil {
+		t.Error(err)
+	}
+	b := l.SealBlock()
+	if b == nil || len(b.Tx) != 4 || l.Len() != 4 || l.SealBlock() != nil {
+		t.Error("open block not sealed")
+	}
+	if l.Height() != 1 || !bytes.Equal(l.TipHash(), b.Hash) {
+		t.Error("sealed block is not the tip")
+	}
+	// the chain continues across the sealed block
+	_, err = l.Transfer(alice.UUID, c.UUID, bob.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.VerifyChain()
+	if err != nil {
+		t.Error(err)
+	}
+	balance, err := l.Balance(bob.UUID)
+	if err != nil || balance.Balance != 10 || balance.Spendable != 10 || len(balance.Coins) != 1 {
+		t.Errorf("bob has %d in %d coins, %v", balance.Balance, len(balance.Coins), err)
+	}
+	b.Tx[1].Amount = 1000
+	err = l.VerifyChain()
+	if err == nil {
+		t.Error("tampered chain verified")
+	}
+}
+
+func TestLedgerErrors(t *testing.T) {
+	l, users := newLedger(t, "alice")
+	goofy, alice := users[0], users[1]
+	c, err := l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := c.TxHash
+	_, err = l.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		kind error
+	}{
+		{"zero amount", func() error { _, err := l.Mint(goofy.UUID, 0); return err }, errkind.Invalid},
+		{"mint by alice", func() error { _, err := l.Mint(alice.UUID, 10); return err }, errkind.Forbidden},
+		{"spend another's coin", func() error { _, err := l.Transfer(goofy.UUID, c.UUID, goofy.UUID, nil, nil, nil); return err }, errkind.Forbidden},
+		{"double spend", func() error { _, err := l.Transfer(alice.UUID, c.UUID, goofy.UUID, stale, nil, nil); return err }, errkind.DoubleSpend},
+		{"unknown receiver", func() error { _, err := l.Transfer(alice.UUID, c.UUID, c.UUID, nil, nil, nil); return err }, errkind.NotFound},
+		{"unknown coin", func() error { _, err := l.Transfer(alice.UUID, alice.UUID, goofy.UUID, nil, nil, nil); return err }, errkind.NotFound},
+		{"unknown transaction", func() error { _, err := l.Transaction([]byte{0}); return err }, errkind.NotFound},
+		{"name taken", func() error { _, err := l.RegisterUser("Alice", nil, ""); return err }, errkind.Conflict},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); !errors.Is(err, tt.kind) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.kind)
+		}
+	}
+	if len(l.Users()) != 2 || len(l.Coins()) != 1 || l.Len() != 2 {
+		t.Error("failed call changed the ledger")
+	}
+}
+
+func TestVerifyMints(t *testing.T) {
+	l, users := newLedger(t, "alice")
+	goofy, alice := users[0], users[1]
+	c, _ := l.Mint(goofy.UUID, 10)
+
+	// forged mints signed by their sender
+	mint := func(u wallet.User, coinID uuid.UUID) *Transaction {
+		r, s, err := crypto.Sign(u.PrivateKey, crypto.SpendDigest(coinID, u.UUID, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		Tx := &Transaction{Message: mintMessage(coinID, 10), CoinID: coinID, Sender: u.UUID, Receiver: u.UUID, Amount: 10, R: r, S: s}
+		l.appendTx(Tx)
+		return Tx
+	}
+	tests := []struct {
+		name   string
+		user   wallet.User
+		coinID uuid.UUID
+		want   string
+	}{
+		{"mint by alice", alice, uuid.Must(uuid.NewV4()), "without goofy"},
+		{"mint of a used coin ID", goofy, c.UUID, "used before"},
+	}
+	for _, tt := range tests {
+		mint(tt.user, tt.coinID)
+		if err := l.VerifyChain(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+		l.open.Tx = l.open.Tx[:len(l.open.Tx)-1]
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	valid := map[string]int{"1": 1, "10": 10, "2147483647": MaxAmount}
+	invalid := []string{"", "0", "-5", "1.5", "1e3", "1 0", "ten", "2147483648", "99999999999999999999"}
+	for s, want := range valid {
+		amount, err := ParseAmount(s)
+		if err != nil || amount != want {
+			t.Errorf("%q: got %d, %v", s, amount, err)
+		}
+	}
+	for _, s := range invalid {
+		if _, err := ParseAmount(s); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestTransactionsOf(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob", "carol")
+	goofy, alice, bob, carol := users[0], users[1], users[2], users[3]
+	ms, err := l.CreateMultisig(1, []uuid.UUID{bob.UUID, carol.UUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := l.Mint(goofy.UUID, 10)
+	l.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	l.Transfer(alice.UUID, c.UUID, ms.UUID, nil, nil, nil)
+
+	for _, tt := range []struct {
+		name string
+		user uuid.UUID
+		want int
+	}{
+		{"goofy", goofy.UUID, 3},
+		{"alice", alice.UUID, 2},
+		{"owner of the multisig", carol.UUID, 1},
+		{"multisig", ms.UUID, 1},
+		{"stranger", uuid.Must(uuid.NewV4()), 0},
+	} {
+		if txs := l.TransactionsOf(tt.user); len(txs) != tt.want {
+			t.Errorf("%s sees %d transactions, want %d", tt.name, len(txs), tt.want)
+		}
+	}
+	if Tx := l.Transactions()[0]; l.VisibleTo(alice.UUID, Tx) {
+		t.Error("alice sees the mint of goofy")
+	}
+}
@@ -0,0 +1,68 @@
+package ledger
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/de7ign/goofy-coin/errkind"
+)
+
+/*
+	Time Locks
+	___________________________________________________________________________
+
+	A transfer may lock the coin for its receiver until the chain has Height
+	sealed blocks and until the unix time Time. The spending Tx is checked
+	against the height when it was appended and its TimeStamp, so
+	VerifyChain() can check the locks again later. Vesting pays several coins
+	with increasing locks
+*/
+
+/*
+	Lock keeps the receiver of a coin from spending it early, a zero field
+	does not lock
+*/
+type Lock struct {
+	Height int   `json:"height,omitempty"`
+	Time   int64 `json:"time,omitempty"`
+}
+
+/*
+	validate() rejects negative locks and returns nil for a lock which locks
+	nothing
+*/
+func (lk *Lock) validate() (*Lock, error) {
+	if lk == nil {
+		return nil, nil
+	}
+	if lk.Height < 0 || lk.Time < 0 {
+		return nil, errkind.Failed("lock height and time must not be negative")
+	}
+	if *lk == (Lock{}) {
+		return nil, nil
+	}
+	copied := *lk
+	return &copied, nil
+}
+
+/*
+	opened() reports whether a coin with lock lk is spendable at height by a
+	Tx stamped now
+*/
+func (lk *Lock) opened(height int, now int64) bool {
+	return lk == nil || (height >= lk.Height && now >= lk.Time)
+}
+
+/*
+	check() returns why a coin with lock lk cannot be spent at height by a
+	Tx stamped now, nil if it can
+*/
+func (lk *Lock) check(height int, now int64) error {
+	if lk.opened(height, now) {
+		return nil
+	}
+	if height < lk.Height {
+		return errkind.Failed("coin is locked until block " + strconv.Itoa(lk.Height) + ", the chain has " + strconv.Itoa(height))
+	}
+	return errkind.Failed("coin is locked until " + time.Unix(lk.Time, 0).UTC().Format(time.RFC3339))
+}
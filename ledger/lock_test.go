@@ -0,0 +1,98 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+)
+
+func TestHeightLock(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob")
+	goofy, alice, bob := users[0], users[1], users[2]
+
+	c, err := l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.TransferLocked(goofy.UUID, c.UUID, alice.UUID, &Lock{Height: 2}, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.Balance(alice.UUID)
+	if err != nil || b.Balance != 10 || b.Locked != 10 || b.Spendable != 0 {
+		t.Errorf("alice has %+v, %v", b, err)
+	}
+	for height := 0; height < 2; height++ {
+		if _, err := l.Transfer(alice.UUID, c.UUID, bob.UUID, nil, nil, nil); !errors.Is(err, errkind.Invalid) {
+			t.Errorf("spend at height %d returned %v", height, err)
+		}
+		l.SealBlock()
+		if _, err := l.Mint(goofy.UUID, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b, _ := l.Balance(alice.UUID); b.Spendable != 10 || b.Locked != 0 {
+		t.Errorf("alice has %+v at height 2", b)
+	}
+	if _, err := l.Transfer(alice.UUID, c.UUID, bob.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := l.Coin(c.UUID); c.Lock != nil {
+		t.Error("lock passed on with the coin")
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTimeLock(t *testing.T) {
+	l, users := newLedger(t, "alice")
+	goofy, alice := users[0], users[1]
+	bobPriv, bobPub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := l.RegisterUser("bob", bobPub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := l.Mint(goofy.UUID, 10)
+	if _, err := l.Transfer(goofy.UUID, c.UUID, bob.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+
+	// the lock is part of what the sender signs
+	future := &Lock{Time: time.Now().Add(time.Hour).Unix()}
+	r, s, err := crypto.Sign(bobPriv, crypto.SpendDigest(c.UUID, alice.UUID, c.TxHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.TransferLocked(bob.UUID, c.UUID, alice.UUID, future, nil, r, s); err != ErrInvalidSignature {
+		t.Errorf("signature without the lock returned %v", err)
+	}
+	r, s, err = crypto.Sign(bobPriv, crypto.LockedSpendDigest(c.UUID, alice.UUID, c.TxHash, 0, future.Time))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Tx, err := l.TransferLocked(bob.UUID, c.UUID, alice.UUID, future, nil, r, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Transfer(alice.UUID, c.UUID, goofy.UUID, nil, nil, nil); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("spend before the lock time returned %v", err)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+	Tx.Lock.Time = 0
+	if err := l.VerifyChain(); err == nil {
+		t.Error("chain with a removed lock verified")
+	}
+
+	if _, err := l.TransferLocked(goofy.UUID, c.UUID, alice.UUID, &Lock{Height: -1}, nil, nil, nil); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("negative lock returned %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package ledger
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Memos
+	___________________________________________________________________________
+
+	A transfer may carry a memo, a few key-value pairs such as an order
+	number. The memo is part of the Tx hash and of the digest the sender
+	signs, crypto.MemoDigest(), so nobody can change it once the coin moved.
+	Users search the transactions they may see, see VisibleTo(), by memo key
+	or key and value
+*/
+
+const (
+	MaxMemoKeys = 8
+	MaxMemoKey  = 32
+	MaxMemoSize = 256
+)
+
+/*
+	Memo maps keys to values, its size is the length of every key and value
+*/
+type Memo map[string]string
+
+/*
+	validate() returns a copy of m if it is within the limits, nil for an
+	empty memo
+*/
+func (m Memo) validate() (Memo, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	if len(m) > MaxMemoKeys {
+		return nil, errkind.Failed("memo has more than " + strconv.Itoa(MaxMemoKeys) + " keys")
+	}
+	size := 0
+	memo := Memo{}
+	for k, v := range m {
+		if k == "" || len(k) > MaxMemoKey || strings.Trim(k, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_.-") != "" {
+			return nil, errkind.Failed("memo key " + strconv.Quote(k) + " is not 1 to " + strconv.Itoa(MaxMemoKey) + " letters, digits, '_', '.' or '-'")
+		}
+		if !utf8.ValidString(v) {
+			return nil, errkind.Failed("memo value of " + k + " is not UTF-8")
+		}
+		size += len(k) + len(v)
+		memo[k] = v
+	}
+	if size > MaxMemoSize {
+		return nil, errkind.Failed("memo is larger than " + strconv.Itoa(MaxMemoSize) + " bytes")
+	}
+	return memo, nil
+}
+
+/*
+	bytes() returns the canonical encoding of m, hashed with the Tx
+*/
+func (m Memo) bytes() []byte {
+	return crypto.MemoBytes(m)
+}
+
+/*
+	matches() reports whether m has the key of query, "key" or "key=value",
+	with the value if one is given
+*/
+func (m Memo) matches(query string) bool {
+	key, value, byValue := strings.Cut(query, "=")
+	v, ok := m[key]
+	return ok && (!byValue || v == value)
+}
+
+/*
+	TransactionsWithMemo() returns every Tx user may see whose memo matches
+	query, a key or key=value, in chain order
+*/
+func (l *Ledger) TransactionsWithMemo(user uuid.UUID, query string) []*Transaction {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	txs := []*Transaction{}
+	for _, Tx := range l.allTx() {
+		if l.visibleTo(user, Tx) && Tx.Memo.matches(query) {
+			txs = append(txs, Tx)
+		}
+	}
+	return txs
+}
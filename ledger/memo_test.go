@@ -0,0 +1,88 @@
+package ledger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+)
+
+func TestMemo(t *testing.T) {
+	l, users := newLedger(t, "alice")
+	goofy, alice := users[0], users[1]
+	bobPriv, bobPub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := l.RegisterUser("bob", bobPub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := l.Mint(goofy.UUID, 10)
+	if _, err := l.Transfer(goofy.UUID, c.UUID, bob.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+
+	for _, memo := range []Memo{
+		{"": "x"},
+		{"bad key": "x"},
+		{strings.Repeat("k", MaxMemoKey+1): "x"},
+		{"note": strings.Repeat("x", MaxMemoSize)},
+		{"a": "", "b": "", "c": "", "d": "", "e": "", "f": "", "g": "", "h": "", "i": ""},
+	} {
+		if _, err := l.Pay(Payment{Spender: bob.UUID, Coin: c.UUID, Receiver: alice.UUID, Memo: memo}); !errors.Is(err, errkind.Invalid) {
+			t.Errorf("memo %v returned %v", memo, err)
+		}
+	}
+
+	// the memo is part of what the sender signs
+	memo := Memo{"order": "42", "note": "tea"}
+	r, s, err := crypto.Sign(bobPriv, crypto.SpendDigest(c.UUID, alice.UUID, c.TxHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Pay(Payment{Spender: bob.UUID, Coin: c.UUID, Receiver: alice.UUID, Memo: memo, R: r, S: s}); err != ErrInvalidSignature {
+		t.Errorf("signature without the memo returned %v", err)
+	}
+	r, s, err = crypto.Sign(bobPriv, crypto.MemoDigest(crypto.SpendDigest(c.UUID, alice.UUID, c.TxHash), memo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Tx, err := l.Pay(Payment{Spender: bob.UUID, Coin: c.UUID, Receiver: alice.UUID, Memo: memo, R: r, S: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memo["order"] = "43"
+	if Tx.Memo["order"] != "42" {
+		t.Error("memo shared with the payment")
+	}
+	if _, err := l.Transfer(alice.UUID, c.UUID, goofy.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, n := range map[string]int{"order": 1, "order=42": 1, "order=4": 0, "note=tea": 1, "tea": 0} {
+		if txs := l.TransactionsWithMemo(bob.UUID, query); len(txs) != n {
+			t.Errorf("%q matched %d transactions, want %d", query, len(txs), n)
+		}
+	}
+	carol, err := l.RegisterUser("carol", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs := l.TransactionsWithMemo(carol.UUID, "order"); len(txs) != 0 {
+		t.Errorf("memo search found %d transactions of others", len(txs))
+	}
+	if txs := l.TransactionsWithMemo(goofy.UUID, "note=tea"); len(txs) != 1 {
+		t.Errorf("memo search of goofy found %d transactions", len(txs))
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+	Tx.Memo["order"] = "43"
+	if err := l.VerifyChain(); err == nil {
+		t.Error("changed memo verified")
+	}
+}
@@ -0,0 +1,239 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Multisig
+	___________________________________________________________________________
+
+	A multisig is an account owned by n users, a coin passed to it can only
+	be passed on with the signatures of m of them. Each owner signs the same
+	crypto.SpendDigest() as a single owner would
+*/
+
+const MaxMultisigOwners = 16
+
+var ErrMultisigNotFound = &errkind.Error{Kind: errkind.NotFound, Message: "multisig not found"}
+
+/*
+	Multisig is an m-of-n account, its UUID receives coins like a user's
+*/
+type Multisig struct {
+	UUID   uuid.UUID   `json:"uuid"`
+	M      int         `json:"m"`
+	Owners []uuid.UUID `json:"owners"`
+}
+
+/*
+	String() names ms in transaction messages
+*/
+func (ms Multisig) String() string {
+	return strconv.Itoa(ms.M) + "-of-" + strconv.Itoa(len(ms.Owners)) + " multisig"
+}
+
+/*
+	HasOwner() reports whether id is one of the owners of ms
+*/
+func (ms Multisig) HasOwner(id uuid.UUID) bool {
+	for _, owner := range ms.Owners {
+		if owner == id {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Signature is the signature of one owner of a multisig
+*/
+type Signature struct {
+	Signer uuid.UUID
+	R      *big.Int
+	S      *big.Int
+}
+
+func (sig Signature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Signer uuid.UUID `json:"signer"`
+		R      string    `json:"r"`
+		S      string    `json:"s"`
+	}{sig.Signer, bigHex(sig.R), bigHex(sig.S)})
+}
+
+func (sig *Signature) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Signer uuid.UUID `json:"signer"`
+		R      string    `json:"r"`
+		S      string    `json:"s"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r, s, err := crypto.ParseSignature(v.R, v.S)
+	if err != nil {
+		return err
+	}
+	*sig = Signature{Signer: v.Signer, R: r, S: s}
+	return nil
+}
+
+/*
+	CreateMultisig() adds an account which needs the signatures of m of
+	owners to spend its coins
+*/
+func (l *Ledger) CreateMultisig(m int, owners []uuid.UUID) (Multisig, error) {
+	if len(owners) == 0 || len(owners) > MaxMultisigOwners {
+		return Multisig{}, errkind.Failed("a multisig needs 1 to " + strconv.Itoa(MaxMultisigOwners) + " owners")
+	}
+	if m < 1 || m > len(owners) {
+		return Multisig{}, errkind.Failed("m must be between 1 and the number of owners")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.createMultisig(m, owners)
+}
+
+/*
+	createMultisig() is CreateMultisig() with valid counts, the caller holds
+	the lock
+*/
+func (l *Ledger) createMultisig(m int, owners []uuid.UUID) (Multisig, error) {
+	seen := map[uuid.UUID]bool{}
+	for _, owner := range owners {
+		if seen[owner] {
+			return Multisig{}, errkind.Failed("owner " + owner.String() + " is listed twice")
+		}
+		seen[owner] = true
+		if _, err := l.users.User(owner); err != nil {
+			return Multisig{}, err
+		}
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Multisig{}, err
+	}
+	ms := Multisig{UUID: id, M: m, Owners: append([]uuid.UUID{}, owners...)}
+	l.multisigs = append(l.multisigs, ms)
+	l.bus.publish(MultisigCreated{ms})
+	return ms, nil
+}
+
+func (l *Ledger) multisig(id uuid.UUID) (Multisig, error) {
+	for _, ms := range l.multisigs {
+		if ms.UUID == id {
+			return ms, nil
+		}
+	}
+	return Multisig{}, ErrMultisigNotFound
+}
+
+/*
+	Multisig() returns the multisig with provided uuid
+*/
+func (l *Ledger) Multisig(id uuid.UUID) (Multisig, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.multisig(id)
+}
+
+/*
+	MultisigsOf() returns every multisig user is an owner of
+*/
+func (l *Ledger) MultisigsOf(user uuid.UUID) []Multisig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	list := []Multisig{}
+	for _, ms := range l.multisigs {
+		if ms.HasOwner(user) {
+			list = append(list, ms)
+		}
+	}
+	return list
+}
+
+/*
+	verifyMultisig() checks that sigs holds valid signatures of digest by
+	at least m distinct owners of ms
+*/
+func (l *Ledger) verifyMultisig(ms Multisig, digest []byte, sigs []Signature) error {
+	if len(sigs) < ms.M {
+		return &errkind.Error{Kind: errkind.Invalid, Message: "needs " + strconv.Itoa(ms.M) + " signatures, got " + strconv.Itoa(len(sigs))}
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, sig := range sigs {
+		if !ms.HasOwner(sig.Signer) {
+			return errkind.New(errkind.Forbidden, "signer "+sig.Signer.String()+" is not an owner of the multisig")
+		}
+		if seen[sig.Signer] {
+			return errkind.Failed("signer " + sig.Signer.String() + " signed twice")
+		}
+		seen[sig.Signer] = true
+		pub, err := l.users.PublicKey(sig.Signer)
+		if err != nil {
+			return err
+		}
+		if sig.R == nil || sig.S == nil || !l.verify(pub, digest, sig.R, sig.S) {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+/*
+	SpendMultisig() passes a coin owned by a multisig to receiver, sigs sign
+	crypto.SpendDigest() with the keys of at least m owners. A non-nil
+	prevHash must be the hash of the coin's last Tx
+*/
+func (l *Ledger) SpendMultisig(coinID uuid.UUID, receiver uuid.UUID, prevHash []byte, sigs []Signature) (*Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, err := l.coin(coinID)
+	if err != nil {
+		return nil, err
+	}
+	if prevHash != nil && !bytes.Equal(prevHash, c.TxHash) {
+		return nil, errkind.DoubleSpend
+	}
+	ms, err := l.multisig(c.Owner)
+	if err != nil {
+		return nil, errkind.New(errkind.Forbidden, "coin is not owned by a multisig")
+	}
+	if c.Script != nil {
+		return nil, errkind.Failed("coin is spent with its locking script")
+	}
+	if err := c.Lock.check(len(l.chain), time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	toName, err := l.accountName(receiver)
+	if err != nil {
+		return nil, err
+	}
+	escrow := l.escrowOf(c.UUID)
+	if escrow != nil {
+		if err := escrow.allows(receiver, sigs); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.verifyMultisig(ms, crypto.SpendDigest(c.UUID, receiver, c.TxHash), sigs); err != nil {
+		return nil, err
+	}
+
+	Tx := &Transaction{Message: transferMessage(ms.String(), toName, c.Value), CoinID: c.UUID, Sender: ms.UUID, Receiver: receiver, Amount: c.Value, CoinPrev: c.TxHash, Sigs: append([]Signature{}, sigs...)}
+	l.pass(c, Tx)
+	if escrow != nil {
+		l.settle(escrow, Tx)
+	}
+	return Tx, nil
+}
@@ -0,0 +1,116 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/wallet"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	signSpend() signs the spend of coin c to receiver by each of users
+*/
+func signSpend(t *testing.T, c Coin, receiver uuid.UUID, users ...wallet.User) []Signature {
+	var sigs []Signature
+	for _, u := range users {
+		r, s, err := crypto.Sign(u.PrivateKey, crypto.SpendDigest(c.UUID, receiver, c.TxHash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs = append(sigs, Signature{Signer: u.UUID, R: r, S: s})
+	}
+	return sigs
+}
+
+func TestMultisig(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob", "claire", "dave")
+	goofy, alice, bob, claire, dave := users[0], users[1], users[2], users[3], users[4]
+
+	ms, err := l.CreateMultisig(2, []uuid.UUID{alice.UUID, bob.UUID, claire.UUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.MultisigsOf(bob.UUID); len(got) != 1 || got[0].UUID != ms.UUID {
+		t.Errorf("bob owns %v", got)
+	}
+	c, err := l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Transfer(goofy.UUID, c.UUID, ms.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+	if b, err := l.Balance(ms.UUID); err != nil || b.Balance != 10 {
+		t.Errorf("multisig has %d, %v", b.Balance, err)
+	}
+
+	// no single owner can spend the coin
+	if _, err := l.Transfer(alice.UUID, c.UUID, dave.UUID, nil, nil, nil); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("transfer by one owner returned %v", err)
+	}
+	tests := []struct {
+		name string
+		sigs []Signature
+		kind error
+	}{
+		{"one signature", signSpend(t, c, dave.UUID, alice), errkind.Invalid},
+		{"same owner twice", signSpend(t, c, dave.UUID, alice, alice), errkind.Invalid},
+		{"not an owner", signSpend(t, c, dave.UUID, alice, dave), errkind.Forbidden},
+		{"other receiver", signSpend(t, c, goofy.UUID, alice, bob), errkind.Invalid},
+	}
+	for _, test := range tests {
+		if _, err := l.SpendMultisig(c.UUID, dave.UUID, nil, test.sigs); !errors.Is(err, test.kind) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.kind)
+		}
+	}
+
+	Tx, err := l.SpendMultisig(c.UUID, dave.UUID, c.TxHash, signSpend(t, c, dave.UUID, claire, alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Tx.Sender != ms.UUID || string(Tx.Message) != "2-of-3 multisig paid dave 10 goofy coins" {
+		t.Errorf("unexpected transaction %s from %s", Tx.Message, Tx.Sender)
+	}
+	if _, err := l.SpendMultisig(c.UUID, dave.UUID, c.TxHash, signSpend(t, c, dave.UUID, claire, alice)); !errors.Is(err, errkind.DoubleSpend) {
+		t.Errorf("replayed spend returned %v", err)
+	}
+	if _, err := l.SpendMultisig(c.UUID, goofy.UUID, nil, nil); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("spend of a coin the multisig no longer owns returned %v", err)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+	Tx.Sigs[1].Signer = bob.UUID
+	if err := l.VerifyChain(); err == nil {
+		t.Error("tampered signatures verified")
+	}
+}
+
+func TestCreateMultisig(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob")
+	alice, bob := users[1].UUID, users[2].UUID
+	tests := []struct {
+		name   string
+		m      int
+		owners []uuid.UUID
+		kind   error
+	}{
+		{"no owners", 1, nil, errkind.Invalid},
+		{"m of zero", 0, []uuid.UUID{alice, bob}, errkind.Invalid},
+		{"m above n", 3, []uuid.UUID{alice, bob}, errkind.Invalid},
+		{"owner twice", 2, []uuid.UUID{alice, alice}, errkind.Invalid},
+		{"unknown owner", 1, []uuid.UUID{alice, uuid.Must(uuid.NewV4())}, errkind.NotFound},
+	}
+	for _, test := range tests {
+		if _, err := l.CreateMultisig(test.m, test.owners); !errors.Is(err, test.kind) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.kind)
+		}
+	}
+	if _, err := l.Multisig(alice); !errors.Is(err, ErrMultisigNotFound) {
+		t.Errorf("user found as multisig: %v", err)
+	}
+}
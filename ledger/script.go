@@ -0,0 +1,102 @@
+package ledger
+
+import (
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/script"
+)
+
+/*
+	Scripts
+	___________________________________________________________________________
+
+	A transfer may lock the coin with a Script instead of a Lock or HashLock,
+	see package script. Whoever presents an Unlock script which makes it true
+	may then spend the coin, the receiver is its owner only for balances. The
+	CHECKSIG operations check the digest of the spending Tx, so a signature
+	cannot be replayed for another receiver or output. A scripted spend
+	carries no signature of its sender
+*/
+
+/*
+	validateScript() rejects a locking script which cannot be run
+*/
+func validateScript(lock []byte) ([]byte, error) {
+	if len(lock) == 0 {
+		return nil, nil
+	}
+	if err := script.Validate(lock); err != nil {
+		return nil, errkind.Failed("locking script: " + err.Error())
+	}
+	return append([]byte{}, lock...), nil
+}
+
+/*
+	spendableBy() returns why the sender of Tx, appended at height, cannot
+	spend c, nil if it can. The owner of a plain coin spends it once its
+	lock opened, the owner of a hash locked coin claims it with the
+	preimage and its refund user takes it back after the timeout. A scripted
+	coin is spent by whoever unlocks its script
+*/
+func (c *Coin) spendableBy(Tx *Transaction, height int) error {
+	hl, spender := c.HashLock, Tx.Sender
+	preimage := Tx.Preimage
+	if c.Script == nil && len(Tx.Unlock) > 0 {
+		return errkind.Failed("coin has no locking script")
+	}
+	switch {
+	case c.Script != nil:
+		if len(preimage) > 0 {
+			return errkind.Failed("coin is not hash locked")
+		}
+		ctx := script.Context{Digest: Tx.spendDigest(), Height: height, Time: Tx.TimeStamp}
+		if err := script.Run(Tx.Unlock, c.Script, ctx); err != nil {
+			return errkind.Failed("locking script refused the spend: " + err.Error())
+		}
+		return nil
+	case hl == nil:
+		if spender != c.Owner {
+			return errkind.New(errkind.Forbidden, "only the owner can spend a coin")
+		}
+		if len(preimage) > 0 {
+			return errkind.Failed("coin is not hash locked")
+		}
+		return c.Lock.check(height, Tx.TimeStamp)
+	case spender == c.Owner && (len(preimage) > 0 || spender != hl.Refund):
+		if !hl.unlocks(preimage) {
+			return errkind.Failed("preimage does not match the hash lock")
+		}
+		return nil
+	case spender == hl.Refund:
+		return hl.Timeout.check(height, Tx.TimeStamp)
+	default:
+		return errkind.New(errkind.Forbidden, "only the receiver or, after the timeout, the sender can spend a hash locked coin")
+	}
+}
+
+/*
+	spendDigest() returns the digest the sender of Tx signs, it covers the
+	lock, hash lock or script Tx puts on the coin and its memo, or the
+	coins a split or merge spends and creates
+*/
+func (Tx *Transaction) spendDigest() []byte {
+	if len(Tx.Inputs) > 0 {
+		return Tx.splitMergeDigest()
+	}
+	return crypto.MemoDigest(Tx.lockDigest(), Tx.Memo)
+}
+
+/*
+	lockDigest() returns the digest of Tx without its memo
+*/
+func (Tx *Transaction) lockDigest() []byte {
+	switch lk, hl := Tx.Lock, Tx.HashLock; {
+	case Tx.Script != nil:
+		return crypto.ScriptSpendDigest(Tx.CoinID, Tx.Receiver, Tx.CoinPrev, Tx.Script)
+	case hl != nil:
+		return crypto.HashLockedSpendDigest(Tx.CoinID, Tx.Receiver, Tx.CoinPrev, hl.Hash, hl.Timeout.Height, hl.Timeout.Time)
+	case lk != nil:
+		return crypto.LockedSpendDigest(Tx.CoinID, Tx.Receiver, Tx.CoinPrev, lk.Height, lk.Time)
+	}
+	return crypto.SpendDigest(Tx.CoinID, Tx.Receiver, Tx.CoinPrev)
+}
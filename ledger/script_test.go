@@ -0,0 +1,69 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/de7ign/goofy-coin/script"
+	"github.com/gofrs/uuid"
+)
+
+func TestScript(t *testing.T) {
+	l, users := newLedger(t, "alice", "bob", "claire")
+	goofy, alice, bob, claire := users[0], users[1], users[2], users[3]
+	lock := script.AppendData(nil, crypto.CompressPublicKey(bob.PublicKey))
+	lock = append(lock, byte(script.OpCheckSig))
+
+	c, _ := l.Mint(goofy.UUID, 10)
+	if _, err := l.Pay(Payment{Spender: goofy.UUID, Coin: c.UUID, Receiver: bob.UUID, Script: lock}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+	if b, _ := l.Balance(bob.UUID); b.Locked != 10 {
+		t.Errorf("bob has %+v", b)
+	}
+
+	// bob signs the spend to claire, anyone may submit it
+	unlock := func(receiver uuid.UUID) []byte {
+		r, s, err := crypto.Sign(bob.PrivateKey, crypto.SpendDigest(c.UUID, receiver, c.TxHash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return script.AppendData(nil, crypto.SignatureBytes(r, s))
+	}
+	tests := []struct {
+		name string
+		p    Payment
+		kind error
+	}{
+		{"no unlock", Payment{Spender: bob.UUID, Coin: c.UUID, Receiver: claire.UUID}, errkind.Invalid},
+		{"other receiver", Payment{Spender: alice.UUID, Coin: c.UUID, Receiver: claire.UUID, Unlock: unlock(alice.UUID)}, errkind.Invalid},
+		{"not push only", Payment{Spender: alice.UUID, Coin: c.UUID, Receiver: claire.UUID, Unlock: []byte{byte(script.OpDup)}}, errkind.Invalid},
+		{"malformed script", Payment{Spender: goofy.UUID, Coin: c.UUID, Receiver: claire.UUID, Script: []byte{5, 1}}, errkind.Invalid},
+		{"script and lock", Payment{Spender: goofy.UUID, Coin: c.UUID, Receiver: claire.UUID, Script: lock, Lock: &Lock{Height: 1}}, errkind.Invalid},
+	}
+	for _, test := range tests {
+		if _, err := l.Pay(test.p); !errors.Is(err, test.kind) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.kind)
+		}
+	}
+	Tx, err := l.Pay(Payment{Spender: alice.UUID, Coin: c.UUID, Receiver: claire.UUID, Unlock: unlock(claire.UUID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := l.Coin(c.UUID); c.Owner != claire.UUID || c.Script != nil {
+		t.Errorf("unlocked coin is %+v", c)
+	}
+	if _, err := l.Pay(Payment{Spender: claire.UUID, Coin: c.UUID, Receiver: alice.UUID, Unlock: Tx.Unlock}); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("unlock of a plain coin returned %v", err)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+	Tx.Unlock[1] ^= 1
+	if err := l.VerifyChain(); err == nil {
+		t.Error("tampered unlocking script verified")
+	}
+}
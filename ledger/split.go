@@ -0,0 +1,262 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Split and Merge
+	___________________________________________________________________________
+
+	A coin keeps the value it was minted with, so an owner who has to pay
+	an amount splits a coin into new coins whose values add up to its
+	value, or merges several of its coins into one worth their sum. A
+	single Tx spends its Inputs and creates its Outputs, the owner signs
+	crypto.SplitMergeDigest() over both. The spent coins are gone, the new
+	ones start at the Tx and their IDs derive from its first input
+*/
+
+const MaxSplitMerge = 16
+
+/*
+	Input is a coin spent by a split or merge, PrevHash is the hash of its
+	last Tx
+*/
+type Input struct {
+	Coin     uuid.UUID `json:"coin"`
+	PrevHash HexBytes  `json:"prevHash"`
+}
+
+/*
+	Output is a coin created by a split or merge
+*/
+type Output struct {
+	Coin  uuid.UUID `json:"coin"`
+	Value int       `json:"value"`
+}
+
+/*
+	outputID() returns the ID of the i-th coin created by a Tx spending
+	first, a coin spends a given Tx only once
+*/
+func outputID(first Input, i int) uuid.UUID {
+	return uuid.NewV5(first.Coin, hex.EncodeToString(first.PrevHash)+":"+strconv.Itoa(i))
+}
+
+/*
+	splitMergeDigest() returns the digest the owner signs for a split or
+	merge Tx
+*/
+func (Tx *Transaction) splitMergeDigest() []byte {
+	coins := make([]uuid.UUID, len(Tx.Inputs))
+	prevHashes := make([][]byte, len(Tx.Inputs))
+	for i, in := range Tx.Inputs {
+		coins[i], prevHashes[i] = in.Coin, in.PrevHash
+	}
+	values := make([]int, len(Tx.Outputs))
+	for i, out := range Tx.Outputs {
+		values[i] = out.Value
+	}
+	return crypto.SplitMergeDigest(coins, prevHashes, values)
+}
+
+/*
+	splitMergeMessage() returns the Tx message for owner turning n coins
+	into m coins worth amount
+*/
+func splitMergeMessage(owner string, n, m, amount int) []byte {
+	if n == 1 {
+		return []byte(owner + " split " + strconv.Itoa(amount) + " goofy coins into " + strconv.Itoa(m) + " coins")
+	}
+	return []byte(owner + " merged " + strconv.Itoa(n) + " coins into " + strconv.Itoa(amount) + " goofy coins")
+}
+
+/*
+	Split() turns coinID of owner into new coins of values, which must add
+	up to its value. r and s sign crypto.SplitMergeDigest(), if they are
+	nil the ledger signs with the owner's key when it holds it
+*/
+func (l *Ledger) Split(owner uuid.UUID, coinID uuid.UUID, values []int, r, s *big.Int) (*Transaction, []Coin, error) {
+	if len(values) < 2 || len(values) > MaxSplitMerge {
+		return nil, nil, errkind.Failed("a coin is split into 2 to " + strconv.Itoa(MaxSplitMerge) + " coins")
+	}
+	for _, v := range values {
+		if v <= 0 || v > MaxAmount {
+			return nil, nil, errkind.Failed("values must be positive and at most " + strconv.Itoa(MaxAmount))
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.splitMerge(owner, []uuid.UUID{coinID}, values, r, s)
+}
+
+/*
+	Merge() turns coinIDs of owner into one coin worth their sum, r and s
+	sign crypto.SplitMergeDigest() like for Split()
+*/
+func (l *Ledger) Merge(owner uuid.UUID, coinIDs []uuid.UUID, r, s *big.Int) (*Transaction, Coin, error) {
+	if len(coinIDs) < 2 || len(coinIDs) > MaxSplitMerge {
+		return nil, Coin{}, errkind.Failed("2 to " + strconv.Itoa(MaxSplitMerge) + " coins are merged")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	Tx, coins, err := l.splitMerge(owner, coinIDs, nil, r, s)
+	if err != nil {
+		return nil, Coin{}, err
+	}
+	return Tx, coins[0], nil
+}
+
+/*
+	splitMerge() spends coinIDs of owner for new coins of values, one coin
+	of their sum for nil values. The caller holds the lock
+*/
+func (l *Ledger) splitMerge(owner uuid.UUID, coinIDs []uuid.UUID, values []int, r, s *big.Int) (*Transaction, []Coin, error) {
+	u, err := l.users.User(owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	Tx := &Transaction{TimeStamp: time.Now().Unix(), Sender: owner, Receiver: owner}
+	spent := []*Coin{}
+	for _, id := range coinIDs {
+		c, err := l.coin(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, in := range Tx.Inputs {
+			if in.Coin == c.UUID {
+				return nil, nil, errkind.Failed("coin " + c.UUID.String() + " is listed twice")
+			}
+		}
+		if c.HashLock != nil || c.Script != nil {
+			return nil, nil, errkind.Failed("a hash locked or scripted coin cannot be split or merged")
+		}
+		if err := c.spendableBy(Tx, len(l.chain)); err != nil {
+			return nil, nil, err
+		}
+		Tx.Inputs = append(Tx.Inputs, Input{Coin: c.UUID, PrevHash: c.TxHash})
+		Tx.Amount += c.Value
+		spent = append(spent, c)
+	}
+	if values == nil {
+		if Tx.Amount > MaxAmount {
+			return nil, nil, errkind.Failed("merged coin would be worth more than " + strconv.Itoa(MaxAmount))
+		}
+		values = []int{Tx.Amount}
+	}
+	total := 0
+	for i, v := range values {
+		total += v
+		Tx.Outputs = append(Tx.Outputs, Output{Coin: outputID(Tx.Inputs[0], i), Value: v})
+	}
+	if total != Tx.Amount {
+		return nil, nil, errkind.Failed("values add up to " + strconv.Itoa(total) + ", the coin is worth " + strconv.Itoa(Tx.Amount))
+	}
+	Tx.Message = splitMergeMessage(u.Name, len(Tx.Inputs), len(Tx.Outputs), Tx.Amount)
+
+	digest := Tx.spendDigest()
+	if r == nil || s == nil {
+		if u.PrivateKey == nil {
+			return nil, nil, ErrSignatureRequired
+		}
+		r, s, err = crypto.Sign(u.PrivateKey, digest)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if !l.verify(u.PublicKey, digest, r, s) {
+		return nil, nil, ErrInvalidSignature
+	}
+	Tx.R, Tx.S = r, s
+
+	l.appendTx(Tx)
+	coins := l.regroup(Tx)
+	if len(spent) == 1 {
+		l.bus.publish(CoinsSplit{Tx, coins})
+	} else {
+		l.bus.publish(CoinsMerged{Tx, coins[0]})
+	}
+	return Tx, coins, nil
+}
+
+/*
+	regroup() drops the coins the split or merge Tx spent and returns the
+	coins it created
+*/
+func (l *Ledger) regroup(Tx *Transaction) []Coin {
+	kept := l.coins[:0]
+	for _, c := range l.coins {
+		if !Tx.spends(c.UUID) {
+			kept = append(kept, c)
+		}
+	}
+	l.coins = kept
+	coins := []Coin{}
+	for _, out := range Tx.Outputs {
+		c := &Coin{UUID: out.Coin, Value: out.Value, Owner: Tx.Receiver, TxHash: Tx.CurrHash}
+		l.coins = append(l.coins, c)
+		coins = append(coins, *c)
+	}
+	return coins
+}
+
+/*
+	spends() reports whether the split or merge Tx spends coinID
+*/
+func (Tx *Transaction) spends(coinID uuid.UUID) bool {
+	for _, in := range Tx.Inputs {
+		if in.Coin == coinID {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	verifySplitMerge() checks the split or merge Tx, the i-th of the chain
+	appended at height, against coins and moves them on. It conserves the
+	value of its inputs
+*/
+func verifySplitMerge(i int, height int, Tx *Transaction, coins map[uuid.UUID]*Coin) error {
+	if Tx.Sender != Tx.Receiver || len(Tx.Outputs) == 0 || (len(Tx.Inputs) > 1 && len(Tx.Outputs) > 1) {
+		return errors.New("malformed split or merge at transaction " + strconv.Itoa(i))
+	}
+	in := 0
+	for _, input := range Tx.Inputs {
+		c, ok := coins[input.Coin]
+		if !ok || !bytes.Equal(input.PrevHash, c.TxHash) {
+			return errors.New("broken coin link at transaction " + strconv.Itoa(i))
+		}
+		if c.HashLock != nil || c.Script != nil {
+			return errors.New("transaction " + strconv.Itoa(i) + " splits or merges a locked coin")
+		}
+		if err := c.spendableBy(Tx, height); err != nil {
+			return errors.New("transaction " + strconv.Itoa(i) + " spends a coin it may not: " + err.Error())
+		}
+		in += c.Value
+		delete(coins, input.Coin)
+	}
+	out := 0
+	for j, output := range Tx.Outputs {
+		if output.Value <= 0 || output.Coin != outputID(Tx.Inputs[0], j) {
+			return errors.New("invalid output at transaction " + strconv.Itoa(i))
+		}
+		out += output.Value
+		coins[output.Coin] = &Coin{Value: output.Value, Owner: Tx.Receiver, TxHash: Tx.CurrHash}
+	}
+	if in != out || in != Tx.Amount {
+		return errors.New("transaction " + strconv.Itoa(i) + " does not conserve value")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package ledger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+func TestSplitMerge(t *testing.T) {
+	l, users := newLedger(t, "alice")
+	goofy, alice := users[0], users[1]
+	bobPriv, bobPub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := l.RegisterUser("bob", bobPub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := l.Mint(goofy.UUID, 10)
+	if _, err := l.Transfer(goofy.UUID, c.UUID, bob.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+
+	for _, values := range [][]int{{10}, {3, 8}, {3, 0, 7}, {-1, 11}} {
+		if _, _, err := l.Split(bob.UUID, c.UUID, values, nil, nil); !errors.Is(err, errkind.Invalid) {
+			t.Errorf("split into %v returned %v", values, err)
+		}
+	}
+	if _, _, err := l.Split(alice.UUID, c.UUID, []int{3, 7}, nil, nil); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("split by another user returned %v", err)
+	}
+	if _, _, err := l.Split(bob.UUID, c.UUID, []int{3, 7}, nil, nil); err != ErrSignatureRequired {
+		t.Errorf("split without bob's key returned %v", err)
+	}
+	r, s, err := crypto.Sign(bobPriv, crypto.SplitMergeDigest([]uuid.UUID{c.UUID}, [][]byte{c.TxHash}, []int{3, 7}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l.Split(bob.UUID, c.UUID, []int{7, 3}, r, s); err != ErrInvalidSignature {
+		t.Errorf("split signed for other values returned %v", err)
+	}
+	Tx, parts, err := l.Split(bob.UUID, c.UUID, []int{3, 7}, r, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Value != 3 || parts[1].Value != 7 || parts[0].Owner != bob.UUID || len(Tx.Inputs) != 1 {
+		t.Errorf("split returned %+v", parts)
+	}
+	if _, err := l.Coin(c.UUID); err != ErrCoinNotFound {
+		t.Errorf("split coin still exists: %v", err)
+	}
+	if b, _ := l.Balance(bob.UUID); b.Balance != 10 || len(b.Coins) != 2 || l.TotalSupply() != 10 {
+		t.Errorf("bob has %+v after the split, supply %d", b, l.TotalSupply())
+	}
+
+	// bob pays 3 to alice, who merges it with a new coin
+	if _, err := l.Transfer(bob.UUID, parts[0].UUID, alice.UUID, nil, nil, nil); err == nil {
+		t.Fatal("transfer without bob's key succeeded")
+	}
+	r, s, _ = crypto.Sign(bobPriv, crypto.SpendDigest(parts[0].UUID, alice.UUID, parts[0].TxHash))
+	if _, err := l.Transfer(bob.UUID, parts[0].UUID, alice.UUID, nil, r, s); err != nil {
+		t.Fatal(err)
+	}
+	more, _ := l.Mint(goofy.UUID, 5)
+	l.Transfer(goofy.UUID, more.UUID, alice.UUID, nil, nil, nil)
+	if _, _, err := l.Merge(alice.UUID, []uuid.UUID{parts[0].UUID, parts[0].UUID}, nil, nil); !errors.Is(err, errkind.Invalid) {
+		t.Errorf("merging a coin with itself returned %v", err)
+	}
+	if _, _, err := l.Merge(alice.UUID, []uuid.UUID{parts[0].UUID, parts[1].UUID}, nil, nil); !errors.Is(err, errkind.Forbidden) {
+		t.Errorf("merging bob's coin returned %v", err)
+	}
+	_, merged, err := l.Merge(alice.UUID, []uuid.UUID{parts[0].UUID, more.UUID}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Value != 8 || merged.Owner != alice.UUID {
+		t.Errorf("merge returned %+v", merged)
+	}
+	if b, _ := l.Balance(alice.UUID); b.Balance != 8 || len(b.Coins) != 1 {
+		t.Errorf("alice has %+v after the merge", b)
+	}
+	if _, err := l.Transfer(alice.UUID, more.UUID, goofy.UUID, nil, nil, nil); err != ErrCoinNotFound {
+		t.Errorf("spending a merged coin returned %v", err)
+	}
+	if _, err := l.Transfer(alice.UUID, merged.UUID, goofy.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Error(err)
+	}
+	Tx.Outputs[1].Value = 8
+	Tx.CurrHash = Tx.hash()
+	if err := l.VerifyChain(); err == nil || !strings.Contains(err.Error(), "conserve") {
+		t.Errorf("split creating value returned %v", err)
+	}
+}
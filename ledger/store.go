@@ -0,0 +1,361 @@
+package ledger
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/wallet"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	Storage
+	___________________________________________________________________________
+
+	The ledger is journaled with one JSON record per line for every new
+	user, multisig, escrow or invoice change, transaction and sealed block,
+	and replayed on start. Private keys held by the ledger are journaled
+	too, except goofy's which stays in its key file
+*/
+
+type userRecord struct {
+	UUID       uuid.UUID `json:"uuid"`
+	Name       string    `json:"name"`
+	PublicKey  string    `json:"publicKey"`
+	PrivateKey HexBytes  `json:"privateKey,omitempty"`
+	Password   HexBytes  `json:"password,omitempty"`
+}
+
+type multisigRecord struct {
+	UUID   uuid.UUID   `json:"uuid"`
+	M      int         `json:"m"`
+	Owners []uuid.UUID `json:"owners"`
+}
+
+type txRecord struct {
+	TimeStamp int64       `json:"timeStamp"`
+	Message   HexBytes    `json:"message"`
+	PrevHash  HexBytes    `json:"prevHash"`
+	CurrHash  HexBytes    `json:"currHash"`
+	Coin      uuid.UUID   `json:"coin"`
+	Sender    uuid.UUID   `json:"sender"`
+	Receiver  uuid.UUID   `json:"receiver"`
+	Amount    int         `json:"amount"`
+	CoinPrev  HexBytes    `json:"coinPrev"`
+	R         string      `json:"r"`
+	S         string      `json:"s"`
+	Sigs      []Signature `json:"sigs,omitempty"`
+	Lock      *Lock       `json:"lock,omitempty"`
+	HashLock  *HashLock   `json:"hashLock,omitempty"`
+	Preimage  HexBytes    `json:"preimage,omitempty"`
+	Script    HexBytes    `json:"script,omitempty"`
+	Unlock    HexBytes    `json:"unlock,omitempty"`
+	Memo      Memo        `json:"memo,omitempty"`
+	Inputs    []Input     `json:"inputs,omitempty"`
+	Outputs   []Output    `json:"outputs,omitempty"`
+}
+
+type blockRecord struct {
+	Height    int      `json:"height"`
+	TimeStamp int64    `json:"timeStamp"`
+	PrevHash  HexBytes `json:"prevHash"`
+	Hash      HexBytes `json:"hash"`
+	TxCount   int      `json:"txCount"`
+}
+
+/*
+	record is one line of the journal, exactly one field is set
+*/
+type record struct {
+	User     *userRecord     `json:"user,omitempty"`
+	Multisig *multisigRecord `json:"multisig,omitempty"`
+	Escrow   *Escrow         `json:"escrow,omitempty"`
+	Invoice  *Invoice        `json:"invoice,omitempty"`
+	Tx       *txRecord       `json:"tx,omitempty"`
+	Block    *blockRecord    `json:"block,omitempty"`
+}
+
+/*
+	Store journals the events of a ledger to a file
+*/
+type Store struct {
+	ledger      *Ledger
+	file        *os.File
+	unsubscribe func()
+
+	mu  sync.Mutex
+	err error
+}
+
+/*
+	OpenStore() replays the journal at path into the empty ledger and keeps
+	journaling every event of l to it until the store is closed. A last line
+	cut off by a crash is dropped
+*/
+func (l *Ledger) OpenStore(path string) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	end, err := l.replay(file)
+	if err == nil {
+		err = file.Truncate(end)
+	}
+	if err == nil {
+		_, err = file.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	st := &Store{ledger: l, file: file}
+	st.unsubscribe = l.bus.subscribe(st.record)
+	return st, nil
+}
+
+/*
+	replay() applies every complete record of r and returns the offset
+	after the last one
+*/
+func (l *Ledger) replay(r io.Reader) (int64, error) {
+	reader := bufio.NewReader(r)
+	var end int64
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return end, nil
+		}
+		if err != nil {
+			return end, err
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return end, fmt.Errorf("line %d: %v", n, err)
+		}
+		if err := l.apply(rec); err != nil {
+			return end, fmt.Errorf("line %d: %v", n, err)
+		}
+		end += int64(len(line))
+	}
+}
+
+func (l *Ledger) apply(rec record) error {
+	switch {
+	case rec.User != nil:
+		return l.applyUser(rec.User)
+	case rec.Multisig != nil:
+		l.multisigs = append(l.multisigs, Multisig{UUID: rec.Multisig.UUID, M: rec.Multisig.M, Owners: rec.Multisig.Owners})
+		return nil
+	case rec.Escrow != nil:
+		if e, err := l.escrow(rec.Escrow.ID); err == nil {
+			*e = *rec.Escrow
+		} else {
+			l.escrows = append(l.escrows, rec.Escrow)
+		}
+		return nil
+	case rec.Invoice != nil:
+		if inv, err := l.invoice(rec.Invoice.ID); err == nil {
+			*inv = *rec.Invoice
+		} else {
+			l.invoices = append(l.invoices, rec.Invoice)
+		}
+		return nil
+	case rec.Tx != nil:
+		return l.applyTx(rec.Tx)
+	case rec.Block != nil:
+		return l.applyBlock(rec.Block)
+	}
+	return errors.New("empty record")
+}
+
+func (l *Ledger) applyUser(rec *userRecord) error {
+	pub, err := crypto.ParsePublicKey([]byte(rec.PublicKey))
+	if err != nil {
+		return err
+	}
+	var priv *ecdsa.PrivateKey
+	if rec.PrivateKey != nil {
+		priv, err = x509.ParseECPrivateKey(rec.PrivateKey)
+		if err != nil {
+			return err
+		}
+	}
+	l.users.Restore(wallet.User{UUID: rec.UUID, Name: rec.Name, PrivateKey: priv, PublicKey: pub, Password: nilIfEmpty(rec.Password)})
+	return nil
+}
+
+/*
+	nilIfEmpty() restores the nil hashes a HexBytes decodes as empty
+*/
+func nilIfEmpty(b HexBytes) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func (l *Ledger) applyTx(rec *txRecord) error {
+	Tx := &Transaction{TimeStamp: rec.TimeStamp, Message: rec.Message, PrevHash: nilIfEmpty(rec.PrevHash), CurrHash: rec.CurrHash, CoinID: rec.Coin, Sender: rec.Sender, Receiver: rec.Receiver, Amount: rec.Amount, CoinPrev: nilIfEmpty(rec.CoinPrev), Sigs: rec.Sigs, Lock: rec.Lock, HashLock: rec.HashLock, Preimage: nilIfEmpty(rec.Preimage), Script: nilIfEmpty(rec.Script), Unlock: nilIfEmpty(rec.Unlock), Memo: rec.Memo, Inputs: rec.Inputs, Outputs: rec.Outputs}
+	// a scripted spend carries no signature of its sender
+	if rec.Sigs == nil && (rec.R != "" || rec.S != "") {
+		var err error
+		Tx.R, Tx.S, err = crypto.ParseSignature(rec.R, rec.S)
+		if err != nil {
+			return err
+		}
+	}
+	if len(Tx.Inputs) > 0 {
+		l.regroup(Tx)
+	} else if Tx.CoinPrev == nil {
+		l.coins = append(l.coins, &Coin{UUID: Tx.CoinID, Value: Tx.Amount, Owner: Tx.Receiver, TxHash: Tx.CurrHash})
+	} else {
+		c, err := l.coin(Tx.CoinID)
+		if err != nil {
+			return err
+		}
+		c.Owner = Tx.Receiver
+		c.TxHash = Tx.CurrHash
+		c.Lock = Tx.Lock
+		c.HashLock = Tx.HashLock
+		c.Script = Tx.Script
+	}
+	l.open.Tx = append(l.open.Tx, Tx)
+	return nil
+}
+
+func (l *Ledger) applyBlock(rec *blockRecord) error {
+	if rec.TxCount > len(l.open.Tx) {
+		return errors.New("block seals more transactions than are open")
+	}
+	b := &Block{Tx: l.open.Tx[:rec.TxCount:rec.TxCount], Height: rec.Height, TimeStamp: rec.TimeStamp, PrevHash: nilIfEmpty(rec.PrevHash), Hash: rec.Hash}
+	l.chain = append(l.chain, b)
+	l.open = Block{Tx: l.open.Tx[rec.TxCount:]}
+	return nil
+}
+
+/*
+	newRecord() returns the journal record of e, it runs under the ledger's
+	lock
+*/
+func (st *Store) newRecord(e Event) (record, error) {
+	switch e := e.(type) {
+	case UserCreated:
+		u := e.User
+		pub, err := crypto.EncodePublicKey(u.PublicKey)
+		if err != nil {
+			return record{}, err
+		}
+		rec := &userRecord{UUID: u.UUID, Name: u.Name, PublicKey: pub, Password: u.Password}
+		goofy, err := st.ledger.users.Goofy()
+		if err != nil {
+			return record{}, err
+		}
+		if u.PrivateKey != nil && u.UUID != goofy.UUID {
+			rec.PrivateKey, err = x509.MarshalECPrivateKey(u.PrivateKey)
+			if err != nil {
+				return record{}, err
+			}
+		}
+		return record{User: rec}, nil
+	case MultisigCreated:
+		ms := e.Multisig
+		return record{Multisig: &multisigRecord{UUID: ms.UUID, M: ms.M, Owners: ms.Owners}}, nil
+	case EscrowChanged:
+		escrow := e.Escrow
+		return record{Escrow: &escrow}, nil
+	case InvoiceChanged:
+		inv := e.Invoice
+		return record{Invoice: &inv}, nil
+	case CoinMinted:
+		return record{Tx: newTxRecord(e.Tx)}, nil
+	case CoinTransferred:
+		return record{Tx: newTxRecord(e.Tx)}, nil
+	case CoinsSplit:
+		return record{Tx: newTxRecord(e.Tx)}, nil
+	case CoinsMerged:
+		return record{Tx: newTxRecord(e.Tx)}, nil
+	case BlockSealed:
+		b := e.Block
+		return record{Block: &blockRecord{Height: b.Height, TimeStamp: b.TimeStamp, PrevHash: b.PrevHash, Hash: b.Hash, TxCount: len(b.Tx)}}, nil
+	}
+	return record{}, nil
+}
+
+func newTxRecord(Tx *Transaction) *txRecord {
+	return &txRecord{TimeStamp: Tx.TimeStamp, Message: Tx.Message, PrevHash: Tx.PrevHash, CurrHash: Tx.CurrHash, Coin: Tx.CoinID, Sender: Tx.Sender, Receiver: Tx.Receiver, Amount: Tx.Amount, CoinPrev: Tx.CoinPrev, R: bigHex(Tx.R), S: bigHex(Tx.S), Sigs: Tx.Sigs, Lock: Tx.Lock, HashLock: Tx.HashLock, Preimage: Tx.Preimage, Script: Tx.Script, Unlock: Tx.Unlock, Memo: Tx.Memo, Inputs: Tx.Inputs, Outputs: Tx.Outputs}
+}
+
+/*
+	record() appends e to the journal as a synchronous subscriber, the file
+	is synced when a block is sealed. After a failed write the journal is no
+	longer complete, so every later write is refused and Err() reports why
+*/
+func (st *Store) record(e Event) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.err != nil {
+		return
+	}
+	rec, err := st.newRecord(e)
+	if err != nil {
+		st.fail(err)
+		return
+	}
+	if rec == (record{}) {
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		st.fail(err)
+		return
+	}
+	if _, err := st.file.Write(append(line, '\n')); err != nil {
+		st.fail(err)
+		return
+	}
+	if _, ok := e.(BlockSealed); ok {
+		if err := st.file.Sync(); err != nil {
+			st.fail(err)
+		}
+	}
+}
+
+func (st *Store) fail(err error) {
+	st.err = err
+	slog.Error("cannot write ledger journal", "error", err)
+}
+
+/*
+	Close() stops journaling and syncs the journal to disk
+*/
+func (st *Store) Close() error {
+	st.unsubscribe()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := st.file.Sync(); err != nil {
+		st.file.Close()
+		return err
+	}
+	return st.file.Close()
+}
+
+/*
+	Err() returns the error that stopped the journal, if any
+*/
+func (st *Store) Err() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err
+}
@@ -0,0 +1,419 @@
+package ledger
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/script"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	openLedger() replays the journal in dir into a new ledger and attaches
+	goofy with key, as the node does on start
+*/
+func openLedger(dir string, key *ecdsa.PrivateKey) (*Ledger, *Store, error) {
+	l := New()
+	st, err := l.OpenStore(filepath.Join(dir, "ledger.jsonl"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := l.AttachGoofy(key, ""); err != nil {
+		st.Close()
+		return nil, nil, err
+	}
+	if err := l.VerifyChain(); err != nil {
+		st.Close()
+		return nil, nil, err
+	}
+	return l, st, nil
+}
+
+func closeStore(t *testing.T, st *Store) {
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreReplay(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, st, err := openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goofy, _ := l.Goofy()
+	alice, err := l.RegisterUser("alice", nil, "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPriv, bobPub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := l.RegisterUser("bob", bobPub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Transfer(goofy.UUID, c.UUID, alice.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SealBlock()
+	// alice's key is held by the ledger, it signs for her after the restart
+	Tx, err := l.Transfer(alice.UUID, c.UUID, bob.UUID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tip := Tx.CurrHash
+	closeStore(t, st)
+
+	l, st, err = openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, st)
+	if len(l.Users()) != 3 || l.Height() != 1 || l.Len() != 3 {
+		t.Fatalf("ledger not restored: %d users, %d blocks, %d Tx", len(l.Users()), l.Height(), l.Len())
+	}
+	restored, err := l.Coin(c.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Owner != bob.UUID || !bytes.Equal(restored.TxHash, tip) {
+		t.Error("coin owner not restored")
+	}
+	u, err := l.UserByName("alice")
+	if err != nil || !crypto.CheckPassword(u.Password, "wonderland") {
+		t.Error("alice's password not restored")
+	}
+	r, s, err := crypto.Sign(bobPriv, crypto.SpendDigest(c.UUID, alice.UUID, tip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Transfer(bob.UUID, c.UUID, alice.UUID, tip, r, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goofy, _ = l.Goofy()
+	if len(goofy.Password) != 0 || goofy.PrivateKey != key {
+		t.Error("goofy not attached to the replayed ledger")
+	}
+}
+
+func TestStoreMultisig(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, st, err := openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goofy, _ := l.Goofy()
+	alice, _ := l.RegisterUser("alice", nil, "")
+	bob, _ := l.RegisterUser("bob", nil, "")
+	ms, err := l.CreateMultisig(2, []uuid.UUID{alice.UUID, bob.UUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := l.Mint(goofy.UUID, 10)
+	if _, err := l.Transfer(goofy.UUID, c.UUID, ms.UUID, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = l.Coin(c.UUID)
+	if _, err := l.SpendMultisig(c.UUID, goofy.UUID, nil, signSpend(t, c, goofy.UUID, alice, bob)); err != nil {
+		t.Fatal(err)
+	}
+	claire, _ := l.RegisterUser("claire", nil, "")
+	held, _ := l.Mint(goofy.UUID, 5)
+	e, err := l.OpenEscrow(goofy.UUID, alice.UUID, claire.UUID, held.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Dispute(alice.UUID, e.ID); err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, st)
+
+	// openLedger() verifies the multisig signatures of the replayed chain
+	l, st, err = openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, st)
+	restored, err := l.Multisig(ms.UUID)
+	if err != nil || restored.M != 2 || len(restored.Owners) != 2 {
+		t.Errorf("multisig not restored: %+v, %v", restored, err)
+	}
+	if c, _ := l.Coin(c.UUID); c.Owner != goofy.UUID {
+		t.Error("coin owner not restored")
+	}
+	if e, err := l.Escrow(e.ID); err != nil || e.State != EscrowDisputed || e.Buyer != goofy.UUID {
+		t.Errorf("escrow not restored: %+v, %v", e, err)
+	}
+	if got := l.EscrowsOf(claire.UUID); len(got) != 1 {
+		t.Errorf("claire is party of %d escrows", len(got))
+	}
+}
+
+func TestStoreInvoice(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, st, err := openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goofy, _ := l.Goofy()
+	alice, _ := l.RegisterUser("alice", nil, "")
+	c, _ := l.Mint(goofy.UUID, 10)
+	open, err := l.CreateInvoice(Invoice{Recipient: alice.UUID, Amount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, err := l.CreateInvoice(Invoice{Recipient: alice.UUID, Amount: 10, Memo: "rent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid, err = l.PayInvoice(goofy.UUID, paid.ID, []Payment{{Coin: c.UUID}}); err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, st)
+
+	l, st, err = openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, st)
+	if inv, err := l.Invoice(open.ID); err != nil || inv.State != InvoiceOpen || inv.URI() != open.URI() {
+		t.Errorf("open invoice not restored: %+v, %v", inv, err)
+	}
+	inv, err := l.Invoice(paid.ID)
+	if err != nil || inv.State != InvoicePaid || len(inv.Payments) != 1 || *inv.Payer != goofy.UUID {
+		t.Fatalf("paid invoice not restored: %+v, %v", inv, err)
+	}
+	if _, err := l.Transaction(inv.Payments[0]); err != nil {
+		t.Errorf("payment not found: %v", err)
+	}
+}
+
+func TestStoreLock(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, st, err := openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goofy, _ := l.Goofy()
+	alice, _ := l.RegisterUser("alice", nil, "")
+	c, _ := l.Mint(goofy.UUID, 10)
+	if _, err := l.TransferLocked(goofy.UUID, c.UUID, alice.UUID, &Lock{Height: 5}, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("secret"))
+	c, _ = l.Mint(goofy.UUID, 3)
+	if _, err := l.Pay(Payment{Spender: goofy.UUID, Coin: c.UUID, Receiver: alice.UUID, HashLock: &HashLock{Hash: hash[:], Timeout: Lock{Height: 5}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Pay(Payment{Spender: alice.UUID, Coin: c.UUID, Receiver: goofy.UUID, Preimage: []byte("secret")}); err != nil {
+		t.Fatal(err)
+	}
+	scripted, _ := l.Mint(goofy.UUID, 2)
+	if _, err := l.Pay(Payment{Spender: goofy.UUID, Coin: scripted.UUID, Receiver: alice.UUID, Script: []byte{byte(script.OpTrue)}, Memo: Memo{"order": "42"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, parts, err := l.Split(goofy.UUID, c.UUID, []int{1, 2}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l.Split(goofy.UUID, parts[1].UUID, []int{1, 1}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l.Merge(goofy.UUID, []uuid.UUID{parts[0].UUID, l.CoinsOf(goofy.UUID)[1].UUID}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, st)
+
+	l, st, err = openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, st)
+	if b, _ := l.Balance(alice.UUID); b.Locked != 12 {
+		t.Errorf("lock not restored, alice has %+v", b)
+	}
+	if c, _ := l.Coin(scripted.UUID); c.Script == nil {
+		t.Error("locking script not restored")
+	}
+	if txs := l.TransactionsWithMemo(alice.UUID, "order=42"); len(txs) != 1 {
+		t.Errorf("memo not restored, %d transactions match", len(txs))
+	}
+	if b, _ := l.Balance(goofy.UUID); b.Balance != 3 || len(b.Coins) != 2 {
+		t.Errorf("split and merge not restored, goofy has %+v", b)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Errorf("restored locks do not verify: %v", err)
+	}
+}
+
+func TestStoreScriptedSpend(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, st, err := openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goofy, _ := l.Goofy()
+	alice, _ := l.RegisterUser("alice", nil, "")
+	c, _ := l.Mint(goofy.UUID, 10)
+	if _, err := l.Pay(Payment{Spender: goofy.UUID, Coin: c.UUID, Receiver: alice.UUID, Script: []byte{byte(script.OpTrue)}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Pay(Payment{Spender: alice.UUID, Coin: c.UUID, Receiver: goofy.UUID}); err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, st)
+
+	l, st, err = openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, st)
+	if c, _ := l.Coin(c.UUID); c.Owner != goofy.UUID || c.Script != nil {
+		t.Errorf("scripted spend not restored, coin is %+v", c)
+	}
+	if err := l.VerifyChain(); err != nil {
+		t.Errorf("restored scripted spend does not verify: %v", err)
+	}
+}
+
+func TestStoreForeignKey(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, st, err := openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, st)
+
+	// another key cannot take over goofy
+	other, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openLedger(dir, other); err == nil {
+		t.Error("ledger started with another goofy key")
+	}
+}
+
+func TestStoreTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, st, err := openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goofy, _ := l.Goofy()
+	_, err = l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, st)
+
+	// a crash in the middle of a write leaves a partial last line
+	file := filepath.Join(dir, "ledger.jsonl")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"tx": {"timeStamp": 1`))
+	f.Close()
+
+	l, st, err = openLedger(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Coins()) != 1 {
+		t.Errorf("got %d coins, want 1", len(l.Coins()))
+	}
+	_, err = l.Mint(goofy.UUID, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, st)
+	l, st, err = openLedger(dir, key)
+	if err != nil {
+		t.Fatalf("journal unreadable after torn write: %v", err)
+	}
+	if len(l.Coins()) != 2 {
+		t.Errorf("got %d coins, want 2", len(l.Coins()))
+	}
+	closeStore(t, st)
+
+	// corruption before the last line is not silently dropped
+	err = os.WriteFile(file, append([]byte("{\n"), data...), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openLedger(dir, key); err == nil {
+		t.Error("corrupted journal loaded")
+	}
+}
+
+func TestStoreFailure(t *testing.T) {
+	key, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, st, err := openLedger(t.TempDir(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Err() != nil {
+		t.Fatal(st.Err())
+	}
+
+	// a failed write stops the journal
+	st.file.Close()
+	goofy, _ := l.Goofy()
+	_, err = l.Mint(goofy.UUID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Err() == nil {
+		t.Error("failed journal write not reported")
+	}
+	st.unsubscribe()
+}
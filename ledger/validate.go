@@ -0,0 +1,58 @@
+package ledger
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/de7ign/goofy-coin/errkind"
+)
+
+/*
+	Validation rules of amounts, the dashboard checks the same rules in
+	assets/js/script.js before sending a request
+*/
+
+const (
+	MaxAmount = math.MaxInt32
+	MaxSupply = math.MaxInt64 / 2
+)
+
+/*
+	ParseAmount() parses a positive, non-fractional amount of goofy coins
+*/
+func ParseAmount(s string) (int, error) {
+	if s == "" {
+		return 0, errkind.Failed("enter an amount")
+	}
+	if strings.ContainsAny(s, " \t\n") {
+		return 0, errkind.Failed("no space allowed")
+	}
+	if strings.ContainsAny(s, ".eE") {
+		return 0, errkind.Failed("no fractions allowed")
+	}
+	amount, err := strconv.ParseInt(s, 10, 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return 0, errkind.Failed("amount too large")
+	}
+	if err != nil {
+		return 0, errkind.Failed("enter a numeric value")
+	}
+	if err := ValidateAmount(amount); err != nil {
+		return 0, err
+	}
+	return int(amount), nil
+}
+
+/*
+	ValidateAmount() rejects zero, negative and overflowing amounts
+*/
+func ValidateAmount(amount int64) error {
+	if amount <= 0 {
+		return errkind.Failed("amount must be positive")
+	}
+	if amount > MaxAmount {
+		return errkind.Failed("amount larger than " + strconv.Itoa(MaxAmount))
+	}
+	return nil
+}
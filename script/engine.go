@@ -0,0 +1,363 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+
+	"github.com/de7ign/goofy-coin/crypto"
+)
+
+var ErrFalse = errors.New("script left false on the stack")
+
+/*
+	Context is what a script may check of the spending transaction: Digest
+	is what its signatures sign, Height the chain height it is appended at
+	and Time its time stamp
+*/
+type Context struct {
+	Digest []byte
+	Height int
+	Time   int64
+}
+
+/*
+	engine runs scripts on one stack, counting steps
+*/
+type engine struct {
+	ctx   Context
+	stack [][]byte
+	steps int
+}
+
+/*
+	Run() executes unlock, which may only push data, and then lock with ctx,
+	it returns nil if lock accepts the spend
+*/
+func Run(unlock []byte, lock []byte, ctx Context) error {
+	if !PushOnly(unlock) {
+		if err := Validate(unlock); err != nil {
+			return errors.New("unlocking script: " + err.Error())
+		}
+		return errors.New("unlocking script may only push data")
+	}
+	in, _ := parse(unlock)
+	out, err := parse(lock)
+	if err != nil {
+		return errors.New("locking script: " + err.Error())
+	}
+	e := &engine{ctx: ctx}
+	if err := e.exec(in); err != nil {
+		return err
+	}
+	if err := e.exec(out); err != nil {
+		return err
+	}
+	if len(e.stack) == 0 || !truth(e.stack[len(e.stack)-1]) {
+		return ErrFalse
+	}
+	return nil
+}
+
+/*
+	exec() runs prog, the branches of its OP_IFs are skipped unless taken
+*/
+func (e *engine) exec(prog []instruction) error {
+	var branches []bool
+	for _, in := range prog {
+		e.steps++
+		if e.steps > MaxSteps {
+			return errors.New("script takes more than " + strconv.Itoa(MaxSteps) + " steps")
+		}
+		running := true
+		for _, taken := range branches {
+			running = running && taken
+		}
+		switch in.op {
+		case OpIf:
+			taken := false
+			if running {
+				top, err := e.pop()
+				if err != nil {
+					return err
+				}
+				taken = truth(top)
+			}
+			branches = append(branches, taken)
+			continue
+		case OpElse:
+			branches[len(branches)-1] = !branches[len(branches)-1]
+			continue
+		case OpEndIf:
+			branches = branches[:len(branches)-1]
+			continue
+		}
+		if !running {
+			continue
+		}
+		if err := e.step(in); err != nil {
+			return errors.New(in.op.String() + " at step " + strconv.Itoa(e.steps) + ": " + err.Error())
+		}
+		if len(e.stack) > MaxStack {
+			return errors.New("stack grows beyond " + strconv.Itoa(MaxStack) + " items")
+		}
+	}
+	return nil
+}
+
+/*
+	step() executes a single instruction other than the branches
+*/
+func (e *engine) step(in instruction) error {
+	switch op := in.op; {
+	case op <= OpPushData2:
+		e.push(in.data)
+	case op >= OpTrue && op <= Op16:
+		e.push([]byte{byte(op-OpTrue) + 1})
+	case op == OpVerify:
+		return e.verify()
+	case op == OpReturn:
+		return errors.New("spend refused")
+	case op == OpDrop:
+		_, err := e.pop()
+		return err
+	case op == OpDup:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(top)
+		e.push(top)
+	case op == OpSwap:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(a)
+		e.push(b)
+	case op == OpEqual, op == OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.pushBool(bytes.Equal(a, b))
+		if op == OpEqualVerify {
+			return e.verify()
+		}
+	case op == OpNot:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.pushBool(!truth(top))
+	case op == OpBoolAnd, op == OpBoolOr:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if op == OpBoolAnd {
+			e.pushBool(truth(a) && truth(b))
+		} else {
+			e.pushBool(truth(a) || truth(b))
+		}
+	case op == OpSHA256:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(top)
+		e.push(hash[:])
+	case op == OpCheckSig, op == OpCheckSigVerify:
+		key, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+		ok, err := e.checkSig(key, sig)
+		if err != nil {
+			return err
+		}
+		e.pushBool(ok)
+		if op == OpCheckSigVerify {
+			return e.verify()
+		}
+	case op == OpCheckMultisig, op == OpCheckMultisigVerify:
+		ok, err := e.checkMultisig()
+		if err != nil {
+			return err
+		}
+		e.pushBool(ok)
+		if op == OpCheckMultisigVerify {
+			return e.verify()
+		}
+	case op == OpCheckHeightVerify, op == OpCheckTimeVerify:
+		if len(e.stack) == 0 {
+			return errors.New("empty stack")
+		}
+		n, err := number(e.stack[len(e.stack)-1])
+		if err != nil {
+			return err
+		}
+		if op == OpCheckHeightVerify && int64(e.ctx.Height) < n {
+			return errors.New("locked until block " + strconv.FormatInt(n, 10))
+		}
+		if op == OpCheckTimeVerify && e.ctx.Time < n {
+			return errors.New("locked until unix time " + strconv.FormatInt(n, 10))
+		}
+	}
+	return nil
+}
+
+/*
+	checkSig() reports whether sig signs the digest of the context for key
+*/
+func (e *engine) checkSig(key []byte, sig []byte) (bool, error) {
+	pub, err := crypto.DecompressPublicKey(key)
+	if err != nil {
+		return false, err
+	}
+	r, s, err := crypto.SplitSignature(sig)
+	if err != nil {
+		return false, nil
+	}
+	return crypto.Verify(pub, e.ctx.Digest, r, s), nil
+}
+
+/*
+	checkMultisig() pops n, n keys, m and m signatures and reports whether
+	the signatures are of m different keys, in the order of the keys
+*/
+func (e *engine) checkMultisig() (bool, error) {
+	n, err := e.popNumber()
+	if err != nil {
+		return false, err
+	}
+	if n < 1 || n > MaxKeys {
+		return false, errors.New("between 1 and " + strconv.Itoa(MaxKeys) + " keys required")
+	}
+	keys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if keys[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+	m, err := e.popNumber()
+	if err != nil {
+		return false, err
+	}
+	if m < 1 || m > n {
+		return false, errors.New("between 1 and " + strconv.FormatInt(n, 10) + " signatures required")
+	}
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+	e.steps += int(n)
+	if e.steps > MaxSteps {
+		return false, errors.New("script takes more than " + strconv.Itoa(MaxSteps) + " steps")
+	}
+	k := 0
+	for _, sig := range sigs {
+		for ; k < len(keys); k++ {
+			ok, err := e.checkSig(keys[k], sig)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				break
+			}
+		}
+		if k == len(keys) {
+			return false, nil
+		}
+		k++
+	}
+	return true, nil
+}
+
+func (e *engine) push(data []byte) {
+	e.stack = append(e.stack, data)
+}
+
+func (e *engine) pushBool(b bool) {
+	if b {
+		e.push([]byte{1})
+	} else {
+		e.push([]byte{})
+	}
+}
+
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errors.New("empty stack")
+	}
+	top := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	return top, nil
+}
+
+func (e *engine) popNumber() (int64, error) {
+	top, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	return number(top)
+}
+
+/*
+	verify() pops the top of the stack and fails unless it is true
+*/
+func (e *engine) verify() error {
+	top, err := e.pop()
+	if err != nil {
+		return err
+	}
+	if !truth(top) {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+/*
+	truth() reports whether data is true, anything but zero bytes is
+*/
+func truth(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	number() decodes the little endian data pushed by AppendNumber()
+*/
+func number(data []byte) (int64, error) {
+	if len(data) > 7 {
+		return 0, errors.New("number longer than 7 bytes")
+	}
+	var n int64
+	for i := len(data) - 1; i >= 0; i-- {
+		n = n<<8 | int64(data[i])
+	}
+	return n, nil
+}
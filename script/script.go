@@ -0,0 +1,296 @@
+/*
+	Package script is the small stack language which decides who may spend a
+	coin. A coin carries a locking script, the transaction spending it an
+	unlocking script which only pushes data. Run() executes the unlocking
+	script and then the locking script on the same stack, the spend is valid
+	when neither fails and the top of the stack is true. There are no loops
+	and every script is bounded by MaxSize, MaxSteps and MaxStack, so a run
+	is short and gives the same result on every node
+
+		pay to key     <key> OP_CHECKSIG                  unlocked by <sig>
+		2 of 3 keys    OP_2 <k1> <k2> <k3> OP_3 OP_CHECKMULTISIG
+		                                                  unlocked by <s1> <s3>
+		hash lock      OP_IF OP_SHA256 <hash> OP_EQUALVERIFY <receiver>
+		               OP_ELSE 100 OP_CHECKHEIGHTVERIFY OP_DROP <sender>
+		               OP_ENDIF OP_CHECKSIG               unlocked by
+		                                     <sig> <preimage> OP_1 or <sig> OP_0
+
+	Signatures are the 64 bytes of crypto.SignatureBytes() over the digest of
+	the spending transaction, keys the 33 bytes of crypto.CompressPublicKey()
+*/
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+	Limits of a script and its run
+*/
+const (
+	MaxSize  = 1024
+	MaxPush  = 520
+	MaxSteps = 200
+	MaxStack = 64
+	MaxKeys  = 16
+)
+
+/*
+	Op is an operation code, the codes between OpFalse and OpPushData1 push
+	that many following bytes
+*/
+type Op byte
+
+const (
+	OpFalse     Op = 0x00
+	OpPushData1 Op = 0x4c
+	OpPushData2 Op = 0x4d
+	OpTrue      Op = 0x51
+	Op16        Op = 0x60
+
+	OpIf     Op = 0x63
+	OpElse   Op = 0x67
+	OpEndIf  Op = 0x68
+	OpVerify Op = 0x69
+	OpReturn Op = 0x6a
+
+	OpDrop Op = 0x75
+	OpDup  Op = 0x76
+	OpSwap Op = 0x7c
+
+	OpEqual       Op = 0x87
+	OpEqualVerify Op = 0x88
+	OpNot         Op = 0x91
+	OpBoolAnd     Op = 0x9a
+	OpBoolOr      Op = 0x9b
+
+	OpSHA256              Op = 0xa8
+	OpCheckSig            Op = 0xac
+	OpCheckSigVerify      Op = 0xad
+	OpCheckMultisig       Op = 0xae
+	OpCheckMultisigVerify Op = 0xaf
+
+	OpCheckHeightVerify Op = 0xb1
+	OpCheckTimeVerify   Op = 0xb2
+)
+
+var names = map[Op]string{
+	OpFalse: "OP_0", OpIf: "OP_IF", OpElse: "OP_ELSE", OpEndIf: "OP_ENDIF", OpVerify: "OP_VERIFY", OpReturn: "OP_RETURN",
+	OpDrop: "OP_DROP", OpDup: "OP_DUP", OpSwap: "OP_SWAP",
+	OpEqual: "OP_EQUAL", OpEqualVerify: "OP_EQUALVERIFY", OpNot: "OP_NOT", OpBoolAnd: "OP_BOOLAND", OpBoolOr: "OP_BOOLOR",
+	OpSHA256: "OP_SHA256", OpCheckSig: "OP_CHECKSIG", OpCheckSigVerify: "OP_CHECKSIGVERIFY",
+	OpCheckMultisig: "OP_CHECKMULTISIG", OpCheckMultisigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckHeightVerify: "OP_CHECKHEIGHTVERIFY", OpCheckTimeVerify: "OP_CHECKTIMEVERIFY",
+}
+
+var codes = map[string]Op{"OP_FALSE": OpFalse, "OP_TRUE": OpTrue}
+
+func init() {
+	for op := OpTrue; op <= Op16; op++ {
+		names[op] = "OP_" + strconv.Itoa(int(op-OpTrue)+1)
+	}
+	for op, name := range names {
+		codes[name] = op
+	}
+}
+
+/*
+	String() returns the assembler name of op
+*/
+func (op Op) String() string {
+	if name, ok := names[op]; ok {
+		return name
+	}
+	if op > OpFalse && op < OpPushData1 {
+		return "OP_PUSHBYTES_" + strconv.Itoa(int(op))
+	}
+	return "OP_UNKNOWN_" + hex.EncodeToString([]byte{byte(op)})
+}
+
+/*
+	instruction is an Op with the data it pushes
+*/
+type instruction struct {
+	op   Op
+	data []byte
+}
+
+/*
+	pushes() reports whether in only pushes data
+*/
+func (in instruction) pushes() bool {
+	return in.op <= OpPushData2 || (in.op >= OpTrue && in.op <= Op16)
+}
+
+/*
+	parse() splits script into instructions, checking sizes, known codes and
+	that every OP_IF is closed
+*/
+func parse(script []byte) ([]instruction, error) {
+	if len(script) > MaxSize {
+		return nil, errors.New("script is longer than " + strconv.Itoa(MaxSize) + " bytes")
+	}
+	var prog []instruction
+	depth := 0
+	for i := 0; i < len(script); {
+		op := Op(script[i])
+		i++
+		n := 0
+		switch {
+		case op > OpFalse && op < OpPushData1:
+			n = int(op)
+		case op == OpPushData1:
+			if i+1 > len(script) {
+				return prog, errors.New("truncated OP_PUSHDATA1 at byte " + strconv.Itoa(i-1))
+			}
+			n = int(script[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(script) {
+				return prog, errors.New("truncated OP_PUSHDATA2 at byte " + strconv.Itoa(i-1))
+			}
+			n = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		case op == OpIf:
+			depth++
+		case op == OpElse && depth == 0, op == OpEndIf && depth == 0:
+			return prog, errors.New(op.String() + " without OP_IF at byte " + strconv.Itoa(i-1))
+		case op == OpEndIf:
+			depth--
+		default:
+			if _, ok := names[op]; !ok {
+				return prog, errors.New("unknown operation " + op.String() + " at byte " + strconv.Itoa(i-1))
+			}
+		}
+		if n > MaxPush {
+			return prog, errors.New("push of more than " + strconv.Itoa(MaxPush) + " bytes")
+		}
+		if i+n > len(script) {
+			return prog, errors.New("truncated push at byte " + strconv.Itoa(i-1))
+		}
+		var data []byte
+		if op < OpTrue {
+			data = script[i : i+n]
+		}
+		prog = append(prog, instruction{op, data})
+		i += n
+	}
+	if depth != 0 {
+		return prog, errors.New("OP_IF without OP_ENDIF")
+	}
+	return prog, nil
+}
+
+/*
+	Validate() reports why script cannot be run, nil if it can
+*/
+func Validate(script []byte) error {
+	_, err := parse(script)
+	return err
+}
+
+/*
+	PushOnly() reports whether script is valid and only pushes data, as an
+	unlocking script must
+*/
+func PushOnly(script []byte) bool {
+	prog, err := parse(script)
+	if err != nil {
+		return false
+	}
+	for _, in := range prog {
+		if !in.pushes() {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+	Disassemble() returns script as assembler text, data pushes as 0x
+	prefixed hex. A malformed script is disassembled up to the error
+*/
+func Disassemble(script []byte) (string, error) {
+	prog, err := parse(script)
+	words := make([]string, 0, len(prog))
+	for _, in := range prog {
+		if in.op > OpFalse && in.op < OpTrue {
+			words = append(words, "0x"+hex.EncodeToString(in.data))
+		} else {
+			words = append(words, in.op.String())
+		}
+	}
+	return strings.Join(words, " "), err
+}
+
+/*
+	Assemble() returns the script of assembler text: operation names, 0x
+	prefixed hex data and decimal numbers, separated by white space
+*/
+func Assemble(text string) ([]byte, error) {
+	var script []byte
+	for _, word := range strings.Fields(text) {
+		if op, ok := codes[strings.ToUpper(word)]; ok {
+			script = append(script, byte(op))
+			continue
+		}
+		if strings.HasPrefix(word, "0x") {
+			data, err := hex.DecodeString(word[2:])
+			if err != nil {
+				return nil, errors.New("malformed data " + word)
+			}
+			script = AppendData(script, data)
+			continue
+		}
+		n, err := strconv.ParseUint(word, 10, 56)
+		if err != nil {
+			return nil, errors.New("unknown word " + word)
+		}
+		script = AppendNumber(script, int64(n))
+	}
+	return script, Validate(script)
+}
+
+/*
+	AppendData() appends a push of data to script
+*/
+func AppendData(script []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n < int(OpPushData1):
+		script = append(script, byte(n))
+	case n <= 0xff:
+		script = append(script, byte(OpPushData1), byte(n))
+	default:
+		script = append(script, byte(OpPushData2), byte(n), byte(n>>8))
+	}
+	return append(script, data...)
+}
+
+/*
+	AppendNumber() appends a push of the non-negative n to script, OP_0 to
+	OP_16 for small numbers
+*/
+func AppendNumber(script []byte, n int64) []byte {
+	if n == 0 {
+		return append(script, byte(OpFalse))
+	}
+	if n <= 16 {
+		return append(script, byte(OpTrue)+byte(n-1))
+	}
+	return AppendData(script, numberBytes(n))
+}
+
+/*
+	numberBytes() returns the minimal little endian bytes of n
+*/
+func numberBytes(n int64) []byte {
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append(b, byte(n))
+	}
+	return b
+}
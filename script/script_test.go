@@ -0,0 +1,129 @@
+package script
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+)
+
+func newKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	priv, pub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, "0x" + hex.EncodeToString(crypto.CompressPublicKey(pub))
+}
+
+func sign(t *testing.T, priv *ecdsa.PrivateKey, digest []byte) string {
+	r, s, err := crypto.Sign(priv, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "0x" + hex.EncodeToString(crypto.SignatureBytes(r, s))
+}
+
+func assemble(t *testing.T, text string) []byte {
+	script, err := Assemble(text)
+	if err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	return script
+}
+
+func TestAssemble(t *testing.T) {
+	text := "OP_DUP OP_SHA256 0x0102 OP_EQUALVERIFY OP_16 OP_0 0x" + strings.Repeat("ab", 80) + " OP_CHECKSIG"
+	script := assemble(t, text)
+	if got, err := Disassemble(script); err != nil || got != text {
+		t.Errorf("disassembled to %q, %v", got, err)
+	}
+	if got, _ := Disassemble(assemble(t, "5 200 OP_TRUE")); got != "OP_5 0xc8 OP_1" {
+		t.Errorf("numbers disassembled to %q", got)
+	}
+
+	tests := []struct {
+		name   string
+		script []byte
+	}{
+		{"truncated push", []byte{3, 1, 2}},
+		{"unknown operation", []byte{byte(OpTrue), 0xff}},
+		{"open branch", []byte{byte(OpTrue), byte(OpIf)}},
+		{"else without if", []byte{byte(OpElse)}},
+		{"too long", make([]byte, MaxSize+1)},
+	}
+	for _, test := range tests {
+		if err := Validate(test.script); err == nil {
+			t.Errorf("%s validated", test.name)
+		}
+	}
+	if got, err := Disassemble([]byte{byte(OpDup), 3, 1}); err == nil || got != "OP_DUP" {
+		t.Errorf("malformed script disassembled to %q, %v", got, err)
+	}
+	if _, err := Assemble("OP_DUP OP_NOPE"); err == nil {
+		t.Error("unknown word assembled")
+	}
+}
+
+func TestRun(t *testing.T) {
+	digest := sha256.Sum256([]byte("spend"))
+	ctx := Context{Digest: digest[:], Height: 10, Time: 1000}
+	alice, aliceKey := newKey(t)
+	bob, bobKey := newKey(t)
+	claire, claireKey := newKey(t)
+	aliceSig, bobSig, claireSig := sign(t, alice, digest[:]), sign(t, bob, digest[:]), sign(t, claire, digest[:])
+	hash := sha256.Sum256([]byte("secret"))
+	multisig := "OP_2 " + aliceKey + " " + bobKey + " " + claireKey + " OP_3 OP_CHECKMULTISIG"
+	htlc := func(timeout string) string {
+		return "OP_IF OP_SHA256 0x" + hex.EncodeToString(hash[:]) + " OP_EQUALVERIFY " + bobKey +
+			" OP_ELSE " + timeout + " OP_CHECKHEIGHTVERIFY OP_DROP " + aliceKey + " OP_ENDIF OP_CHECKSIG"
+	}
+
+	tests := []struct {
+		name   string
+		unlock string
+		lock   string
+		ok     bool
+	}{
+		{"pay to key", aliceSig, aliceKey + " OP_CHECKSIG", true},
+		{"other key", bobSig, aliceKey + " OP_CHECKSIG", false},
+		{"two of three", aliceSig + " " + claireSig, multisig, true},
+		{"signatures out of order", claireSig + " " + aliceSig, multisig, false},
+		{"same signature twice", aliceSig + " " + aliceSig, multisig, false},
+		{"claim with preimage", bobSig + " 0x" + hex.EncodeToString([]byte("secret")) + " OP_1", htlc("20"), true},
+		{"claim with a guess", bobSig + " 0x00 OP_1", htlc("20"), false},
+		{"early refund", aliceSig + " OP_0", htlc("20"), false},
+		{"refund", aliceSig + " OP_0", htlc("10"), true},
+		{"time lock", "", "1000 OP_CHECKTIMEVERIFY", true},
+		{"future time lock", "", "1001 OP_CHECKTIMEVERIFY", false},
+		{"boolean ops", "OP_1 OP_0", "OP_BOOLOR OP_0 OP_NOT OP_BOOLAND", true},
+		{"false left", "OP_0", "", false},
+		{"return", "OP_1", "OP_RETURN", false},
+		{"unlock not push only", "OP_1 OP_DUP", "OP_EQUAL", false},
+		{"empty stack", "", "OP_DROP", false},
+	}
+	for _, test := range tests {
+		err := Run(assemble(t, test.unlock), assemble(t, test.lock), ctx)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	// every operation is a step, skipped branches too
+	lock := assemble(t, "OP_0 OP_IF"+strings.Repeat(" OP_DUP", MaxSteps)+" OP_ENDIF OP_1")
+	if err := Run(nil, lock, Context{}); err == nil || !strings.Contains(err.Error(), "steps") {
+		t.Errorf("long script returned %v", err)
+	}
+	lock = assemble(t, "OP_1"+strings.Repeat(" OP_DUP", MaxStack))
+	if err := Run(nil, lock, Context{}); err == nil || !strings.Contains(err.Error(), "stack") {
+		t.Errorf("deep stack returned %v", err)
+	}
+	if !bytes.Equal(AppendNumber(nil, 0), []byte{byte(OpFalse)}) || !bytes.Equal(AppendNumber(nil, 256), []byte{2, 0, 1}) {
+		t.Error("numbers not pushed minimally")
+	}
+}
@@ -0,0 +1,14 @@
+/*
+	Package goofycoin bundles the dashboard served by the goofyd node, the
+	node itself lives in cmd/goofyd and the ledger in the packages below
+*/
+package goofycoin
+
+import "embed"
+
+/*
+	Static holds the public/ and assets/ directories, assets/openapi.json
+	describes the API
+*/
+//go:embed public assets
+var Static embed.FS
@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/de7ign/goofy-coin/errkind"
+)
+
+/*
+	Validation rules of users, the dashboard checks the same rules in
+	assets/js/script.js before sending a request
+*/
+
+const (
+	MaxUserNameLen = 32
+	MinPasswordLen = 8
+)
+
+/*
+	ValidateUserName() accepts non-empty alphanumeric names without spaces
+*/
+func ValidateUserName(name string) error {
+	if name == "" {
+		return errkind.Failed("please enter a username")
+	}
+	if len(name) > MaxUserNameLen {
+		return errkind.Failed("username longer than " + strconv.Itoa(MaxUserNameLen) + " characters")
+	}
+	for _, c := range name {
+		if unicode.IsSpace(c) {
+			return errkind.Failed("no space allowed")
+		}
+		if c > unicode.MaxASCII || !(unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			return errkind.Failed("only numbers and alphabets are allowed")
+		}
+	}
+	return nil
+}
+
+/*
+	ValidatePassword() accepts passwords of at least MinPasswordLen characters
+*/
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLen {
+		return errkind.Failed("password shorter than " + strconv.Itoa(MinPasswordLen) + " characters")
+	}
+	return nil
+}
+
+/*
+	SameUserName() compares names case-insensitively, "Goofy" is taken once
+	"goofy" exists
+*/
+func SameUserName(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
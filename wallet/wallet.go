@@ -0,0 +1,202 @@
+/*
+	Package wallet is the user registry of the ledger, it holds every user's
+	public key and the private keys the server keeps for users who did not
+	register their own
+*/
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/de7ign/goofy-coin/crypto"
+	"github.com/de7ign/goofy-coin/errkind"
+	"github.com/gofrs/uuid"
+)
+
+/*
+	User is a coin owner, PrivateKey is nil when the user keeps it and
+	Password is nil when the user cannot log in with one
+*/
+type User struct {
+	UUID       uuid.UUID         `json:"uuid"`
+	Name       string            `json:"name"`
+	PrivateKey *ecdsa.PrivateKey `json:"-"`
+	PublicKey  *ecdsa.PublicKey  `json:"-"`
+	Password   []byte            `json:"-"`
+}
+
+var ErrUserNotFound = &errkind.Error{Kind: errkind.NotFound, Message: "user not found"}
+
+/*
+	Wallet holds users in order of registration, the first is goofy. It is
+	not safe for concurrent use, the ledger guards its wallet
+*/
+type Wallet struct {
+	users []User
+}
+
+func New() *Wallet {
+	return &Wallet{}
+}
+
+/*
+	Register() adds a user, if pubKey is nil a key pair is generated and held
+	by the wallet, otherwise the user keeps the private key. Without a
+	password the user cannot log in with one
+*/
+func (w *Wallet) Register(name string, pubKey *ecdsa.PublicKey, password string) (User, error) {
+	var privKey *ecdsa.PrivateKey
+	if pubKey == nil {
+		var err error
+		privKey, pubKey, err = crypto.GenerateKeyPair()
+		if err != nil {
+			return User{}, err
+		}
+	}
+	return w.Add(name, privKey, pubKey, password)
+}
+
+/*
+	Add() adds a user with the provided keys, names are unique regardless of
+	case
+*/
+func (w *Wallet) Add(name string, privKey *ecdsa.PrivateKey, pubKey *ecdsa.PublicKey, password string) (User, error) {
+	if err := ValidateUserName(name); err != nil {
+		return User{}, err
+	}
+	var passwordHash []byte
+	if password != "" {
+		if err := ValidatePassword(password); err != nil {
+			return User{}, err
+		}
+		var err error
+		passwordHash, err = crypto.HashPassword(password)
+		if err != nil {
+			return User{}, err
+		}
+	}
+	for _, u := range w.users {
+		if SameUserName(u.Name, name) {
+			return User{}, errkind.New(errkind.Conflict, "user name "+u.Name+" is taken")
+		}
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return User{}, err
+	}
+	u := User{UUID: id, Name: name, PrivateKey: privKey, PublicKey: pubKey, Password: passwordHash}
+	w.users = append(w.users, u)
+	return u, nil
+}
+
+/*
+	Restore() appends u as it was journaled, without validation
+*/
+func (w *Wallet) Restore(u User) {
+	w.users = append(w.users, u)
+}
+
+/*
+	Attach() gives the user id its private key, which must match the
+	registered public key, and a password if it is not empty
+*/
+func (w *Wallet) Attach(id uuid.UUID, privKey *ecdsa.PrivateKey, password string) error {
+	u, err := w.find(id)
+	if err != nil {
+		return err
+	}
+	if !u.PublicKey.Equal(&privKey.PublicKey) {
+		return errors.New(u.Name + "'s key does not match the ledger")
+	}
+	var passwordHash []byte
+	if password != "" {
+		if err := ValidatePassword(password); err != nil {
+			return err
+		}
+		passwordHash, err = crypto.HashPassword(password)
+		if err != nil {
+			return err
+		}
+	}
+	u.PrivateKey = privKey
+	if passwordHash != nil {
+		u.Password = passwordHash
+	}
+	return nil
+}
+
+func (w *Wallet) find(id uuid.UUID) (*User, error) {
+	for i := range w.users {
+		if w.users[i].UUID == id {
+			return &w.users[i], nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+/*
+	User() returns the user with id
+*/
+func (w *Wallet) User(id uuid.UUID) (User, error) {
+	u, err := w.find(id)
+	if err != nil {
+		return User{}, err
+	}
+	return *u, nil
+}
+
+/*
+	UserByName() returns the user named name, regardless of case
+*/
+func (w *Wallet) UserByName(name string) (User, error) {
+	for _, u := range w.users {
+		if SameUserName(u.Name, name) {
+			return u, nil
+		}
+	}
+	return User{}, ErrUserNotFound
+}
+
+/*
+	Users() returns every user in order of registration
+*/
+func (w *Wallet) Users() []User {
+	return append([]User{}, w.users...)
+}
+
+func (w *Wallet) Len() int {
+	return len(w.users)
+}
+
+/*
+	PrivateKey() returns the private key of id, nil if the user keeps it
+*/
+func (w *Wallet) PrivateKey(id uuid.UUID) (*ecdsa.PrivateKey, error) {
+	u, err := w.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return u.PrivateKey, nil
+}
+
+/*
+	PublicKey() returns the public key of id
+*/
+func (w *Wallet) PublicKey(id uuid.UUID) (*ecdsa.PublicKey, error) {
+	u, err := w.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return u.PublicKey, nil
+}
+
+/*
+	Goofy() returns the first user, who mints every coin
+*/
+func (w *Wallet) Goofy() (User, error) {
+	if len(w.users) == 0 {
+		return User{}, errkind.New(errkind.NotFound, "goofy not found")
+	}
+	return w.users[0], nil
+}
@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/de7ign/goofy-coin/crypto"
+)
+
+func TestWallet(t *testing.T) {
+	w := New()
+	for _, name := range []string{"goofy", "alice", "bob", "claire", "dave"} {
+		if _, err := w.Register(name, nil, ""); err != nil {
+			t.Errorf("cannot create user %s: %v", name, err)
+		}
+	}
+	goofy := w.Users()[0]
+
+	priv, err := w.PrivateKey(goofy.UUID)
+	if err != nil {
+		t.Error(err)
+	}
+	if goofy.PrivateKey != priv {
+		t.Error("Private key doesnt match")
+	}
+	pub, err := w.PublicKey(goofy.UUID)
+	if err != nil {
+		t.Error(err)
+	}
+	if goofy.PublicKey != pub {
+		t.Error("Public key doesnt match")
+	}
+	if u, err := w.UserByName("ALICE"); err != nil || u.Name != "alice" {
+		t.Errorf("alice not found by name: %v", err)
+	}
+}
+
+func TestUniqueUserName(t *testing.T) {
+	w := New()
+	if _, err := w.Register("goofy", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Register("GOOFY", nil, ""); err == nil {
+		t.Error("second goofy created")
+	}
+	if w.Len() != 1 {
+		t.Errorf("got %d users", w.Len())
+	}
+}
+
+func TestAttach(t *testing.T) {
+	w := New()
+	priv, pub, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := w.Register("alice", pub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Attach(alice.UUID, other, ""); err == nil {
+		t.Error("another key attached")
+	}
+	if err := w.Attach(alice.UUID, priv, "wonderland"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := w.User(alice.UUID)
+	if u.PrivateKey != priv || !crypto.CheckPassword(u.Password, "wonderland") {
+		t.Error("key and password not attached")
+	}
+}
+
+func TestValidateUserName(t *testing.T) {
+	valid := []string{"goofy", "Alice", "bob42", "7"}
+	invalid := []string{"", "bob smith", "alice!", "élodie", "abcdefghijklmnopqrstuvwxyz0123456789"}
+	for _, name := range valid {
+		if err := ValidateUserName(name); err != nil {
+			t.Errorf("%q rejected: %v", name, err)
+		}
+	}
+	for _, name := range invalid {
+		if err := ValidateUserName(name); err == nil {
+			t.Errorf("%q accepted", name)
+		}
+	}
+}